@@ -0,0 +1,150 @@
+package gomigratex
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/lock"
+)
+
+// maxConnectBackoff caps the doubling wait between connection attempts.
+const maxConnectBackoff = 30 * time.Second
+
+// DefaultPingTimeout bounds Ping when no timeout is given.
+const DefaultPingTimeout = 5 * time.Second
+
+// ExitCannotConnect is the exit status for a run that could not reach the
+// database, EX_UNAVAILABLE from sysexits.h, so it is told apart from a
+// failing migration.
+const ExitCannotConnect = 69
+
+// ErrCannotConnect wraps the error from Ping and ConnectConfig when the
+// database can't be reached or rejects the credentials, before anything
+// touches the tracking table.
+var ErrCannotConnect = errors.New("cannot connect to the database")
+
+// Ping checks that pool can reach the database within timeout
+// (DefaultPingTimeout when 0). Do it right after opening so wrong
+// credentials or an unreachable host fail with ErrCannotConnect instead of
+// surfacing later as a DDL error from Ensure.
+func Ping(ctx context.Context, pool *sql.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultPingTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := pool.PingContext(ctx)
+	switch {
+	case err == nil:
+		return nil
+	case db.IsAuthError(err):
+		return fmt.Errorf("%w: credentials rejected, check the DSN's user and password: %w", ErrCannotConnect, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrCannotConnect, err)
+	}
+}
+
+// ConnectRetryable reports whether a failure to connect or to take the lock
+// may clear up by itself, such as a refused connection while the database
+// container is still starting. Rejected credentials never are.
+func ConnectRetryable(err error) bool {
+	return !db.IsAuthError(err) && db.IsTransient(err)
+}
+
+// ConnectConfig opens cfg's database like OpenConfig and pings it with
+// Ping. While the ping fails with a ConnectRetryable error it tries again up to
+// cfg.ConnectRetries more times, waiting cfg.ConnectBackoff() (1s when
+// unset) and doubling the wait each time up to 30s, and giving up early at
+// ctx's deadline. onRetry, if set, is called before each wait.
+func ConnectConfig(ctx context.Context, cfg *Config, onRetry func(attempt int, wait time.Duration, err error)) (*sql.DB, Driver, error) {
+	var (
+		conn   *sql.DB
+		driver Driver
+	)
+	err := retryConnect(ctx, cfg.ConnectRetries, cfg.ConnectBackoff(), func() error {
+		c, d, err := OpenConfig(cfg)
+		if err != nil {
+			return err
+		}
+		if err := Ping(ctx, c, 0); err != nil {
+			_ = c.Close()
+			return err
+		}
+		conn, driver = c, d
+		return nil
+	}, onRetry)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, driver, nil
+}
+
+// AcquireLock acquires lk like lk.Acquire, retrying ConnectRetryable errors
+// the way ConnectConfig does. A lock held by another run (ErrNotAcquired)
+// is not retried; timeout is how long to wait for that.
+func AcquireLock(ctx context.Context, lk *Lock, pool *sql.DB, timeout time.Duration, retries int, backoff time.Duration, onRetry func(attempt int, wait time.Duration, err error)) error {
+	return retryConnect(ctx, retries, backoff, func() error {
+		return lk.Acquire(ctx, pool, timeout)
+	}, onRetry)
+}
+
+// ErrAlreadyRunning is returned by AcquireLockConfig under skip_if_locked
+// when another run holds the lock. It is not a failure: log it and exit
+// successfully, leaving the migration to the run that holds the lock.
+var ErrAlreadyRunning = errors.New("another migration in progress, skipping")
+
+// AcquireLockConfig is AcquireLock with cfg's lock_mode, lock_timeout_sec,
+// connect_retries and connect_backoff_sec; see Lock.AcquireMode. Under
+// lock_mode skip it takes no lock, so the caller should warn that
+// concurrent runs are not serialized. With skip_if_locked it doesn't wait
+// for the lock: if another run holds it, it returns ErrAlreadyRunning.
+func AcquireLockConfig(ctx context.Context, cfg *Config, lk *Lock, pool *sql.DB, onRetry func(attempt int, wait time.Duration, err error)) error {
+	mode, err := lock.ParseMode(cfg.LockMode)
+	if err != nil {
+		return fmt.Errorf("lock_mode: %w", err)
+	}
+	timeout := cfg.LockTimeout()
+	if cfg.SkipIfLocked {
+		timeout = 0
+	}
+	err = retryConnect(ctx, cfg.ConnectRetries, cfg.ConnectBackoff(), func() error {
+		return lk.AcquireMode(ctx, pool, mode, timeout)
+	}, onRetry)
+	if cfg.SkipIfLocked && errors.Is(err, lock.ErrNotAcquired) {
+		return ErrAlreadyRunning
+	}
+	return err
+}
+
+// retryConnect calls fn until it succeeds, fails with an error that isn't
+// ConnectRetryable, or retries are used up, with exponential backoff.
+func retryConnect(ctx context.Context, retries int, backoff time.Duration, fn func() error, onRetry func(attempt int, wait time.Duration, err error)) error {
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	wait := backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > retries || !ConnectRetryable(err) {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt+1, wait, err)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		wait = min(wait*2, maxConnectBackoff)
+	}
+}
@@ -7,8 +7,7 @@ import (
 	"embed"
 	"log"
 
-	"github.com/mirajehossain/gomigratex/internal/db"
-	"github.com/mirajehossain/gomigratex/internal/migrator"
+	"github.com/mirajehossain/gomigratex"
 )
 
 //go:embed migrations/*.sql
@@ -16,21 +15,21 @@ var migFS embed.FS
 
 func main() {
 	dsn := "user:admin@testpass1(127.0.0.1:3306)/test?parseTime=true&multiStatements=true"
-	sqlDB, err := db.OpenMySQL(dsn)
+	sqlDB, driver, err := gomigratex.Open(dsn)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer sqlDB.Close()
 
-	run := migrator.NewRunner(sqlDB, "schema_migrations", "embedded-example")
+	run := gomigratex.NewRunner(sqlDB, driver, "schema_migrations", "embedded-example")
 	if err := run.Ensure(context.Background()); err != nil {
 		log.Fatal(err)
 	}
 
-	src := migrator.FileSource{
-		FS: migFS, RootDir: "migrations", Embedded: true,
+	src := gomigratex.FileSource{
+		FS: migFS, RootDir: "migrations",
 	}
-	plan, err := migrator.DiscoverAndPlan(context.Background(), src, run.Storage)
+	plan, err := gomigratex.DiscoverAndPlan(context.Background(), src, run.Storage)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,87 @@
+//go:build examples
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mirajehossain/gomigratex"
+)
+
+// source is this file, so editing the migration changes its checksum.
+//
+//go:embed gomigration.go
+var source []byte
+
+func init() {
+	gomigratex.RegisterGoMigrationWith(gomigratex.GoMigration{
+		Version: "20250102000000",
+		Name:    "backfill_email_domain",
+		Up:      backfillEmailDomain,
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE users SET email_domain = NULL")
+			return err
+		},
+		Source: source,
+	})
+}
+
+// backfillEmailDomain fills a column from logic that is easier in Go than SQL.
+func backfillEmailDomain(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, email FROM users WHERE email_domain IS NULL")
+	if err != nil {
+		return err
+	}
+	domains := map[int]string{}
+	for rows.Next() {
+		var id int
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, domain, ok := strings.Cut(email, "@"); ok {
+			domains[id] = strings.ToLower(domain)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, domain := range domains {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET email_domain = ? WHERE id = ?", domain, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	dsn := "user:admin@testpass1(127.0.0.1:3306)/test?parseTime=true&multiStatements=true"
+	sqlDB, driver, err := gomigratex.Open(dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	run := gomigratex.NewRunner(sqlDB, driver, "schema_migrations", "gomigration-example")
+	if err := run.Ensure(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	// The SQL file creates the table; the registered Go migration sorts
+	// after it by version.
+	src := gomigratex.FileSource{FS: os.DirFS("examples/gomigration"), RootDir: "migrations"}
+	plan, err := gomigratex.DiscoverAndPlan(context.Background(), src, run.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := run.ApplyUp(context.Background(), plan.Pending, false, nil); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1 @@
+CREATE TABLE users (id INT PRIMARY KEY, email VARCHAR(255) NOT NULL, email_domain VARCHAR(255) NULL);
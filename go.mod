@@ -7,3 +7,5 @@ require (
 	github.com/go-sql-driver/mysql v1.7.1
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/lib/pq v1.10.9
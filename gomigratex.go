@@ -0,0 +1,445 @@
+// Package gomigratex is the public library API for running SQL migrations
+// from your own program, e.g. at application startup.
+//
+// The implementation lives in internal packages; this package re-exports the
+// parts that are stable. Types are aliases, so values move freely between
+// this package and code written against the internal ones.
+//
+// Stability: the types, functions and variables declared here, and the
+// exported fields documented on them, follow semantic versioning. Runner's
+// DB, Storage, AppliedBy, Params, LockWaitTimeout, PauseBetween and OnWarn;
+// Plan's Pending, Applied, All and Skipped; FilePair's Version, Name,
+// Checksum, UpBytes and DownBytes; and FileSource's FS, RootDir, Ext,
+// Dialect, Layers and Inline are stable. Other exported fields reachable
+// through these aliases may change between minor releases.
+package gomigratex
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/config"
+	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/fsutil"
+	"github.com/mirajehossain/gomigratex/internal/lock"
+	"github.com/mirajehossain/gomigratex/internal/logger"
+	"github.com/mirajehossain/gomigratex/internal/migrator"
+	"github.com/mirajehossain/gomigratex/internal/schemadiff"
+)
+
+type (
+	// Runner applies and reverts migrations and records them in the tracking table.
+	Runner = migrator.Runner
+	// Plan is the result of DiscoverAndPlan.
+	Plan = migrator.Plan
+	// FilePair is one migration: its up and down SQL.
+	FilePair = migrator.FilePair
+	// FileSource says where migrations are read from.
+	FileSource = migrator.FileSource
+	// ChecksumFunc overrides how FileSource computes checksums.
+	ChecksumFunc = migrator.ChecksumFunc
+	// Layer is an extra source in FileSource.Layers.
+	Layer = migrator.Layer
+	// InlineMigration is a migration given as literal SQL.
+	InlineMigration = migrator.InlineMigration
+	// GoMigration is a migration written as Go functions.
+	GoMigration = migrator.GoMigration
+	// GoMigrationFunc is one direction of a GoMigration.
+	GoMigrationFunc = migrator.GoMigrationFunc
+	// Row is a tracking table row.
+	Row = migrator.Row
+	// Storage reads and writes the tracking table.
+	Storage = migrator.Storage
+	// PlanOption customizes DiscoverAndPlan.
+	PlanOption = migrator.PlanOption
+	// Config is the YAML/env configuration used by the CLI.
+	Config = config.Config
+	// Driver isolates database-specific SQL (MySQL, Postgres or SQLite).
+	Driver = db.Driver
+	// Lock is the advisory lock that serializes concurrent runners.
+	Lock = lock.Advisory
+	// DriftError carries the key and checksums of a drifted migration.
+	DriftError = migrator.DriftError
+	// MigrationError names the migration whose failure stopped a run.
+	MigrationError = migrator.MigrationError
+	// StatusReport is the result of Status.
+	StatusReport = migrator.StatusReport
+	// Seed is a reference-data file run by Runner.ApplySeeds.
+	Seed = migrator.Seed
+	// SeedStorage reads and writes the seeds table.
+	SeedStorage = migrator.SeedStorage
+	// SeedResult is what Runner.ApplySeeds did with one seed.
+	SeedResult = migrator.SeedResult
+	// WarningCounter counts logged warnings for Config.CheckStrict.
+	WarningCounter = config.WarningCounter
+	// DryRunFormat selects how Runner.WriteDryRun renders the planned SQL.
+	DryRunFormat = migrator.DryRunFormat
+	// DiffCommand is the external schema-diff tool run by CreateFromDiff.
+	DiffCommand = schemadiff.Command
+	// OnConflict says what happens when a new migration file already exists.
+	OnConflict = fsutil.OnConflict
+	// Created reports the files written for a new migration pair.
+	Created = fsutil.Created
+	// Templates hold text/template sources for new migration files.
+	Templates = fsutil.Templates
+	// Logger writes plain-text or JSON logs, or forwards them to a
+	// slog.Handler, and counts warnings for Config.CheckStrict.
+	Logger = logger.Logger
+	// LockMode selects how AcquireLockConfig and Lock.AcquireMode take the
+	// lock.
+	LockMode = lock.Mode
+	// Estimate is one migration's expected duration from EstimatePlan.
+	Estimate = migrator.Estimate
+	// Approver gates each migration in Runner.ApplyUp.
+	Approver = migrator.Approver
+	// ApproverFunc adapts a function to Approver.
+	ApproverFunc = migrator.ApproverFunc
+	// HTTPApprover asks an external service to approve each migration; use
+	// it as a pointer.
+	HTTPApprover = migrator.HTTPApprover
+	// DirDiff is the result of DiffSources.
+	DirDiff = migrator.DirDiff
+	// ChangedPair is a migration whose files differ between two sources.
+	ChangedPair = migrator.ChangedPair
+)
+
+// Dry-run formats; see Runner.WriteDryRun.
+const (
+	DryRunText = migrator.DryRunText
+	DryRunSQL  = migrator.DryRunSQL
+)
+
+// Lock modes; see Lock.AcquireMode.
+const (
+	LockWait   = lock.ModeWait
+	LockNoWait = lock.ModeNoWait
+	LockSkip   = lock.ModeSkip
+)
+
+// OnConflict values for CreatePair and CreateFromDiff.
+const (
+	ConflictError  = fsutil.ConflictError
+	ConflictSkip   = fsutil.ConflictSkip
+	ConflictSuffix = fsutil.ConflictSuffix
+)
+
+var (
+	ErrDrift          = migrator.ErrDrift
+	ErrDownDrift      = migrator.ErrDownDrift
+	ErrChecksumAlgo   = migrator.ErrChecksumAlgo
+	ErrOrphaned       = migrator.ErrOrphaned
+	ErrMissingParam   = migrator.ErrMissingParam
+	ErrNotAcquired    = lock.ErrNotAcquired
+	ErrLockTimeout    = db.ErrLockTimeout
+	ErrLockLost       = lock.ErrLost
+	ErrTargetNotFound = migrator.ErrTargetNotFound
+	ErrShadowNotEmpty = migrator.ErrShadowNotEmpty
+	ErrFileExists     = fsutil.ErrFileExists
+
+	ErrMigrationTimeout = migrator.ErrMigrationTimeout
+	ErrStrictWarnings   = logger.ErrStrictWarnings
+	ErrNoDiffCommand    = schemadiff.ErrNoCommand
+	ErrNoDiffChanges    = schemadiff.ErrNoChanges
+)
+
+// Plan options; see the migrator package for details.
+var (
+	WithAsOf             = migrator.WithAsOf
+	WithSkip             = migrator.WithSkip
+	WithIgnoreFuture     = migrator.WithIgnoreFuture
+	WithFailOnOrphan     = migrator.WithFailOnOrphan
+	WithFailedRetryAfter = migrator.WithFailedRetryAfter
+	WithIgnoreDrift      = migrator.WithIgnoreDrift
+	WithOnWarn           = migrator.WithOnWarn
+)
+
+// ParseDryRunFormat parses a dry_run_format value: text or sql, empty
+// meaning text.
+func ParseDryRunFormat(s string) (DryRunFormat, error) {
+	return migrator.ParseDryRunFormat(s)
+}
+
+// CreatePair writes empty {version}_{name}.up{ext} and .down{ext} files in
+// dir, handling existing files according to onConflict.
+func CreatePair(dir, version, name, ext string, onConflict OnConflict) (Created, error) {
+	return fsutil.CreatePair(dir, version, name, ext, onConflict)
+}
+
+// CreatePairFrom is CreatePair with the files' contents rendered from tmpl,
+// e.g. from Config.CreateTemplates. A template error writes nothing.
+func CreatePairFrom(dir, version, name, ext string, onConflict OnConflict, tmpl Templates) (Created, error) {
+	return fsutil.CreatePairFrom(dir, version, name, ext, onConflict, tmpl)
+}
+
+// LoadTemplates reads template files: shared applies to both directions and
+// up or down, when set, override it for theirs. Empty paths are skipped.
+func LoadTemplates(shared, up, down string) (Templates, error) {
+	return fsutil.LoadTemplates(shared, up, down)
+}
+
+// CreateFromDiff runs the schema-diff tool c and writes its SQL as the up
+// file of a new migration pair in dir, with a best-effort down file written
+// by ReverseDiff in the dialect of dsn's driver. Review both files.
+func CreateFromDiff(ctx context.Context, c DiffCommand, schemaFile, dsn, dir, version, name, ext string, onConflict OnConflict) (Created, error) {
+	return schemadiff.Create(ctx, c, schemaFile, dsn, dir, version, name, ext, onConflict)
+}
+
+// ReverseDiff returns a best-effort down for the up SQL, using driver's
+// DROP INDEX syntax; driver may be nil for MySQL.
+func ReverseDiff(up string, driver Driver) string {
+	return schemadiff.Reverse(up, driver)
+}
+
+// ParseIsolation parses a transaction_isolation value for
+// Runner.Isolation: read-committed, repeatable-read or serializable, empty
+// meaning the server's default.
+func ParseIsolation(s string) (sql.IsolationLevel, error) {
+	return migrator.ParseIsolation(s)
+}
+
+// ParseLockMode parses a lock_mode value: wait, nowait or skip, empty
+// meaning wait.
+func ParseLockMode(s string) (LockMode, error) {
+	return lock.ParseMode(s)
+}
+
+// NewLogger returns a Logger writing plain text to stdout, or JSON lines
+// when jsonOutput is set, at info level.
+func NewLogger(jsonOutput bool) *Logger {
+	return logger.New(jsonOutput)
+}
+
+// NewLoggerWithHandler returns a Logger that sends records to h, e.g. the
+// handler of an application's own *slog.Logger.
+func NewLoggerWithHandler(h slog.Handler) *Logger {
+	return logger.NewWithHandler(h)
+}
+
+// ParseLogLevel parses a log_level value: debug, info, warn or error, empty
+// meaning info.
+func ParseLogLevel(s string) (slog.Level, error) {
+	return logger.ParseLevel(s)
+}
+
+// Open opens a connection pool for dsn and returns the driver matching its
+// scheme: postgres:// or postgresql:// selects Postgres, sqlite:// or file:
+// SQLite, and mysql:// or no scheme MySQL.
+func Open(dsn string) (*sql.DB, Driver, error) {
+	return db.Open(dsn)
+}
+
+// OpenConfig is Open with cfg's DSN (see Config.ResolveDSN), pool sizing
+// and connection init SQL.
+func OpenConfig(cfg *Config) (*sql.DB, Driver, error) {
+	dsn, err := cfg.ResolveDSN()
+	if err != nil {
+		return nil, nil, err
+	}
+	return db.OpenWith(dsn, cfg.DBOptions())
+}
+
+// NewRunner returns a Runner on database that records migrations in table
+// as appliedBy. driver may be nil for MySQL.
+func NewRunner(database *sql.DB, driver Driver, table, appliedBy string) *Runner {
+	r := migrator.NewRunner(database, table, appliedBy)
+	r.Storage.Driver = driver
+	return r
+}
+
+// NewLock returns the advisory lock for database and table on driver (nil
+// for MySQL). Hold it around Ensure, planning and applying when several
+// instances may migrate at once.
+func NewLock(driver Driver, database, table string) *Lock {
+	if driver == nil {
+		driver = db.MySQL
+	}
+	return lock.New(driver, lock.KeyFor(database, table))
+}
+
+// NewLockForDSN is NewLock for the database dsn names, parsed with the
+// driver's own DSN parser so socket and multi-host DSNs resolve correctly.
+// dbName, when set, is used instead, e.g. from lock_db_name.
+func NewLockForDSN(driver Driver, dsn, dbName, table string) (*Lock, error) {
+	key, err := lock.KeyForDSN(dsn, dbName, table)
+	if err != nil {
+		return nil, err
+	}
+	if driver == nil {
+		driver = db.MySQL
+	}
+	return lock.New(driver, key), nil
+}
+
+// NewLockConfig is NewLockForDSN with cfg's DSN, lock_db_name and
+// migrations table, keyed per lock_scope, and the heartbeat set from
+// lock_heartbeat_sec.
+func NewLockConfig(cfg *Config, driver Driver) (*Lock, error) {
+	key, err := cfg.LockKey()
+	if err != nil {
+		return nil, err
+	}
+	if driver == nil {
+		driver = db.MySQL
+	}
+	lk := lock.New(driver, key)
+	lk.SetHeartbeat(cfg.LockHeartbeat())
+	return lk, nil
+}
+
+// Since returns the migrations to re-apply after restoring a backup taken
+// at cutoff: those source recorded as applied after cutoff that restored
+// doesn't have, in source's execution order.
+func Since(files []FilePair, source, restored map[string]Row, cutoff time.Time) ([]FilePair, error) {
+	return migrator.Since(files, source, restored, cutoff)
+}
+
+// WriteApplied exports applied rows, e.g. from Storage.GetAll, as JSON for
+// ReadApplied in another environment.
+func WriteApplied(w io.Writer, applied map[string]Row) error {
+	return migrator.WriteApplied(w, applied)
+}
+
+// ReadApplied imports rows written by WriteApplied.
+func ReadApplied(r io.Reader) (map[string]Row, error) {
+	return migrator.ReadApplied(r)
+}
+
+// EstimatePlan estimates how long applying pending will take from each
+// migration's est-duration directive or history (keyed like
+// Storage.GetAll), and totals them with the pauses ApplyUp makes between
+// migrations, pauseBetween where a file has no pause-after.
+func EstimatePlan(pending []FilePair, history map[string]Row, pauseBetween time.Duration) ([]Estimate, time.Duration) {
+	return migrator.EstimatePlan(pending, history, pauseBetween)
+}
+
+// WriteEstimate writes estimates as a table followed by their total.
+func WriteEstimate(w io.Writer, estimates []Estimate, total time.Duration) error {
+	return migrator.WriteEstimate(w, estimates, total)
+}
+
+// DiffSources compares two migration sources by their files alone, with no
+// database: migrations only in a, only in b, and those whose files differ.
+func DiffSources(a, b FileSource) (DirDiff, error) {
+	return migrator.DiffSources(a, b)
+}
+
+// WriteDirDiff writes d as -/+/~ lines labelled nameA and nameB, or as JSON
+// when asJSON is set.
+func WriteDirDiff(w io.Writer, d DirDiff, nameA, nameB string, asJSON bool) error {
+	return migrator.WriteDirDiff(w, d, nameA, nameB, asJSON)
+}
+
+// Shadow applies every migration in files, in order, on a disposable
+// database reached through dsn, and returns the rows or the first failure.
+// With create, a fresh database is created on dsn's server and dropped
+// afterwards; otherwise dsn must name an empty database.
+func Shadow(ctx context.Context, dsn string, create bool, table string, files []FilePair) ([]Row, error) {
+	return migrator.Shadow(ctx, dsn, create, table, files)
+}
+
+// ShadowRun is Shadow on a pool the caller opened, which must have an empty
+// tracking table.
+func ShadowRun(ctx context.Context, shadow *sql.DB, driver Driver, table string, files []FilePair) ([]Row, error) {
+	if driver == nil {
+		driver = db.MySQL
+	}
+	return migrator.ShadowRun(ctx, shadow, driver, table, files)
+}
+
+// runShadow is Shadow; tests replace it.
+var runShadow = migrator.Shadow
+
+// ShadowConfig is Shadow with cfg's shadow_dsn, shadow_create and tracking
+// table.
+func ShadowConfig(ctx context.Context, cfg *Config, files []FilePair) ([]Row, error) {
+	if cfg.ShadowDSN == "" {
+		return nil, errors.New("shadow_dsn is not set")
+	}
+	driver, _, err := db.DriverFor(cfg.ShadowDSN)
+	if err != nil {
+		return nil, fmt.Errorf("shadow_dsn: %w", err)
+	}
+	table, err := cfg.TrackingTable(driver)
+	if err != nil {
+		return nil, err
+	}
+	return runShadow(ctx, cfg.ShadowDSN, cfg.ShadowCreate, table, files)
+}
+
+// DiscoverAndPlan reads src and compares it with the tracking table to decide
+// which migrations are pending.
+func DiscoverAndPlan(ctx context.Context, src FileSource, st *Storage, opts ...PlanOption) (*Plan, error) {
+	return migrator.DiscoverAndPlan(ctx, src, st, opts...)
+}
+
+// Status reports every migration in src as applied, pending or failed,
+// listing drift instead of failing on it.
+func Status(ctx context.Context, src FileSource, st *Storage, opts ...PlanOption) (StatusReport, error) {
+	return migrator.Status(ctx, src, st, opts...)
+}
+
+// WriteStatus writes rep as a table with a summary line, or as one JSON
+// object when asJSON is set.
+func WriteStatus(w io.Writer, rep StatusReport, asJSON bool) error {
+	return migrator.WriteStatus(w, rep, asJSON)
+}
+
+// LoadSeeds reads the *.sql seed files in dir in file name order.
+func LoadSeeds(dir string) ([]Seed, error) {
+	return migrator.LoadSeeds(dir)
+}
+
+// LoadSeedsWith is LoadSeeds with checksums computed by sum, e.g. the
+// FileSource.Checksum used for migrations.
+func LoadSeedsWith(dir string, sum ChecksumFunc) ([]Seed, error) {
+	return migrator.LoadSeedsWith(dir, sum)
+}
+
+// ApplySeedsConfig runs the seeds in cfg's seeds_dir with r, recording them
+// in seeds_table on r's database, and skips unchanged ones when
+// seed_only_changed is set.
+func ApplySeedsConfig(ctx context.Context, cfg *Config, r *Runner) ([]SeedResult, error) {
+	seeds, err := cfg.LoadSeeds()
+	if err != nil {
+		return nil, err
+	}
+	st := &SeedStorage{DB: r.Storage.DB, Table: cfg.SeedsTable, Driver: r.Storage.Driver}
+	return r.ApplySeeds(ctx, seeds, st, cfg.SeedOnlyChanged)
+}
+
+// RegisterGoMigration registers a Go migration that DiscoverAndPlan merges
+// with SQL files by version. Call it from an init function; down may be nil.
+func RegisterGoMigration(version, name string, up, down GoMigrationFunc) {
+	migrator.RegisterGoMigration(version, name, up, down)
+}
+
+// RegisterGoMigrationWith is RegisterGoMigration for a migration with a
+// Revision or Source to checksum.
+func RegisterGoMigrationWith(m GoMigration) {
+	migrator.RegisterGoMigrationWith(m)
+}
+
+// DefaultConfig returns the built-in configuration defaults.
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// LoadConfig loads .gomigratex.yaml from the working directory and its
+// ancestors, then from the migrations directory dir, then the explicit file,
+// and applies environment overrides on top. The result is validated.
+func LoadConfig(explicit, dir string) (*Config, error) {
+	cfg, err := config.LoadLayered(explicit, dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg = config.MergeEnv(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,447 @@
+package gomigratex
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestPublicAPIPlan(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"20250101000000_init.up.sql":   "CREATE TABLE t(id INT);",
+		"20250101000000_init.down.sql": "DROP TABLE t;",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(
+		[]string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}))
+
+	r := NewRunner(sqlDB, db.Postgres, "schema_migrations", "app")
+	if r.Storage.Driver != db.Postgres || r.AppliedBy != "app" {
+		t.Fatalf("runner not configured: %+v", r)
+	}
+	plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, r.Storage)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(plan.Pending) != 1 || plan.Pending[0].Name != "init" {
+		t.Fatalf("unexpected plan: %+v", plan.Pending)
+	}
+	if NewLock(nil, "app", "schema_migrations").Key() == "" {
+		t.Fatal("lock key must be set")
+	}
+}
+
+func TestConfigInlineMigrationsArePlanned(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "gomigratex.yaml")
+	yaml := "migrations:\n  - version: \"20250101000000\"\n    name: init\n    up: CREATE TABLE settings (k TEXT);\n    down: DROP TABLE settings;\n"
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(cfgPath, dir)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	src, err := cfg.FileSource()
+	if err != nil {
+		t.Fatalf("file source: %v", err)
+	}
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(
+		[]string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}))
+
+	r := NewRunner(sqlDB, nil, cfg.MigrationsTable, "app")
+	plan, err := DiscoverAndPlan(context.Background(), src, r.Storage)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(plan.Pending) != 1 || plan.Pending[0].Name != "init" || string(plan.Pending[0].UpBytes) != "CREATE TABLE settings (k TEXT);" {
+		t.Fatalf("inline migration not planned: %+v", plan.Pending)
+	}
+}
+
+func TestRetryRun_TransientThenSuccess(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"20250101000000_init.up.sql":   "CREATE TABLE t(id INT);",
+		"20250101000000_init.down.sql": "DROP TABLE t;",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+
+	// Each attempt opens a fresh pool, as the up flow does after a restart.
+	var attempts int
+	run := func(ctx context.Context) error {
+		attempts++
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		defer sqlDB.Close()
+		if attempts == 1 {
+			mock.ExpectQuery("SELECT version, name, checksum").WillReturnError(mysql.ErrInvalidConn)
+		} else {
+			mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns))
+			mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+			mock.ExpectBegin()
+			mock.ExpectExec("CREATE TABLE t").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+			mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+				WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+		}
+		r := NewRunner(sqlDB, nil, "schema_migrations", "app")
+		plan, err := DiscoverAndPlan(ctx, FileSource{RootDir: dir}, r.Storage)
+		if err != nil {
+			return err
+		}
+		if _, err := r.ApplyUp(ctx, plan.Pending, false, nil); err != nil {
+			return err
+		}
+		return mock.ExpectationsWereMet()
+	}
+	var retried []int
+	err := RetryRun(context.Background(), 3, time.Millisecond, run, func(attempt int, err error) {
+		retried = append(retried, attempt)
+	})
+	if err != nil {
+		t.Fatalf("retry run: %v", err)
+	}
+	if attempts != 2 || len(retried) != 1 || retried[0] != 2 {
+		t.Fatalf("attempts = %d, retried = %v", attempts, retried)
+	}
+}
+
+func TestRetryRun_NonRetryable(t *testing.T) {
+	var attempts int
+	drift := fmt.Errorf("%w: 1:init", ErrDrift)
+	err := RetryRun(context.Background(), 3, time.Millisecond, func(context.Context) error {
+		attempts++
+		return drift
+	}, nil)
+	if !errors.Is(err, ErrDrift) || attempts != 1 {
+		t.Fatalf("drift must not be retried: attempts = %d, err = %v", attempts, err)
+	}
+
+	attempts = 0
+	err = RetryRun(context.Background(), 2, time.Millisecond, func(context.Context) error {
+		attempts++
+		return driver.ErrBadConn
+	}, nil)
+	if !errors.Is(err, driver.ErrBadConn) || attempts != 3 {
+		t.Fatalf("expected 3 attempts ending in the last error, got %d, %v", attempts, err)
+	}
+	if Retryable(&mysql.MySQLError{Number: 1064, Message: "syntax error"}) {
+		t.Fatal("a SQL syntax error must not be retryable")
+	}
+}
+
+func TestSignalContext_AbortsMigration(t *testing.T) {
+	proc, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	// the rollback may come from database/sql's own context watcher, so
+	// don't tie it to the order of the failure record
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE big").WillDelayFor(10 * time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").
+		WithArgs("1", "slow", sqlmock.AnyArg(), sqlmock.AnyArg(), "app", sqlmock.AnyArg(), "failed", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	ctx, stop := SignalContext(context.Background())
+	defer stop()
+	r := NewRunner(sqlDB, nil, "schema_migrations", "app")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if err := proc.Signal(os.Interrupt); err != nil {
+			t.Errorf("signal: %v", err)
+		}
+	}()
+	_, err = r.ApplyUp(ctx, []FilePair{{Version: "1", Name: "slow", UpBytes: []byte("UPDATE big SET x = 1")}}, false, nil)
+	if err == nil {
+		t.Fatal("expected the migration to be aborted")
+	}
+	if !Aborted(ctx) {
+		t.Fatalf("expected an abort by signal, cause %v", context.Cause(ctx))
+	}
+	// database/sql may still be rolling back in the background
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := mock.ExpectationsWereMet()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expectations: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAcquireLock_RetriesConnectionErrors(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	lk := NewLock(nil, "app", "schema_migrations")
+	mock.ExpectQuery("SELECT GET_LOCK").WillReturnError(mysql.ErrInvalidConn)
+	mock.ExpectQuery("SELECT GET_LOCK").WillReturnError(&mysql.MySQLError{Number: 1053, Message: "server shutdown in progress"})
+	mock.ExpectQuery("SELECT GET_LOCK").WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+
+	var waits []time.Duration
+	err = AcquireLock(context.Background(), lk, sqlDB, time.Second, 5, time.Millisecond, func(attempt int, wait time.Duration, err error) {
+		waits = append(waits, wait)
+	})
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if len(waits) != 2 || waits[0] != time.Millisecond || waits[1] != 2*time.Millisecond {
+		t.Fatalf("waits = %v, want exponential backoff", waits)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestConnectRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{syscall.ECONNREFUSED, true},
+		{&pq.Error{Code: "57P03"}, true}, // the database system is starting up
+		{&mysql.MySQLError{Number: 1045, Message: "Access denied"}, false},
+		{&pq.Error{Code: "28P01"}, false}, // password authentication failed
+		{&mysql.MySQLError{Number: 1049, Message: "Unknown database"}, false},
+	} {
+		if got := ConnectRetryable(tc.err); got != tc.want {
+			t.Errorf("ConnectRetryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+
+	var attempts int
+	err := retryConnect(context.Background(), 3, time.Millisecond, func() error {
+		attempts++
+		return &mysql.MySQLError{Number: 1045, Message: "Access denied"}
+	}, nil)
+	if err == nil || attempts != 1 {
+		t.Fatalf("auth errors must fail at once: attempts = %d, err = %v", attempts, err)
+	}
+
+	attempts = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = retryConnect(ctx, 10, time.Second, func() error {
+		attempts++
+		return syscall.ECONNREFUSED
+	}, nil)
+	if !errors.Is(err, syscall.ECONNREFUSED) || attempts != 1 {
+		t.Fatalf("a wait past the deadline must give up: attempts = %d, err = %v", attempts, err)
+	}
+}
+
+func TestPing_ClassifiesFailures(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	mock.ExpectPing().WillReturnError(&mysql.MySQLError{Number: 1045, Message: "Access denied for user 'app'"})
+	mock.ExpectPing().WillReturnError(syscall.ECONNREFUSED)
+	mock.ExpectPing()
+
+	err = Ping(context.Background(), sqlDB, time.Second)
+	if !errors.Is(err, ErrCannotConnect) || !strings.Contains(err.Error(), "credentials rejected") {
+		t.Fatalf("expected a credentials error, got %v", err)
+	}
+	err = Ping(context.Background(), sqlDB, time.Second)
+	if !errors.Is(err, ErrCannotConnect) || !errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), "credentials") {
+		t.Fatalf("expected a connection error, got %v", err)
+	}
+	if err := Ping(context.Background(), sqlDB, 0); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+}
+
+func TestNewLockForDSN(t *testing.T) {
+	lk, err := NewLockForDSN(nil, "u:p@unix(/var/run/mysqld/mysqld.sock)/app", "", "schema_migrations")
+	if err != nil || lk.Key() != "gomigratex:app:schema_migrations" {
+		t.Fatalf("socket DSN: %v, %v", lk, err)
+	}
+	if _, err := NewLockForDSN(nil, "u:p@tcp(h:3306)/", "", "schema_migrations"); err == nil {
+		t.Fatal("expected an error for a DSN without a database")
+	}
+
+	cfg := DefaultConfig()
+	cfg.DSN = "u:p@tcp(h:3306)/"
+	cfg.LockDBName = "tenant_a"
+	lk, err = NewLockConfig(cfg, nil)
+	if err != nil || lk.Key() != "gomigratex:tenant_a:schema_migrations" {
+		t.Fatalf("lock_db_name: %v, %v", lk, err)
+	}
+
+	cfg.LockScope = "per-database"
+	lk, err = NewLockConfig(cfg, nil)
+	if err != nil || lk.Key() != "gomigratex:tenant_a" {
+		t.Fatalf("lock_scope: %v, %v", lk, err)
+	}
+	cfg.LockScope = "global"
+	if _, err := NewLockConfig(cfg, nil); err == nil {
+		t.Fatal("expected an invalid lock_scope to be rejected")
+	}
+}
+
+func TestAcquireLockConfig_SkipIfLocked(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	lk := NewLock(nil, "app", "schema_migrations")
+	cfg := DefaultConfig()
+
+	// without skip_if_locked the lock is waited for up to lock_timeout_sec
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(lk.Key(), 30).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(0))
+	if err := AcquireLockConfig(context.Background(), cfg, lk, sqlDB, nil); !errors.Is(err, ErrNotAcquired) {
+		t.Fatalf("expected ErrNotAcquired, got %v", err)
+	}
+
+	cfg.SkipIfLocked = true
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(lk.Key(), 0).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(0))
+	if err := AcquireLockConfig(context.Background(), cfg, lk, sqlDB, nil); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestAcquireLockConfig_LockMode(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	lk := NewLock(nil, "app", "schema_migrations")
+	cfg := DefaultConfig()
+
+	cfg.LockMode = "nowait"
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(lk.Key(), 0).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(0))
+	if err := AcquireLockConfig(context.Background(), cfg, lk, sqlDB, nil); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+
+	cfg.LockMode = "skip"
+	if err := AcquireLockConfig(context.Background(), cfg, lk, sqlDB, nil); err != nil {
+		t.Fatalf("skip must not take the lock: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+
+	cfg.LockMode = "later"
+	if err := AcquireLockConfig(context.Background(), cfg, lk, sqlDB, nil); err == nil {
+		t.Fatal("expected an invalid lock_mode to be rejected")
+	}
+}
+
+func TestShadowConfig(t *testing.T) {
+	defer func(orig func(context.Context, string, bool, string, []FilePair) ([]Row, error)) { runShadow = orig }(runShadow)
+	var gotDSN, gotTable string
+	var gotCreate bool
+	runShadow = func(_ context.Context, dsn string, create bool, table string, files []FilePair) ([]Row, error) {
+		gotDSN, gotCreate, gotTable = dsn, create, table
+		return make([]Row, len(files)), nil
+	}
+
+	cfg := DefaultConfig()
+	files := []FilePair{{Version: "1", Name: "init"}}
+	if _, err := ShadowConfig(context.Background(), cfg, files); err == nil {
+		t.Fatal("expected an error without shadow_dsn")
+	}
+	cfg.ShadowDSN, cfg.ShadowCreate = "postgres://ci@shadow:5432/postgres", true
+	cfg.Schema = "ops"
+	rows, err := ShadowConfig(context.Background(), cfg, files)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("shadow: %v, %v", rows, err)
+	}
+	if gotDSN != cfg.ShadowDSN || !gotCreate || gotTable != `"ops"."schema_migrations"` {
+		t.Fatalf("shadow settings not passed: %q %v %q", gotDSN, gotCreate, gotTable)
+	}
+}
+
+func TestApplySeedsConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "010_roles.sql"), []byte("INSERT IGNORE INTO roles VALUES ('admin');"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	r := NewRunner(sqlDB, nil, "schema_migrations", "tester")
+
+	cfg := DefaultConfig()
+	if _, err := ApplySeedsConfig(context.Background(), cfg, r); err == nil {
+		t.Fatal("expected an error without seeds_dir")
+	}
+	cfg.SeedsDir, cfg.SeedsTable, cfg.SeedOnlyChanged = dir, "ref_seeds", true
+	seeds, err := cfg.LoadSeeds()
+	if err != nil || len(seeds) != 1 {
+		t.Fatalf("load: %v, %v", seeds, err)
+	}
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `ref_seeds`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT name, checksum, applied_at, applied_by, duration_ms FROM `ref_seeds`").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "checksum", "applied_at", "applied_by", "duration_ms"}).
+			AddRow("010_roles.sql", seeds[0].Checksum, time.Now(), "ci", int64(3)))
+	results, err := ApplySeedsConfig(context.Background(), cfg, r)
+	if err != nil || len(results) != 1 || results[0].Status != "unchanged" {
+		t.Fatalf("seed_only_changed not honoured: %+v, %v", results, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -1,11 +1,145 @@
 package checksum
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 )
 
+// Checksum modes.
+const (
+	ModeRaw        = "raw"
+	ModeNormalized = "normalized"
+)
+
+// DefaultAlgo is the algorithm of untagged checksums.
+const DefaultAlgo = "sha256"
+
 func SHA256(b []byte) string {
 	sum := sha256.Sum256(b)
 	return hex.EncodeToString(sum[:])
 }
+
+// SHA512 returns the hex SHA-512 of b, untagged.
+func SHA512(b []byte) string {
+	sum := sha512.Sum512(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Normalized is SHA256 after converting CRLF to LF and stripping trailing
+// spaces and tabs from every line, so a checkout with different line endings
+// or editor whitespace settings hashes the same.
+func Normalized(b []byte) string {
+	return SHA256(normalize(b))
+}
+
+func normalize(b []byte) []byte {
+	lines := bytes.Split(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n")), []byte("\n"))
+	for i, l := range lines {
+		lines[i] = bytes.TrimRight(l, " \t\r")
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// ForMode returns the checksum function for mode; empty means ModeRaw.
+func ForMode(mode string) (func([]byte) string, error) {
+	return For(mode, "")
+}
+
+// Hasher computes a hex checksum of b.
+type Hasher interface {
+	Sum(b []byte) string
+}
+
+// HasherFunc adapts a plain function to Hasher.
+type HasherFunc func(b []byte) string
+
+func (f HasherFunc) Sum(b []byte) string { return f(b) }
+
+var (
+	mu      sync.RWMutex
+	hashers = map[string]Hasher{
+		"sha256": HasherFunc(SHA256),
+		"sha512": HasherFunc(SHA512),
+	}
+)
+
+// Register makes h available as algorithm name, e.g. BLAKE3 from a
+// third-party package. Names must not contain ':'.
+func Register(name string, h Hasher) {
+	if name == "" || strings.Contains(name, ":") {
+		panic(fmt.Sprintf("checksum: invalid algorithm name %q", name))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	hashers[name] = h
+}
+
+// Lookup returns the registered algorithm name; empty means DefaultAlgo.
+func Lookup(name string) (Hasher, error) {
+	if name == "" {
+		name = DefaultAlgo
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	h, ok := hashers[name]
+	if !ok {
+		names := make([]string, 0, len(hashers))
+		for n := range hashers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown checksum algorithm %q: want one of %s", name, strings.Join(names, ", "))
+	}
+	return h, nil
+}
+
+// For returns the checksum function for mode and algo (empty: ModeRaw and
+// DefaultAlgo). Checksums from algorithms other than DefaultAlgo are tagged
+// "algo:hex" so a stored checksum records how it was computed; DefaultAlgo
+// checksums stay untagged, matching rows written before algorithms were
+// configurable.
+func For(mode, algo string) (func([]byte) string, error) {
+	if algo == "" {
+		algo = DefaultAlgo
+	}
+	h, err := Lookup(algo)
+	if err != nil {
+		return nil, err
+	}
+	var prep func([]byte) []byte
+	switch mode {
+	case "", ModeRaw:
+	case ModeNormalized:
+		prep = normalize
+	default:
+		return nil, fmt.Errorf("invalid checksum mode %q: want raw or normalized", mode)
+	}
+	return func(b []byte) string {
+		if prep != nil {
+			b = prep(b)
+		}
+		return Tag(algo, h.Sum(b))
+	}, nil
+}
+
+// Tag prefixes sum with algo unless algo is DefaultAlgo.
+func Tag(algo, sum string) string {
+	if algo == "" || algo == DefaultAlgo {
+		return sum
+	}
+	return algo + ":" + sum
+}
+
+// Algo returns the algorithm a stored checksum was computed with.
+func Algo(stored string) string {
+	if i := strings.IndexByte(stored, ':'); i > 0 {
+		return stored[:i]
+	}
+	return DefaultAlgo
+}
@@ -1,6 +1,9 @@
 package checksum
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestSHA256(t *testing.T) {
 	got := SHA256([]byte("abc"))
@@ -9,3 +12,61 @@ func TestSHA256(t *testing.T) {
 		t.Fatalf("SHA256 mismatch: got %s want %s", got, want)
 	}
 }
+
+func TestNormalized(t *testing.T) {
+	lf := []byte("CREATE TABLE t (\n  id INT\n);\n")
+	crlf := []byte("CREATE TABLE t (  \r\n  id INT\t\r\n);\r\n")
+	if Normalized(lf) != Normalized(crlf) {
+		t.Fatal("CRLF and LF must produce equal normalized checksums")
+	}
+	if SHA256(lf) == SHA256(crlf) {
+		t.Fatal("raw checksums must still differ")
+	}
+	if Normalized(lf) == Normalized([]byte("CREATE TABLE t (\n  id BIGINT\n);\n")) {
+		t.Fatal("content changes must still change the checksum")
+	}
+}
+
+func TestForMode(t *testing.T) {
+	for _, mode := range []string{"", ModeRaw, ModeNormalized} {
+		if _, err := ForMode(mode); err != nil {
+			t.Errorf("ForMode(%q): %v", mode, err)
+		}
+	}
+	if _, err := ForMode("crc32"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestForAlgo(t *testing.T) {
+	sha256Sum, err := For("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sha256Sum([]byte("abc")); got != SHA256([]byte("abc")) {
+		t.Fatalf("default algorithm must stay untagged: %s", got)
+	}
+	sha512Sum, err := For(ModeRaw, "sha512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sha512Sum([]byte("abc"))
+	if !strings.HasPrefix(got, "sha512:ddaf35a193617aba") || len(got) != len("sha512:")+128 {
+		t.Fatalf("unexpected sha512 checksum: %s", got)
+	}
+	if Algo(got) != "sha512" || Algo(SHA256([]byte("abc"))) != DefaultAlgo {
+		t.Fatal("Algo must read the tag")
+	}
+
+	Register("len", HasherFunc(func(b []byte) string { return strings.Repeat("0", len(b)) }))
+	custom, err := For(ModeNormalized, "len")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := custom([]byte("a  \r\n")); got != "len:00" {
+		t.Fatalf("registered hasher not used after normalizing: %s", got)
+	}
+	if _, err := For("", "md5"); err == nil {
+		t.Fatal("expected error for unknown algorithm")
+	}
+}
@@ -1,28 +1,131 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/fsutil"
+	"github.com/mirajehossain/gomigratex/internal/lock"
+	"github.com/mirajehossain/gomigratex/internal/logger"
+	"github.com/mirajehossain/gomigratex/internal/migrator"
+	"github.com/mirajehossain/gomigratex/internal/schemadiff"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	DSN             string `yaml:"dsn"`
-	Dir             string `yaml:"dir"`
-	Embedded        bool   `yaml:"embedded"`
-	JSON            bool   `yaml:"json"`
-	DryRun          bool   `yaml:"dry_run"`
-	LockTimeoutSec  int    `yaml:"lock_timeout_sec"`
-	MigrationsTable string `yaml:"migrations_table"`
-	AppliedBy       string `yaml:"applied_by"`
+	DSN                   string   `yaml:"dsn"`
+	DSNFile               string   `yaml:"dsn_file"`
+	Dir                   string   `yaml:"dir"`
+	UpDir                 string   `yaml:"up_dir"`
+	DownDir               string   `yaml:"down_dir"`
+	Embedded              bool     `yaml:"embedded"`
+	Ext                   string   `yaml:"ext"`
+	Dialect               string   `yaml:"dialect"`
+	Recursive             bool     `yaml:"recursive"`
+	CreateTemplate        string   `yaml:"create_template"`
+	CreateUpTemplate      string   `yaml:"create_up_template"`
+	CreateDownTemplate    string   `yaml:"create_down_template"`
+	ChecksumMode          string   `yaml:"checksum_mode"`
+	ChecksumAlgo          string   `yaml:"checksum_algo"`
+	JSON                  bool     `yaml:"json"`
+	LogLevel              string   `yaml:"log_level"`
+	DryRun                bool     `yaml:"dry_run"`
+	DryRunFormat          string   `yaml:"dry_run_format"`
+	LockTimeoutSec        int      `yaml:"lock_timeout_sec"`
+	LockDBName            string   `yaml:"lock_db_name"`
+	LockScope             string   `yaml:"lock_scope"`
+	LockMode              string   `yaml:"lock_mode"`
+	LockHeartbeatSec      int      `yaml:"lock_heartbeat_sec"`
+	SkipIfLocked          bool     `yaml:"skip_if_locked"`
+	MigrationsTable       string   `yaml:"migrations_table"`
+	Schema                string   `yaml:"schema"`
+	AppliedBy             string   `yaml:"applied_by"`
+	AppliedByMeta         bool     `yaml:"applied_by_meta"`
+	LockWaitTimeoutSec    int      `yaml:"lock_wait_timeout_sec"`
+	PauseBetweenSec       int      `yaml:"pause_between_sec"`
+	TotalBudgetSec        int      `yaml:"total_budget_sec"`
+	MaxReplicaLagSec      int      `yaml:"max_replica_lag_sec"`
+	ReplicaDSNs           []string `yaml:"replica_dsns"`
+	ShadowDSN             string   `yaml:"shadow_dsn"`
+	ShadowCreate          bool     `yaml:"shadow_create"`
+	ReplicaLagQuery       string   `yaml:"replica_lag_query"`
+	ReplicaWaitTimeoutSec int      `yaml:"replica_wait_timeout_sec"`
+	ConnectionInitSQL     []string `yaml:"connection_init_sql"`
+	MaxOpenConns          int      `yaml:"max_open_conns"`
+	MaxIdleConns          int      `yaml:"max_idle_conns"`
+	ConnMaxLifetimeSec    int      `yaml:"conn_max_lifetime_sec"`
+	StatementTimeoutSec   int      `yaml:"statement_timeout_sec"`
+	TimeoutSec            int      `yaml:"timeout_sec"`
+	TransactionIsolation  string   `yaml:"transaction_isolation"`
+	AnalyzeAfter          []string `yaml:"analyze_after"`
+	AnalyzeAllChanged     bool     `yaml:"analyze_all_changed"`
+	MaintenanceOnSQL      []string `yaml:"maintenance_on_sql"`
+	MaintenanceOffSQL     []string `yaml:"maintenance_off_sql"`
+	Strict                bool     `yaml:"strict"`
+	IgnoreFuture          bool     `yaml:"ignore_future"`
+	HealthCacheTTLSec     int      `yaml:"health_cache_ttl_sec"`
+	FailOnOrphan          bool     `yaml:"fail_on_orphan"`
+	IgnoreDrift           []string `yaml:"ignore_drift"`
+	FailedRetryAfterSec   int      `yaml:"failed_retry_after_sec"`
+	ContiguousOrder       bool     `yaml:"contiguous_execution_order"`
+	AllOrNothing          bool     `yaml:"all_or_nothing"`
+	PushgatewayURL        string   `yaml:"pushgateway_url"`
+	JobName               string   `yaml:"job_name"`
+	ApprovalURL           string   `yaml:"approval_url"`
+	Retries               int      `yaml:"retries"`
+	RetryDelaySec         int      `yaml:"retry_delay_sec"`
+	ConnectRetries        int      `yaml:"connect_retries"`
+	ConnectBackoffSec     int      `yaml:"connect_backoff_sec"`
+	SeedsDir              string   `yaml:"seeds_dir"`
+	SeedsTable            string   `yaml:"seeds_table"`
+	SeedOnlyChanged       bool     `yaml:"seed_only_changed"`
+
+	// AppliedByFromJWT takes applied_by from a claim of a JWT held in an
+	// environment variable; see ResolveAppliedBy.
+	AppliedByFromJWT JWTClaimSource `yaml:"applied_by_from_jwt"`
+
+	// SchemaDiff is the external tool used to generate a migration from the
+	// difference between an expected-schema file and the live database.
+	SchemaDiff SchemaDiffCommand `yaml:"schema_diff"`
+
+	// Params are bound to :name placeholders in migration SQL.
+	// MIGRATEX_PARAM_<NAME> environment variables override them.
+	Params map[string]string `yaml:"params"`
+
+	// Migrations are inline migrations merged with (or, without Dir, used
+	// instead of) file-based discovery.
+	Migrations []InlineMigration `yaml:"migrations"`
+}
+
+// SchemaDiffCommand configures schemadiff.Command: {schema} and {dsn} in
+// Args are replaced by the expected-schema file and the database DSN.
+type SchemaDiffCommand struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
+// InlineMigration is a migration given directly in the config file.
+type InlineMigration struct {
+	Version string `yaml:"version"`
+	Name    string `yaml:"name"`
+	Up      string `yaml:"up"`
+	Down    string `yaml:"down"`
 }
 
 func Default() *Config {
 	return &Config{
 		LockTimeoutSec:  30,
 		MigrationsTable: "schema_migrations",
+		SeedsTable:      "schema_seeds",
 	}
 }
 
@@ -38,13 +141,112 @@ func LoadYAML(path string) (*Config, error) {
 	if err := yaml.Unmarshal(b, cfg); err != nil {
 		return cfg, err
 	}
-	return cfg, nil
+	return cfg, cfg.Validate()
+}
+
+// Validate checks settings that are spliced into SQL, so a bad value fails
+// at load with the key it came from rather than as a syntax error from the
+// database. LoadYAML and LoadLayered call it on the files they read.
+// MergeEnv does not: a caller that applies MergeEnv or flags afterwards
+// must call Validate itself, as gomigratex.LoadConfig does.
+func (c *Config) Validate() error {
+	if err := db.ValidateTableName(c.MigrationsTable); err != nil {
+		return fmt.Errorf("migrations_table: %w", err)
+	}
+	if c.SeedsTable != "" {
+		if err := db.ValidateTableName(c.SeedsTable); err != nil {
+			return fmt.Errorf("seeds_table: %w", err)
+		}
+	}
+	if c.Schema != "" {
+		// with a schema the table must be a plain name
+		if _, err := c.TrackingTable(nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DirConfigName is the config file discovered in the working directory, its
+// ancestors, and alongside the migrations directory.
+const DirConfigName = ".gomigratex.yaml"
+
+// LoadLayered builds a config from discovered files. Later layers override
+// keys set by earlier ones:
+//
+//  1. .gomigratex.yaml in the working directory or its nearest ancestors
+//  2. .gomigratex.yaml inside the migrations directory (dir, or the dir
+//     resolved by layer 1 when empty)
+//  3. the explicit config file, if any
+//
+// Environment variables (MergeEnv) and CLI flags are applied on top by the caller.
+func LoadLayered(explicit, dir string) (*Config, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return Default(), err
+	}
+	return loadLayered(cwd, explicit, dir)
 }
 
+func loadLayered(cwd, explicit, dir string) (*Config, error) {
+	cfg := Default()
+	var ancestors []string
+	for d := cwd; ; d = filepath.Dir(d) {
+		ancestors = append(ancestors, filepath.Join(d, DirConfigName))
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	// farthest ancestor first so the nearest file wins
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		if err := overlayYAML(cfg, ancestors[i], false); err != nil {
+			return cfg, err
+		}
+	}
+	if dir == "" {
+		dir = cfg.Dir
+	}
+	if dir != "" {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+		p := filepath.Join(dir, DirConfigName)
+		if p != filepath.Join(cwd, DirConfigName) {
+			if err := overlayYAML(cfg, p, false); err != nil {
+				return cfg, err
+			}
+		}
+	}
+	if explicit != "" {
+		if err := overlayYAML(cfg, explicit, true); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// overlayYAML unmarshals path onto cfg, leaving keys absent from the file
+// untouched. Missing files are ignored unless required.
+func overlayYAML(cfg *Config, path string, required bool) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return yaml.Unmarshal(b, cfg)
+}
+
+// MergeEnv applies environment overrides to cfg. It doesn't validate the
+// result; call Validate afterwards.
 func MergeEnv(cfg *Config) *Config {
 	if v := os.Getenv("DB_DSN"); v != "" {
 		cfg.DSN = v
 	}
+	if v := os.Getenv("DB_DSN_FILE"); v != "" {
+		cfg.DSNFile = v
+	}
 	if v := os.Getenv("MIGRATIONS_DIR"); v != "" {
 		cfg.Dir = v
 	}
@@ -53,18 +255,274 @@ func MergeEnv(cfg *Config) *Config {
 			cfg.LockTimeoutSec = i
 		}
 	}
+	if v := os.Getenv("LOCK_MODE"); v != "" {
+		cfg.LockMode = v
+	}
 	if v := os.Getenv("MIGRATIONS_TABLE"); v != "" {
 		cfg.MigrationsTable = v
 	}
 	if v := os.Getenv("APPLIED_BY"); v != "" {
 		cfg.AppliedBy = v
 	}
+	if v := os.Getenv("LOCK_WAIT_TIMEOUT_SEC"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			cfg.LockWaitTimeoutSec = i
+		}
+	}
+	for name, dst := range map[string]*int{
+		"MAX_OPEN_CONNS":        &cfg.MaxOpenConns,
+		"MAX_IDLE_CONNS":        &cfg.MaxIdleConns,
+		"CONN_MAX_LIFETIME_SEC": &cfg.ConnMaxLifetimeSec,
+		"STATEMENT_TIMEOUT_SEC": &cfg.StatementTimeoutSec,
+	} {
+		if v := os.Getenv(name); v != "" {
+			if i, err := strconv.Atoi(v); err == nil {
+				*dst = i
+			}
+		}
+	}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutPrefix(k, paramEnvPrefix)
+		if !ok || name == "" {
+			continue
+		}
+		if cfg.Params == nil {
+			cfg.Params = map[string]string{}
+		}
+		cfg.Params[strings.ToLower(name)] = v
+	}
 	return cfg
 }
 
+// paramEnvPrefix marks environment variables that set Params; the rest of the
+// name, lowercased, is the parameter name.
+const paramEnvPrefix = "MIGRATEX_PARAM_"
+
+// LockWaitTimeout returns the per-migration session lock wait timeout, or 0
+// when unset.
+func (c *Config) LockWaitTimeout() time.Duration {
+	if c.LockWaitTimeoutSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.LockWaitTimeoutSec) * time.Second
+}
+
+// PauseBetween returns the delay between successful migrations, or 0 when unset.
+func (c *Config) PauseBetween() time.Duration {
+	if c.PauseBetweenSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.PauseBetweenSec) * time.Second
+}
+
+// TotalBudget returns the time budget for an up batch, or 0 when unset.
+func (c *Config) TotalBudget() time.Duration {
+	if c.TotalBudgetSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.TotalBudgetSec) * time.Second
+}
+
+// FailedRetryAfter returns how long a failed migration cools down before it
+// is retried, or 0 to retry immediately.
+func (c *Config) FailedRetryAfter() time.Duration {
+	if c.FailedRetryAfterSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.FailedRetryAfterSec) * time.Second
+}
+
+// RetryDelay returns the wait between whole-run retries, or 0 when unset.
+func (c *Config) RetryDelay() time.Duration {
+	if c.RetryDelaySec <= 0 {
+		return 0
+	}
+	return time.Duration(c.RetryDelaySec) * time.Second
+}
+
+// ConnectBackoff returns the first wait between connection attempts, or 0
+// when unset.
+func (c *Config) ConnectBackoff() time.Duration {
+	if c.ConnectBackoffSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.ConnectBackoffSec) * time.Second
+}
+
+// LockHeartbeat returns how often the lock connection is kept alive and
+// checked, or 0 when disabled.
+func (c *Config) LockHeartbeat() time.Duration {
+	if c.LockHeartbeatSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.LockHeartbeatSec) * time.Second
+}
+
+// StatementTimeout bounds each migration's execution, or 0 for no limit.
+func (c *Config) StatementTimeout() time.Duration {
+	if c.StatementTimeoutSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.StatementTimeoutSec) * time.Second
+}
+
+// Timeout bounds a whole run, from connecting to the last migration, or 0
+// for no limit.
+func (c *Config) Timeout() time.Duration {
+	if c.TimeoutSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.TimeoutSec) * time.Second
+}
+
+// TrackingTable returns MigrationsTable qualified with Schema and quoted for
+// d, validated as identifiers; see db.QualifiedTable.
+func (c *Config) TrackingTable(d db.Driver) (string, error) {
+	return db.QualifiedTable(d, c.Schema, c.MigrationsTable)
+}
+
+// DBOptions returns the pool and connection settings for db.OpenWith.
+func (c *Config) DBOptions() db.Options {
+	return db.Options{
+		InitSQL:         c.ConnectionInitSQL,
+		MaxOpenConns:    c.MaxOpenConns,
+		MaxIdleConns:    c.MaxIdleConns,
+		ConnMaxLifetime: time.Duration(c.ConnMaxLifetimeSec) * time.Second,
+	}
+}
+
 func (c *Config) LockTimeout() time.Duration {
 	if c.LockTimeoutSec <= 0 {
 		return 30 * time.Second
 	}
 	return time.Duration(c.LockTimeoutSec) * time.Second
 }
+
+// Logger returns a logger writing plain text, or JSON when json is set, at
+// log_level.
+func (c *Config) Logger() (*logger.Logger, error) {
+	level, err := logger.ParseLevel(c.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("log_level: %w", err)
+	}
+	l := logger.New(c.JSON)
+	l.SetLevel(level)
+	return l, nil
+}
+
+// DryRunOutput returns dry_run_format as the format for Runner.WriteDryRun.
+func (c *Config) DryRunOutput() (migrator.DryRunFormat, error) {
+	f, err := migrator.ParseDryRunFormat(c.DryRunFormat)
+	if err != nil {
+		return "", fmt.Errorf("dry_run_format: %w", err)
+	}
+	return f, nil
+}
+
+// LockKey returns the advisory lock key for the migrations table in the
+// database the DSN names, or in lock_db_name when set, under lock_scope;
+// see lock.KeyForDSNScope.
+func (c *Config) LockKey() (string, error) {
+	scope, err := lock.ParseScope(c.LockScope)
+	if err != nil {
+		return "", fmt.Errorf("lock_scope: %w", err)
+	}
+	dsn, err := c.ResolveDSN()
+	if err != nil {
+		return "", err
+	}
+	return lock.KeyForDSNScope(scope, dsn, c.LockDBName, c.MigrationsTable)
+}
+
+// HealthCacheTTL returns health_cache_ttl_sec as the health.Server CacheTTL,
+// or 0 (plan on every request) if unset.
+func (c *Config) HealthCacheTTL() time.Duration {
+	if c.HealthCacheTTLSec <= 0 {
+		return 0
+	}
+	return time.Duration(c.HealthCacheTTLSec) * time.Second
+}
+
+// Pushgateway returns the Pushgateway target for runs against database, or
+// nil if pushgateway_url is unset.
+func (c *Config) Pushgateway(database string) *migrator.Pushgateway {
+	if c.PushgatewayURL == "" {
+		return nil
+	}
+	return &migrator.Pushgateway{URL: c.PushgatewayURL, Job: c.JobName, Database: database, Table: c.MigrationsTable}
+}
+
+// CreateTemplates loads create_template, create_up_template and
+// create_down_template for fsutil.CreatePairFrom.
+func (c *Config) CreateTemplates() (fsutil.Templates, error) {
+	return fsutil.LoadTemplates(c.CreateTemplate, c.CreateUpTemplate, c.CreateDownTemplate)
+}
+
+// DiffCommand returns the schema_diff tool as a schemadiff.Command.
+func (c *Config) DiffCommand() schemadiff.Command {
+	return schemadiff.Command{Path: c.SchemaDiff.Command, Args: c.SchemaDiff.Args}
+}
+
+// WarningCounter counts the warnings a run has logged; *logger.Logger is
+// one.
+type WarningCounter interface {
+	Warnings() int
+}
+
+// CheckStrict returns logger.ErrStrictWarnings if strict is set and w
+// counted any warning.
+func (c *Config) CheckStrict(w WarningCounter) error {
+	if n := w.Warnings(); c.Strict && n > 0 {
+		return fmt.Errorf("%w: %d warning(s)", logger.ErrStrictWarnings, n)
+	}
+	return nil
+}
+
+// ChecksumFunc returns the checksum function selected by ChecksumMode and
+// ChecksumAlgo.
+func (c *Config) ChecksumFunc() (func([]byte) string, error) {
+	return checksum.For(c.ChecksumMode, c.ChecksumAlgo)
+}
+
+// LoadSeeds reads the seeds in seeds_dir, checksummed as checksum_mode
+// and checksum_algo say, like migrations.
+func (c *Config) LoadSeeds() ([]migrator.Seed, error) {
+	if c.SeedsDir == "" {
+		return nil, errors.New("seeds_dir is not set")
+	}
+	sum, err := c.ChecksumFunc()
+	if err != nil {
+		return nil, err
+	}
+	return migrator.LoadSeedsWith(c.SeedsDir, sum)
+}
+
+// Dump renders the effective config as "yaml" or "json" for debugging
+// precedence, with passwords in the DSN and replica DSNs redacted. Keys use
+// the YAML names in both formats.
+func (c *Config) Dump(format string) ([]byte, error) {
+	red := *c
+	red.DSN = db.RedactDSN(c.DSN)
+	red.ShadowDSN = db.RedactDSN(c.ShadowDSN)
+	red.ReplicaDSNs = make([]string, len(c.ReplicaDSNs))
+	for i, dsn := range c.ReplicaDSNs {
+		red.ReplicaDSNs[i] = db.RedactDSN(dsn)
+	}
+	b, err := yaml.Marshal(&red)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "", "yaml":
+		return b, nil
+	case "json":
+		var m map[string]any
+		if err := yaml.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(m, "", "  ")
+	default:
+		return nil, fmt.Errorf("unknown config dump format %q", format)
+	}
+}
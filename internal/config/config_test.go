@@ -1,10 +1,22 @@
 package config
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/logger"
+	"github.com/mirajehossain/gomigratex/internal/migrator"
 )
 
 func TestDefaultAndLockTimeout(t *testing.T) {
@@ -39,3 +51,438 @@ func TestLoadYAMLAndMergeEnv(t *testing.T) {
 		t.Fatal("env merge mismatch")
 	}
 }
+
+func TestLoadLayered(t *testing.T) {
+	root := t.TempDir()
+	cwd := filepath.Join(root, "svc")
+	migs := filepath.Join(cwd, "db", "migrations")
+	if err := os.MkdirAll(migs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(p, body string) {
+		t.Helper()
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(root, DirConfigName), "migrations_table: root_table\nlock_timeout_sec: 5\napplied_by: root\n")
+	write(filepath.Join(cwd, DirConfigName), "dir: db/migrations\napplied_by: cwd\n")
+	write(filepath.Join(migs, DirConfigName), "migrations_table: svc_migrations\n")
+
+	cfg, err := loadLayered(cwd, "", "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.MigrationsTable != "svc_migrations" {
+		t.Fatalf("dir-local config should win, got %q", cfg.MigrationsTable)
+	}
+	if cfg.AppliedBy != "cwd" || cfg.LockTimeoutSec != 5 {
+		t.Fatalf("ancestor layering mismatch: %+v", cfg)
+	}
+
+	explicit := filepath.Join(root, "explicit.yaml")
+	write(explicit, "migrations_table: explicit_table\n")
+	cfg, err = loadLayered(cwd, explicit, "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.MigrationsTable != "explicit_table" {
+		t.Fatalf("explicit config should win, got %q", cfg.MigrationsTable)
+	}
+
+	if _, err := loadLayered(cwd, filepath.Join(root, "missing.yaml"), ""); err == nil {
+		t.Fatal("expected error for missing explicit config")
+	}
+}
+
+func TestLoadYAMLInlineMigrations(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "cfg.yaml")
+	body := "migrations:\n  - version: \"20250101000000\"\n    name: init\n    up: CREATE TABLE t(id INT);\n    down: DROP TABLE t;\n"
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadYAML(p)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.Migrations) != 1 || cfg.Migrations[0].Name != "init" || cfg.Migrations[0].Down != "DROP TABLE t;" {
+		t.Fatalf("unexpected inline migrations: %+v", cfg.Migrations)
+	}
+}
+
+func TestDump(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, DirConfigName), []byte("dsn: \"u:secret@tcp(db:3306)/app\"\nmigrations_table: layered\napplied_by: file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadLayered(root, "", "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for _, k := range []string{"DB_DSN", "MIGRATIONS_DIR", "LOCK_TIMEOUT_SEC", "MIGRATIONS_TABLE", "LOCK_WAIT_TIMEOUT_SEC"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("APPLIED_BY", "env")
+	cfg = MergeEnv(cfg)
+
+	y, err := cfg.Dump("yaml")
+	if err != nil {
+		t.Fatalf("dump yaml: %v", err)
+	}
+	if strings.Contains(string(y), "secret") || !strings.Contains(string(y), "u:****@tcp(db:3306)/app") {
+		t.Fatalf("dsn not redacted:\n%s", y)
+	}
+
+	j, err := cfg.Dump("json")
+	if err != nil {
+		t.Fatalf("dump json: %v", err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(j, &m); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if m["migrations_table"] != "layered" || m["applied_by"] != "env" || m["lock_timeout_sec"] != float64(30) {
+		t.Fatalf("dump does not reflect merge order: %v", m)
+	}
+	if cfg.DSN != "u:secret@tcp(db:3306)/app" {
+		t.Fatal("Dump must not modify the config")
+	}
+}
+
+func TestMergeEnvParams(t *testing.T) {
+	t.Setenv("MIGRATEX_PARAM_TENANT_ID", "t-42")
+	cfg := Default()
+	cfg.Params = map[string]string{"tenant_id": "file", "region": "eu"}
+	cfg = MergeEnv(cfg)
+	if cfg.Params["tenant_id"] != "t-42" || cfg.Params["region"] != "eu" {
+		t.Fatalf("unexpected params: %v", cfg.Params)
+	}
+}
+
+func TestMergeEnvPoolAndStatementTimeout(t *testing.T) {
+	t.Setenv("MAX_OPEN_CONNS", "1")
+	t.Setenv("CONN_MAX_LIFETIME_SEC", "3600")
+	t.Setenv("STATEMENT_TIMEOUT_SEC", "90")
+	cfg := Default()
+	cfg.MaxIdleConns = 1
+	cfg = MergeEnv(cfg)
+	o := cfg.DBOptions()
+	if o.MaxOpenConns != 1 || o.MaxIdleConns != 1 || o.ConnMaxLifetime != time.Hour {
+		t.Fatalf("unexpected pool options: %+v", o)
+	}
+	if cfg.StatementTimeout() != 90*time.Second {
+		t.Fatalf("statement timeout = %v", cfg.StatementTimeout())
+	}
+}
+
+func TestTrackingTable(t *testing.T) {
+	cfg := Default()
+	if got, err := cfg.TrackingTable(db.MySQL); err != nil || got != "schema_migrations" {
+		t.Fatalf("unqualified: %q, %v", got, err)
+	}
+	cfg.Schema = "ops"
+	if got, err := cfg.TrackingTable(db.MySQL); err != nil || got != "`ops`.`schema_migrations`" {
+		t.Fatalf("qualified: %q, %v", got, err)
+	}
+	cfg.Schema = "ops`; DROP DATABASE x; --"
+	if _, err := cfg.TrackingTable(db.MySQL); err == nil {
+		t.Fatal("expected an invalid schema to be rejected")
+	}
+}
+
+func TestValidateTableName(t *testing.T) {
+	dir := t.TempDir()
+	for table, ok := range map[string]bool{
+		"schema_migrations":                   true,
+		"ops.schema_migrations":               true,
+		"`ops`.`schema_migrations`":           true,
+		"schema_migrations; DROP TABLE users": false,
+		"a.b.c":                               false,
+		"1migrations":                         false,
+		"":                                    false,
+	} {
+		p := filepath.Join(dir, "cfg.yaml")
+		if err := os.WriteFile(p, []byte("migrations_table: '"+table+"'\n"), 0o644); err != nil {
+			t.Fatalf("write yaml: %v", err)
+		}
+		_, err := LoadYAML(p)
+		if ok && err != nil {
+			t.Errorf("%q: unexpected error %v", table, err)
+		}
+		if !ok && (err == nil || !strings.Contains(err.Error(), "migrations_table")) {
+			t.Errorf("%q: expected a migrations_table error, got %v", table, err)
+		}
+	}
+	cfg := Default()
+	cfg.Schema = "ops"
+	cfg.MigrationsTable = "other.schema_migrations"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected a schema-qualified table with schema set to be rejected")
+	}
+}
+
+func TestResolveDSN(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "dsn")
+	if err := os.WriteFile(p, []byte("  u:fromfile@tcp(db:3306)/app\n"), 0o600); err != nil {
+		t.Fatalf("write dsn: %v", err)
+	}
+	t.Setenv("DB_DSN", "u:fromenv@tcp(db:3306)/app")
+	cfg := MergeEnv(Default())
+	if got, err := cfg.ResolveDSN(); err != nil || got != "u:fromenv@tcp(db:3306)/app" {
+		t.Fatalf("env dsn: %q, %v", got, err)
+	}
+	t.Setenv("DB_DSN_FILE", p)
+	cfg = MergeEnv(Default())
+	if got, err := cfg.ResolveDSN(); err != nil || got != "u:fromfile@tcp(db:3306)/app" {
+		t.Fatalf("dsn file must win over DB_DSN: %q, %v", got, err)
+	}
+
+	cfg = Default()
+	for _, dsn := range []string{"file:app.db?_pragma=busy_timeout(5000)", "file://" + p} {
+		cfg.DSN = dsn
+		if got, err := cfg.ResolveDSN(); err != nil || got != dsn {
+			t.Fatalf("sqlite dsn must pass through unchanged: %q, %v", got, err)
+		}
+	}
+	cfg.DSNFile = filepath.Join(dir, "missing")
+	if _, err := cfg.ResolveDSN(); err == nil {
+		t.Fatal("expected an error for a missing dsn file")
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatalf("write empty: %v", err)
+	}
+	cfg = Default()
+	cfg.DSNFile = empty
+	if _, err := cfg.ResolveDSN(); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Fatalf("expected an empty dsn file to be rejected, got %v", err)
+	}
+}
+
+func TestPlanOptions(t *testing.T) {
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	inline := []InlineMigration{
+		{Version: "20250101000000", Name: "init", Up: "CREATE TABLE a (id INT);"},
+		{Version: "20990101000000", Name: "later", Up: "CREATE TABLE b (id INT);"},
+	}
+	cases := []struct {
+		name  string
+		set   func(c *Config)
+		rows  func(r *sqlmock.Rows)
+		check func(t *testing.T, p *migrator.Plan, err error)
+	}{
+		{
+			name: "ignore_future",
+			set:  func(c *Config) { c.IgnoreFuture = true },
+			check: func(t *testing.T, p *migrator.Plan, err error) {
+				if err != nil || len(p.Future) != 1 || p.Future[0].Name != "later" {
+					t.Fatalf("future migration not held back: %+v, %v", p, err)
+				}
+			},
+		},
+		{
+			name: "fail_on_orphan",
+			set:  func(c *Config) { c.FailOnOrphan = true },
+			rows: func(r *sqlmock.Rows) {
+				r.AddRow("20240101000000", "gone", "x", now, "t", int64(1), "success", int64(1), "v1", nil)
+			},
+			check: func(t *testing.T, p *migrator.Plan, err error) {
+				if !errors.Is(err, migrator.ErrOrphaned) {
+					t.Fatalf("expected ErrOrphaned, got %v", err)
+				}
+			},
+		},
+		{
+			name: "failed_retry_after_sec",
+			set:  func(c *Config) { c.FailedRetryAfterSec = 3600 },
+			rows: func(r *sqlmock.Rows) {
+				r.AddRow("20250101000000", "init", "x", time.Now(), "t", int64(1), "failed", int64(1), "v1", nil)
+			},
+			check: func(t *testing.T, p *migrator.Plan, err error) {
+				if err != nil || len(p.CoolingDown) != 1 {
+					t.Fatalf("failed migration not cooling down: %+v, %v", p, err)
+				}
+			},
+		},
+		{
+			name: "ignore_drift",
+			set:  func(c *Config) { c.IgnoreDrift = []string{"20250101000000:init"} },
+			rows: func(r *sqlmock.Rows) {
+				r.AddRow("20250101000000", "init", "stale", now, "t", int64(1), "success", int64(1), "v1", nil)
+			},
+			check: func(t *testing.T, p *migrator.Plan, err error) {
+				if err != nil || len(p.DriftIgnored) != 1 {
+					t.Fatalf("drift not ignored: %+v, %v", p, err)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqldb, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer sqldb.Close()
+			rows := sqlmock.NewRows(columns)
+			if tc.rows != nil {
+				tc.rows(rows)
+			}
+			mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(rows)
+
+			cfg := Default()
+			cfg.Migrations = inline
+			tc.set(cfg)
+			src, err := cfg.FileSource()
+			if err != nil {
+				t.Fatalf("file source: %v", err)
+			}
+			st := &migrator.Storage{DB: sqldb, Table: cfg.MigrationsTable}
+			plan, err := migrator.DiscoverAndPlan(context.Background(), src, st, cfg.PlanOptions(now)...)
+			tc.check(t, plan, err)
+		})
+	}
+	if opts := Default().PlanOptions(now); len(opts) != 0 {
+		t.Fatalf("defaults select %d plan options, want none", len(opts))
+	}
+}
+
+type warningCount int
+
+func (n warningCount) Warnings() int { return int(n) }
+
+func TestRunSettings(t *testing.T) {
+	cfg := Default()
+	if cfg.HealthCacheTTL() != 0 || cfg.Pushgateway("app") != nil {
+		t.Fatal("health cache and pushgateway must be off by default")
+	}
+	cfg.HealthCacheTTLSec = 15
+	if cfg.HealthCacheTTL() != 15*time.Second {
+		t.Fatalf("health cache ttl = %s", cfg.HealthCacheTTL())
+	}
+
+	cfg.PushgatewayURL, cfg.JobName = "http://pushgateway:9091", "migrate"
+	pg := cfg.Pushgateway("app")
+	if pg == nil || pg.URL != cfg.PushgatewayURL || pg.Job != "migrate" || pg.Database != "app" || pg.Table != "schema_migrations" {
+		t.Fatalf("pushgateway: %+v", pg)
+	}
+
+	cfg.SchemaDiff = SchemaDiffCommand{Command: "./diff.sh", Args: []string{"{schema}", "{dsn}"}}
+	if cmd := cfg.DiffCommand(); cmd.Path != "./diff.sh" || strings.Join(cmd.Args, " ") != "{schema} {dsn}" {
+		t.Fatalf("diff command: %+v", cmd)
+	}
+
+	l := logger.NewWithHandler(slog.NewTextHandler(io.Discard, nil))
+	l.Warn("table name case mismatch", nil)
+	if err := cfg.CheckStrict(l); err != nil {
+		t.Fatalf("warnings must not fail without strict: %v", err)
+	}
+	cfg.Strict = true
+	if err := cfg.CheckStrict(l); !errors.Is(err, logger.ErrStrictWarnings) {
+		t.Fatalf("expected ErrStrictWarnings, got %v", err)
+	}
+	if err := cfg.CheckStrict(warningCount(0)); err != nil {
+		t.Fatalf("no warnings must pass under strict: %v", err)
+	}
+	if err := cfg.CheckStrict(warningCount(2)); !errors.Is(err, logger.ErrStrictWarnings) {
+		t.Fatalf("expected ErrStrictWarnings from a custom counter, got %v", err)
+	}
+
+	if f, err := cfg.DryRunOutput(); err != nil || f != migrator.DryRunText {
+		t.Fatalf("dry run format default: %q, %v", f, err)
+	}
+	cfg.DryRunFormat = "sql"
+	if f, err := cfg.DryRunOutput(); err != nil || f != migrator.DryRunSQL {
+		t.Fatalf("dry run format: %q, %v", f, err)
+	}
+	cfg.DryRunFormat = "yaml"
+	if _, err := cfg.DryRunOutput(); err == nil {
+		t.Fatal("expected an error for an unknown dry_run_format")
+	}
+
+	dir := t.TempDir()
+	shared, down := filepath.Join(dir, "shared.tmpl"), filepath.Join(dir, "down.tmpl")
+	if err := os.WriteFile(shared, []byte("-- {{.Name}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(down, []byte("-- revert {{.Name}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.CreateTemplate, cfg.CreateDownTemplate = shared, down
+	if tmpl, err := cfg.CreateTemplates(); err != nil || tmpl.Up != "-- {{.Name}}\n" || tmpl.Down != "-- revert {{.Name}}\n" {
+		t.Fatalf("create templates: %+v, %v", tmpl, err)
+	}
+
+	cfg.LogLevel = "warn"
+	if l, err := cfg.Logger(); err != nil || l == nil {
+		t.Fatalf("logger: %v", err)
+	}
+	cfg.LogLevel = "trace"
+	if _, err := cfg.Logger(); err == nil {
+		t.Fatal("expected an error for an unknown log_level")
+	}
+}
+
+func TestApplyTo(t *testing.T) {
+	r := migrator.NewRunner(nil, "schema_migrations", "t")
+	if err := Default().ApplyTo(r); err != nil || r.ReplicaLag != nil || r.PauseBetween != 0 {
+		t.Fatalf("defaults must leave the runner alone: %+v, %v", r, err)
+	}
+
+	cfg := Default()
+	cfg.LockWaitTimeoutSec, cfg.StatementTimeoutSec, cfg.PauseBetweenSec, cfg.TotalBudgetSec = 5, 60, 2, 600
+	cfg.MaxReplicaLagSec = 3
+	cfg.ReplicaDSNs = []string{"u:p@tcp(replica-1:3306)/app", "u:p@tcp(replica-2:3306)/app"}
+	cfg.ReplicaLagQuery = "SELECT lag FROM replica_status"
+	cfg.ReplicaWaitTimeoutSec = 120
+	cfg.AnalyzeAfter, cfg.AnalyzeAllChanged = []string{"users"}, true
+	cfg.MaintenanceOnSQL, cfg.MaintenanceOffSQL = []string{"UPDATE flags SET m = 1"}, []string{"UPDATE flags SET m = 0"}
+	cfg.ApprovalURL = "https://deploys.example.com/approve"
+	cfg.ContiguousOrder = true
+	cfg.TransactionIsolation = "serializable"
+	cfg.AllOrNothing = true
+	cfg.AppliedByMeta = true
+	if err := cfg.ApplyTo(r); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	defer r.ReplicaLag.Close()
+	if r.LockWaitTimeout != 5*time.Second || r.StatementTimeout != time.Minute || r.PauseBetween != 2*time.Second || r.TotalBudget != 10*time.Minute {
+		t.Fatalf("durations not applied: %+v", r)
+	}
+	w := r.ReplicaLag
+	if len(w.Replicas) != 2 || w.MaxLag != 3*time.Second || w.Query != cfg.ReplicaLagQuery || w.Timeout != 2*time.Minute {
+		t.Fatalf("replica lag wait: %+v", w)
+	}
+	if len(r.AnalyzeAfter) != 1 || r.AnalyzeAfter[0] != "users" || !r.AnalyzeAllChanged {
+		t.Fatalf("analyze settings not applied: %v %v", r.AnalyzeAfter, r.AnalyzeAllChanged)
+	}
+	if len(r.MaintenanceOnSQL) != 1 || len(r.MaintenanceOffSQL) != 1 {
+		t.Fatalf("maintenance statements not applied: %q %q", r.MaintenanceOnSQL, r.MaintenanceOffSQL)
+	}
+	if a, ok := r.Approver.(*migrator.HTTPApprover); !ok || a.URL != cfg.ApprovalURL {
+		t.Fatalf("approver: %#v", r.Approver)
+	}
+	if !r.ContiguousOrder {
+		t.Fatal("contiguous_execution_order not applied")
+	}
+	if r.Isolation != sql.LevelSerializable {
+		t.Fatalf("isolation = %v", r.Isolation)
+	}
+	if !r.AllOrNothing {
+		t.Fatal("all_or_nothing not applied")
+	}
+	if !r.AppliedByMeta {
+		t.Fatal("applied_by_meta not applied")
+	}
+
+	cfg.ReplicaDSNs = nil
+	if err := cfg.ApplyTo(migrator.NewRunner(nil, "schema_migrations", "t")); err == nil {
+		t.Fatal("expected an error for max_replica_lag_sec without replica_dsns")
+	}
+	cfg.MaxReplicaLagSec, cfg.TransactionIsolation = 0, "snapshot"
+	if err := cfg.ApplyTo(migrator.NewRunner(nil, "schema_migrations", "t")); err == nil {
+		t.Fatal("expected an error for an unknown transaction_isolation")
+	}
+}
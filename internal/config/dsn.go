@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ResolveDSN returns the DSN to connect with, keeping credentials off the
+// command line and out of process listings. DSNFile (dsn_file,
+// DB_DSN_FILE) wins over DSN (dsn, DB_DSN). DSN itself is never read as a
+// path: a file: DSN is a SQLite database. Files are trimmed of surrounding
+// whitespace. A --dsn flag should set DSN and clear DSNFile so
+// it takes precedence over both.
+func (c *Config) ResolveDSN() (string, error) {
+	if c.DSNFile != "" {
+		return readDSNFile(c.DSNFile)
+	}
+	return c.DSN, nil
+}
+
+func readDSNFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read dsn file: %w", err)
+	}
+	dsn := strings.TrimSpace(string(b))
+	if dsn == "" {
+		return "", fmt.Errorf("dsn file %s is empty", path)
+	}
+	return dsn, nil
+}
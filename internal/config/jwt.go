@@ -0,0 +1,55 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// JWTClaimSource names an environment variable holding a JWT and the claim
+// to read from it.
+type JWTClaimSource struct {
+	Env   string `yaml:"env"`
+	Claim string `yaml:"claim"`
+}
+
+// ClaimFromJWT decodes token's payload and returns claim as a string. The
+// signature is not verified: the value is only used for attribution.
+func ClaimFromJWT(token, claim string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed jwt")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("decode jwt payload: %w", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("parse jwt payload: %w", err)
+	}
+	s, ok := claims[claim].(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("jwt claim %q missing or not a string", claim)
+	}
+	return s, nil
+}
+
+// ResolveAppliedBy returns the identity to record as applied_by. When
+// applied_by_from_jwt is configured and its token yields the claim, that
+// wins; otherwise it falls back to AppliedBy (config, APPLIED_BY), which may
+// be empty to let the runner use the OS username.
+func (c *Config) ResolveAppliedBy() string {
+	src := c.AppliedByFromJWT
+	if src.Env != "" && src.Claim != "" {
+		if tok := os.Getenv(src.Env); tok != "" {
+			if v, err := ClaimFromJWT(tok, src.Claim); err == nil {
+				return v
+			}
+		}
+	}
+	return c.AppliedBy
+}
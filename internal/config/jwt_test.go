@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func testJWT(payload string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc([]byte(payload)) + ".sig"
+}
+
+func TestClaimFromJWT(t *testing.T) {
+	tok := testJWT(`{"sub":"svc-deployer","email":"ci@example.com","iat":1700000000}`)
+	if v, err := ClaimFromJWT(tok, "email"); err != nil || v != "ci@example.com" {
+		t.Fatalf("email: %q %v", v, err)
+	}
+	if v, err := ClaimFromJWT(tok, "sub"); err != nil || v != "svc-deployer" {
+		t.Fatalf("sub: %q %v", v, err)
+	}
+	if _, err := ClaimFromJWT(tok, "iat"); err == nil {
+		t.Fatal("expected error for non-string claim")
+	}
+	if _, err := ClaimFromJWT("not-a-token", "sub"); err == nil {
+		t.Fatal("expected error for malformed token")
+	}
+}
+
+func TestResolveAppliedBy(t *testing.T) {
+	cfg := Default()
+	cfg.AppliedBy = "fallback"
+	cfg.AppliedByFromJWT = JWTClaimSource{Env: "DEPLOY_TOKEN", Claim: "sub"}
+
+	t.Setenv("DEPLOY_TOKEN", "")
+	if got := cfg.ResolveAppliedBy(); got != "fallback" {
+		t.Fatalf("absent token: %q", got)
+	}
+	t.Setenv("DEPLOY_TOKEN", "garbage")
+	if got := cfg.ResolveAppliedBy(); got != "fallback" {
+		t.Fatalf("unparseable token: %q", got)
+	}
+	t.Setenv("DEPLOY_TOKEN", testJWT(`{"sub":"svc-deployer"}`))
+	if got := cfg.ResolveAppliedBy(); got != "svc-deployer" {
+		t.Fatalf("token claim: %q", got)
+	}
+}
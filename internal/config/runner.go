@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/migrator"
+)
+
+// ApplyTo sets the Runner fields the config selects: lock_wait_timeout_sec,
+// statement_timeout_sec, pause_between_sec, total_budget_sec, the replica
+// lag wait, analyze_after, analyze_all_changed, the
+// maintenance_on_sql/maintenance_off_sql statements, approval_url (an
+// HTTPApprover), contiguous_execution_order, all_or_nothing,
+// applied_by_meta and transaction_isolation. An
+// invalid transaction_isolation is an error. Unset keys leave the Runner's values alone. Replica pools
+// opened here belong to the Runner; close them with r.ReplicaLag.Close()
+// when done.
+func (c *Config) ApplyTo(r *migrator.Runner) error {
+	if d := c.LockWaitTimeout(); d > 0 {
+		r.LockWaitTimeout = d
+	}
+	if d := c.StatementTimeout(); d > 0 {
+		r.StatementTimeout = d
+	}
+	if d := c.PauseBetween(); d > 0 {
+		r.PauseBetween = d
+	}
+	if d := c.TotalBudget(); d > 0 {
+		r.TotalBudget = d
+	}
+	if len(c.AnalyzeAfter) > 0 {
+		r.AnalyzeAfter = c.AnalyzeAfter
+	}
+	if c.AnalyzeAllChanged {
+		r.AnalyzeAllChanged = true
+	}
+	if len(c.MaintenanceOnSQL)+len(c.MaintenanceOffSQL) > 0 {
+		r.MaintenanceOnSQL, r.MaintenanceOffSQL = c.MaintenanceOnSQL, c.MaintenanceOffSQL
+	}
+	if c.AppliedByMeta {
+		r.AppliedByMeta = true
+	}
+	if c.AllOrNothing {
+		r.AllOrNothing = true
+	}
+	if c.TransactionIsolation != "" {
+		level, err := migrator.ParseIsolation(c.TransactionIsolation)
+		if err != nil {
+			return fmt.Errorf("transaction_isolation: %w", err)
+		}
+		r.Isolation = level
+	}
+	if c.ContiguousOrder {
+		r.ContiguousOrder = true
+	}
+	if c.ApprovalURL != "" {
+		r.Approver = &migrator.HTTPApprover{URL: c.ApprovalURL}
+	}
+	wait, err := c.ReplicaLag()
+	if err != nil {
+		return err
+	}
+	if wait != nil {
+		r.ReplicaLag = wait
+	}
+	return nil
+}
+
+// ReplicaLag returns the wait configured by max_replica_lag_sec,
+// replica_dsns, replica_lag_query and replica_wait_timeout_sec, with a pool
+// opened for each replica, or nil if max_replica_lag_sec is unset.
+func (c *Config) ReplicaLag() (*migrator.ReplicaLagWait, error) {
+	if c.MaxReplicaLagSec <= 0 {
+		return nil, nil
+	}
+	if len(c.ReplicaDSNs) == 0 {
+		return nil, errors.New("max_replica_lag_sec is set but replica_dsns is empty")
+	}
+	wait := &migrator.ReplicaLagWait{
+		Query:   c.ReplicaLagQuery,
+		MaxLag:  time.Duration(c.MaxReplicaLagSec) * time.Second,
+		Timeout: time.Duration(c.ReplicaWaitTimeoutSec) * time.Second,
+	}
+	for i, dsn := range c.ReplicaDSNs {
+		pool, _, err := db.Open(dsn)
+		if err != nil {
+			_ = wait.Close()
+			return nil, fmt.Errorf("replica_dsns[%d]: %w", i, err)
+		}
+		wait.Replicas = append(wait.Replicas, pool)
+	}
+	return wait, nil
+}
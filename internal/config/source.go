@@ -0,0 +1,51 @@
+package config
+
+import (
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/migrator"
+)
+
+// FileSource returns the migration source the config describes: Dir (or
+// UpDir and DownDir), Ext, Dialect, Recursive, the checksum settings and the
+// inline Migrations. FS is left unset; a caller with embedded migrations
+// sets it.
+func (c *Config) FileSource() (migrator.FileSource, error) {
+	sum, err := c.ChecksumFunc()
+	if err != nil {
+		return migrator.FileSource{}, err
+	}
+	src := migrator.FileSource{
+		RootDir:     c.Dir,
+		UpRootDir:   c.UpDir,
+		DownRootDir: c.DownDir,
+		Ext:         c.Ext,
+		Dialect:     c.Dialect,
+		Recursive:   c.Recursive,
+		Checksum:    sum,
+	}
+	for _, m := range c.Migrations {
+		src.Inline = append(src.Inline, migrator.InlineMigration{Version: m.Version, Name: m.Name, Up: m.Up, Down: m.Down})
+	}
+	return src, nil
+}
+
+// PlanOptions returns the planning options the config selects:
+// ignore_future (relative to now), fail_on_orphan, failed_retry_after_sec
+// and ignore_drift.
+func (c *Config) PlanOptions(now time.Time) []migrator.PlanOption {
+	var opts []migrator.PlanOption
+	if c.IgnoreFuture {
+		opts = append(opts, migrator.WithIgnoreFuture(now))
+	}
+	if c.FailOnOrphan {
+		opts = append(opts, migrator.WithFailOnOrphan())
+	}
+	if d := c.FailedRetryAfter(); d > 0 {
+		opts = append(opts, migrator.WithFailedRetryAfter(d))
+	}
+	if len(c.IgnoreDrift) > 0 {
+		opts = append(opts, migrator.WithIgnoreDrift(c.IgnoreDrift...))
+	}
+	return opts
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Driver isolates the database-specific SQL and locking behind the tracking
+// table, so the runner can target MySQL, Postgres or SQLite. Queries passed to
+// Rebind use '?' placeholders.
+type Driver interface {
+	Name() string
+	Open(dsn string) (*sql.DB, error)
+
+	TableDDL(table string) string
+	EnsureTable(ctx context.Context, db *sql.DB, table string) error
+	// UpsertSQL inserts or updates a row from (version, name, checksum,
+	// applied_at, applied_by, duration_ms, status, execution_order, tool_version,
+	// down_checksum).
+	UpsertSQL(table string) string
+	// UpsertNextSQL is UpsertSQL with execution_order computed in-statement
+	// from the current MAX; it takes the same args minus execution_order,
+	// with tool_version and down_checksum last.
+	UpsertNextSQL(table string) string
+	// Rebind converts '?' placeholders to the driver's style.
+	Rebind(query string) string
+	// Placeholder returns the bind placeholder for the i-th argument (from 1):
+	// "?" on MySQL, "$i" on Postgres.
+	Placeholder(i int) string
+	// SessionTimeoutSQL returns statements bounding lock waits for the
+	// current transaction's session.
+	SessionTimeoutSQL(d time.Duration) []string
+	// SessionTimeoutResetSQL returns statements restoring the server
+	// defaults SessionTimeoutSQL overrode, or nil when nothing it sets
+	// outlives the transaction.
+	SessionTimeoutResetSQL() []string
+	// IsDeadlock reports whether err is a deadlock. The victim's transaction
+	// is rolled back, so only an autocommit statement can be retried.
+	IsDeadlock(err error) bool
+	// TransactionalDDL reports whether DDL can be rolled back. MySQL commits
+	// implicitly before and after most DDL statements.
+	TransactionalDDL() bool
+	// AnalyzeSQL refreshes optimizer statistics for table, already quoted.
+	AnalyzeSQL(table string) string
+	// TableExistsSQL returns a query counting tables named table in schema,
+	// or in the connection's current schema when schema is empty, and its
+	// arguments.
+	TableExistsSQL(schema, table string) (string, []any)
+
+	// AdvisoryLock tries to take key on conn, waiting up to timeout. It
+	// reports false when another session holds it.
+	AdvisoryLock(ctx context.Context, conn *sql.Conn, key string, timeout time.Duration) (bool, error)
+	AdvisoryUnlock(ctx context.Context, conn *sql.Conn, key string) error
+}
+
+// MySQL is the default driver; its behavior matches the original MySQL-only code.
+var MySQL Driver = mysqlDriver{}
+
+// DriverFor selects a driver from the DSN scheme and returns the DSN in the
+// form that driver expects. postgres:// and postgresql:// select Postgres;
+// sqlite:// (followed by a file path) and file: select SQLite; mysql:// or
+// no scheme select MySQL.
+func DriverFor(dsn string) (Driver, string, error) {
+	if strings.HasPrefix(strings.ToLower(dsn), "file:") {
+		return SQLite, dsn, nil
+	}
+	scheme, rest, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return MySQL, dsn, nil
+	}
+	switch strings.ToLower(scheme) {
+	case "mysql":
+		return MySQL, rest, nil
+	case "postgres", "postgresql":
+		return Postgres, dsn, nil
+	case "sqlite":
+		return SQLite, rest, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported DSN scheme %q", scheme)
+	}
+}
+
+// DSNWithDatabase returns dsn pointing at database name instead, in the same
+// form: MySQL DSNs with or without mysql://, and postgres:// URLs.
+func DSNWithDatabase(dsn, name string) (string, error) {
+	d, rest, err := DriverFor(dsn)
+	if err != nil {
+		return "", err
+	}
+	if d == SQLite {
+		return "", errors.New("SQLite has no database server to create databases on")
+	}
+	if d == Postgres {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		u.Path = "/" + name
+		return u.String(), nil
+	}
+	cfg, err := mysql.ParseDSN(rest)
+	if err != nil {
+		return "", err
+	}
+	cfg.DBName = name
+	return strings.TrimSuffix(dsn, rest) + cfg.FormatDSN(), nil
+}
+
+// Open picks the driver from dsn's scheme and opens a pool with it.
+func Open(dsn string) (*sql.DB, Driver, error) {
+	d, dsn, err := DriverFor(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := d.Open(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, d, nil
+}
+
+// OpenWith is Open with pool sizing and per-connection init SQL from opts.
+func OpenWith(dsn string, opts Options) (*sql.DB, Driver, error) {
+	d, dsn, err := DriverFor(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conn *sql.DB
+	switch d {
+	case Postgres:
+		conn, err = openPostgres(dsn, opts)
+	case SQLite:
+		conn, err = openSQLite(dsn, opts)
+	default:
+		conn, err = OpenMySQLWith(dsn, opts)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, d, nil
+}
+
+type mysqlDriver struct{}
+
+func (mysqlDriver) Name() string                     { return "mysql" }
+func (mysqlDriver) Open(dsn string) (*sql.DB, error) { return OpenMySQL(dsn) }
+func (mysqlDriver) TableDDL(table string) string     { return TableDDL(table) }
+func (mysqlDriver) Rebind(query string) string       { return query }
+func (mysqlDriver) Placeholder(int) string           { return "?" }
+func (mysqlDriver) IsDeadlock(err error) bool        { return IsDeadlock(err) }
+func (mysqlDriver) TransactionalDDL() bool           { return false }
+func (mysqlDriver) AnalyzeSQL(table string) string   { return "ANALYZE TABLE " + table }
+func (mysqlDriver) TableExistsSQL(schema, table string) (string, []any) {
+	if schema == "" {
+		return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", []any{table}
+	}
+	return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ?", []any{schema, table}
+}
+func (mysqlDriver) EnsureTable(ctx context.Context, db *sql.DB, table string) error {
+	return EnsureTable(ctx, db, table)
+}
+
+func (mysqlDriver) UpsertSQL(table string) string {
+	return fmt.Sprintf(`
+INSERT INTO %s (version, name, checksum, applied_at, applied_by, duration_ms, status, execution_order, tool_version, down_checksum)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE checksum=VALUES(checksum), applied_at=VALUES(applied_at), applied_by=VALUES(applied_by), duration_ms=VALUES(duration_ms), status=VALUES(status), execution_order=VALUES(execution_order), tool_version=VALUES(tool_version), down_checksum=VALUES(down_checksum)
+`, table)
+}
+
+func (mysqlDriver) UpsertNextSQL(table string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[1]s (version, name, checksum, applied_at, applied_by, duration_ms, status, tool_version, down_checksum, execution_order)
+SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(MAX(execution_order), 0) + 1 FROM %[1]s
+ON DUPLICATE KEY UPDATE checksum=VALUES(checksum), applied_at=VALUES(applied_at), applied_by=VALUES(applied_by), duration_ms=VALUES(duration_ms), status=VALUES(status), execution_order=VALUES(execution_order), tool_version=VALUES(tool_version), down_checksum=VALUES(down_checksum)
+`, table)
+}
+
+func (mysqlDriver) SessionTimeoutSQL(d time.Duration) []string {
+	secs := int64(d / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return []string{
+		fmt.Sprintf("SET SESSION lock_wait_timeout = %d", secs),
+		fmt.Sprintf("SET SESSION innodb_lock_wait_timeout = %d", secs),
+	}
+}
+
+// SessionTimeoutResetSQL restores the global values: SET SESSION outlives
+// the transaction on the pooled connection.
+func (mysqlDriver) SessionTimeoutResetSQL() []string {
+	return []string{
+		"SET SESSION lock_wait_timeout = DEFAULT",
+		"SET SESSION innodb_lock_wait_timeout = DEFAULT",
+	}
+}
+
+func (mysqlDriver) AdvisoryLock(ctx context.Context, conn *sql.Conn, key string, timeout time.Duration) (bool, error) {
+	// GET_LOCK(name, timeout_seconds)
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", key, int(timeout.Seconds())).Scan(&got); err != nil {
+		return false, err
+	}
+	return got.Valid && got.Int64 == 1, nil
+}
+
+func (mysqlDriver) AdvisoryUnlock(ctx context.Context, conn *sql.Conn, key string) error {
+	var rel sql.NullInt64
+	return conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", key).Scan(&rel)
+}
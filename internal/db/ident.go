@@ -0,0 +1,80 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// identRe matches the table and schema names QualifiedTable accepts. They
+// are interpolated into SQL, so nothing that needs escaping is allowed.
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// ValidateTableName checks a table name as configured: a plain
+// identifier, optionally qualified as schema.table, either part optionally
+// quoted. Names are spliced into SQL, so anything else is rejected.
+func ValidateTableName(name string) error {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return fmt.Errorf("invalid table name %q: at most one schema qualifier is allowed", name)
+	}
+	for _, part := range parts {
+		if !identRe.MatchString(unquoteIdent(part)) {
+			return fmt.Errorf("invalid table name %q: %q must match %s", name, part, identRe)
+		}
+	}
+	return nil
+}
+
+// QualifiedTable returns the tracking table name to use in d's SQL. Without
+// a schema it is table as given, which Storage quotes when it builds SQL. With a
+// schema (a database on MySQL, an attached database on SQLite) both parts
+// are quoted for d, with backticks on MySQL and double quotes elsewhere,
+// e.g. `ops`.`schema_migrations`. Quoted names keep their case on
+// Postgres. Both names must be plain identifiers.
+func QualifiedTable(d Driver, schema, table string) (string, error) {
+	if !identRe.MatchString(table) {
+		return "", fmt.Errorf("invalid tracking table name %q", table)
+	}
+	if schema == "" {
+		return table, nil
+	}
+	if !identRe.MatchString(schema) {
+		return "", fmt.Errorf("invalid tracking table schema %q", schema)
+	}
+	return quoteIdent(d, schema) + "." + quoteIdent(d, table), nil
+}
+
+// QuoteTable quotes each part of a possibly schema-qualified table name for
+// d, leaving parts that are already quoted as they are. Postgres folds
+// unquoted names to lower case, so there an unquoted part is lowercased
+// before quoting: SchemaMigrations still names the table created as
+// schemamigrations, and only a quoted part keeps its case.
+func QuoteTable(d Driver, table string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		name := unquoteIdent(part)
+		if name == part && d != nil && d.Name() == "postgres" {
+			name = strings.ToLower(name)
+		}
+		parts[i] = quoteIdent(d, name)
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteIdent quotes name for d, doubling any quote character inside it.
+func quoteIdent(d Driver, name string) string {
+	q := `"`
+	if d == nil || d.Name() == "mysql" {
+		q = "`"
+	}
+	return q + strings.ReplaceAll(name, q, q+q) + q
+}
+
+// unquoteIdent removes the quotes quoteIdent adds.
+func unquoteIdent(name string) string {
+	if len(name) >= 2 && (name[0] == '`' || name[0] == '"') && name[len(name)-1] == name[0] {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
@@ -3,15 +3,52 @@ package db
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
+// Options tunes how OpenMySQLWith opens the pool.
+type Options struct {
+	// InitSQL runs on every new physical connection (e.g. SET NAMES,
+	// SET SESSION sql_mode), so pooled connections share the same session
+	// settings regardless of server defaults.
+	InitSQL []string
+
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime size the pool; zero
+	// keeps the defaults (10, 10, 30m). Serverless databases usually want
+	// fewer connections, long migration jobs a longer lifetime.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// configurePool applies the pool settings, falling back to the defaults.
+func (o Options) configurePool(db *sql.DB) {
+	maxOpen, maxIdle, lifetime := 10, 10, 30*time.Minute
+	if o.MaxOpenConns > 0 {
+		maxOpen = o.MaxOpenConns
+	}
+	if o.MaxIdleConns > 0 {
+		maxIdle = o.MaxIdleConns
+	}
+	if o.ConnMaxLifetime > 0 {
+		lifetime = o.ConnMaxLifetime
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+}
+
 func OpenMySQL(dsn string) (*sql.DB, error) {
+	return OpenMySQLWith(dsn, Options{})
+}
+
+func OpenMySQLWith(dsn string, opts Options) (*sql.DB, error) {
 	// Ensure parseTime is on, recommend multiStatements true
 	if !strings.Contains(strings.ToLower(dsn), "parsetime=") {
 		if strings.Contains(dsn, "?") {
@@ -20,33 +57,196 @@ func OpenMySQL(dsn string) (*sql.DB, error) {
 			dsn += "?parseTime=true"
 		}
 	}
-	db, err := sql.Open("mysql", dsn)
+	var db *sql.DB
+	if len(opts.InitSQL) == 0 {
+		var err error
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		base, err := mysql.NewConnector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		db = sql.OpenDB(&initConnector{Connector: base, init: opts.InitSQL})
+	}
+	opts.configurePool(db)
+	return db, nil
+}
+
+// initConnector runs init statements on each connection as it is created.
+type initConnector struct {
+	driver.Connector
+	init []string
+}
+
+func (c *initConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(10)
-	db.SetConnMaxLifetime(30 * time.Minute)
-	return db, nil
+	ex, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, errors.New("driver connection does not support ExecContext")
+	}
+	for _, q := range c.init {
+		if _, err := ex.ExecContext(ctx, q, nil); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("connection init %q: %w", q, err)
+		}
+	}
+	return conn, nil
 }
 
-func EnsureTable(ctx context.Context, db *sql.DB, table string) error {
-	ddl := fmt.Sprintf(`
+// RedactDSN masks the password in a MySQL DSN so it can be logged or printed.
+// DSNs the driver can't parse (e.g. URL form) are masked from the first ':'
+// after any scheme to the last '@'.
+func RedactDSN(dsn string) string {
+	if cfg, err := mysql.ParseDSN(dsn); err == nil {
+		if cfg.Passwd == "" {
+			return dsn
+		}
+		return strings.Replace(dsn, ":"+cfg.Passwd+"@", ":****@", 1)
+	}
+	start := 0
+	if i := strings.Index(dsn, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	at := strings.LastIndex(dsn, "@")
+	colon := strings.Index(dsn[start:], ":")
+	if at < start || colon < 0 || start+colon > at {
+		return dsn
+	}
+	return dsn[:start+colon+1] + "****" + dsn[at:]
+}
+
+// TableDDL returns the CREATE TABLE statement for the tracking table. It is
+// the single source of truth for EnsureTable and for printing the DDL for
+// manual review.
+func TableDDL(table string) string {
+	return fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS %s (
   id BIGINT PRIMARY KEY AUTO_INCREMENT,
   version VARCHAR(64) NOT NULL,
   name VARCHAR(255) NOT NULL,
-  checksum CHAR(64) NOT NULL,
+  checksum VARCHAR(255) NOT NULL,
   applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   applied_by VARCHAR(255) NOT NULL,
   duration_ms BIGINT NOT NULL,
   status ENUM('success','failed') NOT NULL,
   execution_order BIGINT NOT NULL,
+  tool_version VARCHAR(64) NOT NULL DEFAULT '',
+  down_checksum VARCHAR(255) NULL,
   UNIQUE KEY uniq_version_name (version, name)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 `, table)
-	_, err := db.ExecContext(ctx, ddl)
-	return err
+}
+
+func EnsureTable(ctx context.Context, db *sql.DB, table string) error {
+	if _, err := db.ExecContext(ctx, TableDDL(table)); err != nil {
+		return err
+	}
+	return upgradeTable(ctx, db, table)
+}
+
+// addedColumns are tracking table columns introduced after the original
+// schema, with their definitions, in the order they were added.
+var addedColumns = [][2]string{
+	{"tool_version", "VARCHAR(64) NOT NULL DEFAULT ''"},
+	{"down_checksum", "VARCHAR(255) NULL"},
+}
+
+// widenedColumns are checksum columns widened to checksumWidth so tagged
+// checksums from longer algorithms (e.g. "sha512:<128 hex>") fit, with their
+// new definitions.
+var widenedColumns = [][2]string{
+	{"checksum", "VARCHAR(255) NOT NULL"},
+	{"down_checksum", "VARCHAR(255) NULL"},
+}
+
+const checksumWidth = 255
+
+// splitTable splits a schema-qualified table name, removing the quotes
+// QualifiedTable adds.
+func splitTable(table string) (schema, name string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return unquoteIdent(table[:i]), unquoteIdent(table[i+1:])
+	}
+	return "", unquoteIdent(table)
+}
+
+// upgradeTable adds columns introduced after a tracking table was created
+// and widens its checksum columns.
+func upgradeTable(ctx context.Context, db *sql.DB, table string) error {
+	schema, name := splitTable(table)
+	for _, col := range addedColumns {
+		var n int
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.columns
+WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ? AND column_name = ?`, schema, name, col[0]).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, col[0], col[1])); err != nil {
+			return err
+		}
+	}
+	for _, col := range widenedColumns {
+		var width int
+		err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(character_maximum_length), 0) FROM information_schema.columns
+WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ? AND column_name = ?`, schema, name, col[0]).Scan(&width)
+		if err != nil {
+			return err
+		}
+		if width >= checksumWidth {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s MODIFY %s %s", table, col[0], col[1])); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 var ErrLockTimeout = errors.New("advisory lock wait timeout")
+
+// ErrMultiStatementsIgnored means a multi-statement probe ran only its first
+// statement, typically because a proxy strips multiStatements=true.
+var ErrMultiStatementsIgnored = errors.New("multi-statement execution is not honored (check multiStatements=true and any proxy in between)")
+
+// ProbeMultiStatements runs a harmless two-statement query and verifies both
+// statements produced results.
+func ProbeMultiStatements(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SELECT 1; SELECT 2")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMultiStatementsIgnored, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	if !rows.NextResultSet() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrMultiStatementsIgnored
+	}
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// IsDeadlock reports whether err is a MySQL deadlock (1213). InnoDB resolves
+// it by rolling back the victim's whole transaction, so only a statement that
+// ran in a transaction of its own (autocommit) can simply be retried.
+func IsDeadlock(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1213
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres uses pg_advisory_lock and INSERT ... ON CONFLICT.
+var Postgres Driver = postgresDriver{}
+
+// pgLockPoll is how often AdvisoryLock retries pg_try_advisory_lock while
+// waiting; tests shorten it.
+var pgLockPoll = 250 * time.Millisecond
+
+type postgresDriver struct{}
+
+func (postgresDriver) Name() string { return "postgres" }
+
+func (postgresDriver) Open(dsn string) (*sql.DB, error) {
+	return openPostgres(dsn, Options{})
+}
+
+// openPostgres opens a pool sized by opts. InitSQL is not supported.
+func openPostgres(dsn string, opts Options) (*sql.DB, error) {
+	if len(opts.InitSQL) > 0 {
+		return nil, errors.New("connection init SQL is only supported on MySQL")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	opts.configurePool(db)
+	return db, nil
+}
+
+func (postgresDriver) TableDDL(table string) string {
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+  id BIGSERIAL PRIMARY KEY,
+  version VARCHAR(64) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  checksum VARCHAR(255) NOT NULL,
+  applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  applied_by VARCHAR(255) NOT NULL,
+  duration_ms BIGINT NOT NULL,
+  status VARCHAR(16) NOT NULL CHECK (status IN ('success','failed')),
+  execution_order BIGINT NOT NULL,
+  tool_version VARCHAR(64) NOT NULL DEFAULT '',
+  down_checksum VARCHAR(255) NULL,
+  UNIQUE (version, name)
+);
+`, table)
+}
+
+func (d postgresDriver) EnsureTable(ctx context.Context, db *sql.DB, table string) error {
+	if _, err := db.ExecContext(ctx, d.TableDDL(table)); err != nil {
+		return err
+	}
+	for _, col := range addedColumns {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, col[0], col[1])); err != nil {
+			return err
+		}
+	}
+	schema, name := splitTable(table)
+	for _, col := range widenedColumns {
+		var width int
+		err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(character_maximum_length), 0) FROM information_schema.columns
+WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2 AND column_name = $3`, schema, name, col[0]).Scan(&width)
+		if err != nil {
+			return err
+		}
+		if width >= checksumWidth {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE VARCHAR(%d)", table, col[0], checksumWidth)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const pgUpdateSet = `ON CONFLICT (version, name) DO UPDATE SET checksum=EXCLUDED.checksum, applied_at=EXCLUDED.applied_at, applied_by=EXCLUDED.applied_by, duration_ms=EXCLUDED.duration_ms, status=EXCLUDED.status, execution_order=EXCLUDED.execution_order, tool_version=EXCLUDED.tool_version, down_checksum=EXCLUDED.down_checksum`
+
+func (postgresDriver) UpsertSQL(table string) string {
+	return fmt.Sprintf(`
+INSERT INTO %s (version, name, checksum, applied_at, applied_by, duration_ms, status, execution_order, tool_version, down_checksum)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+%s
+`, table, pgUpdateSet)
+}
+
+func (postgresDriver) UpsertNextSQL(table string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[1]s (version, name, checksum, applied_at, applied_by, duration_ms, status, tool_version, down_checksum, execution_order)
+SELECT $1, $2, $3, $4::timestamptz, $5, $6::bigint, $7, $8, $9, COALESCE(MAX(execution_order), 0) + 1 FROM %[1]s
+%[2]s
+`, table, pgUpdateSet)
+}
+
+// Rebind converts '?' placeholders to $1, $2, ... It doesn't look inside
+// string literals; the queries it sees are the tool's own.
+func (postgresDriver) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDriver) Placeholder(i int) string { return "$" + strconv.Itoa(i) }
+
+func (postgresDriver) TransactionalDDL() bool { return true }
+
+func (postgresDriver) AnalyzeSQL(table string) string { return "ANALYZE " + table }
+
+func (postgresDriver) TableExistsSQL(schema, table string) (string, []any) {
+	if schema == "" {
+		return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1", []any{table}
+	}
+	return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = $1 AND table_name = $2", []any{schema, table}
+}
+
+func (postgresDriver) SessionTimeoutSQL(d time.Duration) []string {
+	ms := d.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return []string{fmt.Sprintf("SET LOCAL lock_timeout = %d", ms)}
+}
+
+// SessionTimeoutResetSQL is nil: SET LOCAL ends with the transaction.
+func (postgresDriver) SessionTimeoutResetSQL() []string { return nil }
+
+func (postgresDriver) IsDeadlock(err error) bool {
+	var pe *pq.Error
+	return errors.As(err, &pe) && pe.Code == "40P01"
+}
+
+// pgLockID maps a lock key to the bigint pg_advisory_lock expects.
+func pgLockID(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+func (postgresDriver) AdvisoryLock(ctx context.Context, conn *sql.Conn, key string, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var got bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", pgLockID(key)).Scan(&got); err != nil {
+			return false, err
+		}
+		if got {
+			return true, nil
+		}
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+		t := time.NewTimer(pgLockPoll)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (postgresDriver) AdvisoryUnlock(ctx context.Context, conn *sql.Conn, key string) error {
+	var rel bool
+	return conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", pgLockID(key)).Scan(&rel)
+}
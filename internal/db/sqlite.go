@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SQLite targets a local SQLite file for development and tests. SQLite has
+// no session locks, so the advisory lock is a file created next to the
+// database, or a lock held in this process for an in-memory database. The database/sql driver is not bundled: import one that
+// registers as "sqlite" (modernc.org/sqlite) or "sqlite3"
+// (github.com/mattn/go-sqlite3).
+var SQLite Driver = sqliteDriver{}
+
+// sqliteLockPoll is how often AdvisoryLock retries creating the lock file
+// while waiting; tests shorten it.
+var sqliteLockPoll = 250 * time.Millisecond
+
+type sqliteDriver struct{}
+
+func (sqliteDriver) Name() string { return "sqlite" }
+
+func (sqliteDriver) Open(dsn string) (*sql.DB, error) {
+	return openSQLite(dsn, Options{})
+}
+
+// openSQLite opens dsn with whichever SQLite database/sql driver is
+// registered. InitSQL is not supported.
+func openSQLite(dsn string, opts Options) (*sql.DB, error) {
+	if len(opts.InitSQL) > 0 {
+		return nil, errors.New("connection init SQL is only supported on MySQL")
+	}
+	registered := sql.Drivers()
+	name := ""
+	for _, n := range []string{"sqlite", "sqlite3"} {
+		if slices.Contains(registered, n) {
+			name = n
+			break
+		}
+	}
+	if name == "" {
+		return nil, errors.New("no SQLite database/sql driver is registered; import modernc.org/sqlite or github.com/mattn/go-sqlite3")
+	}
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		return nil, err
+	}
+	opts.configurePool(db)
+	return db, nil
+}
+
+func (sqliteDriver) TableDDL(table string) string {
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  version TEXT NOT NULL,
+  name TEXT NOT NULL,
+  checksum TEXT NOT NULL,
+  applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  applied_by TEXT NOT NULL,
+  duration_ms INTEGER NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('success','failed')),
+  execution_order INTEGER NOT NULL,
+  tool_version TEXT NOT NULL DEFAULT '',
+  down_checksum TEXT NULL,
+  UNIQUE (version, name)
+);
+`, table)
+}
+
+// EnsureTable creates the table. TEXT columns have no width to widen, and
+// the DDL already has every column added since the original schema.
+func (d sqliteDriver) EnsureTable(ctx context.Context, db *sql.DB, table string) error {
+	_, err := db.ExecContext(ctx, d.TableDDL(table))
+	return err
+}
+
+const sqliteUpdateSet = `ON CONFLICT (version, name) DO UPDATE SET checksum=excluded.checksum, applied_at=excluded.applied_at, applied_by=excluded.applied_by, duration_ms=excluded.duration_ms, status=excluded.status, execution_order=excluded.execution_order, tool_version=excluded.tool_version, down_checksum=excluded.down_checksum`
+
+func (sqliteDriver) UpsertSQL(table string) string {
+	return fmt.Sprintf(`
+INSERT INTO %s (version, name, checksum, applied_at, applied_by, duration_ms, status, execution_order, tool_version, down_checksum)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+%s
+`, table, sqliteUpdateSet)
+}
+
+// UpsertNextSQL needs WHERE true: without it SQLite parses ON CONFLICT as
+// part of the SELECT's join.
+func (sqliteDriver) UpsertNextSQL(table string) string {
+	return fmt.Sprintf(`
+INSERT INTO %[1]s (version, name, checksum, applied_at, applied_by, duration_ms, status, tool_version, down_checksum, execution_order)
+SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(MAX(execution_order), 0) + 1 FROM %[1]s WHERE true
+%[2]s
+`, table, sqliteUpdateSet)
+}
+
+func (sqliteDriver) Rebind(query string) string { return query }
+func (sqliteDriver) Placeholder(int) string     { return "?" }
+func (sqliteDriver) IsDeadlock(error) bool      { return false }
+func (sqliteDriver) TransactionalDDL() bool     { return true }
+
+func (sqliteDriver) AnalyzeSQL(table string) string { return "ANALYZE " + table }
+
+// TableExistsSQL reads sqlite_master, of the attached database schema when
+// one is given.
+func (sqliteDriver) TableExistsSQL(schema, table string) (string, []any) {
+	master := "sqlite_master"
+	if schema != "" {
+		master = quoteIdent(SQLite, schema) + "." + master
+	}
+	return "SELECT COUNT(*) FROM " + master + " WHERE type = 'table' AND name = ?", []any{table}
+}
+
+// SessionTimeoutSQL sets how long the connection waits on a locked database.
+func (sqliteDriver) SessionTimeoutSQL(d time.Duration) []string {
+	ms := d.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return []string{fmt.Sprintf("PRAGMA busy_timeout = %d", ms)}
+}
+
+// SessionTimeoutResetSQL is nil: the busy timeout stays on the connection,
+// since its earlier value (possibly set in the DSN) isn't known.
+func (sqliteDriver) SessionTimeoutResetSQL() []string { return nil }
+
+// sqliteMemLocks holds the advisory locks of in-memory databases, which no
+// other process can open, so a lock in this process is enough.
+var sqliteMemLocks = struct {
+	sync.Mutex
+	held map[string]bool
+}{held: map[string]bool{}}
+
+// sqliteLockPath returns the lock file for key next to the main database
+// file, or "" for an in-memory database.
+func sqliteLockPath(ctx context.Context, conn *sql.Conn, key string) (string, error) {
+	var file string
+	if err := conn.QueryRowContext(ctx, "SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&file); err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	suffix := ".gomigratex-" + strconv.FormatUint(h.Sum64(), 16) + ".lock"
+	if file == "" {
+		return "", nil
+	}
+	return file + suffix, nil
+}
+
+// sqliteTryLock takes key once, without waiting: the lock file at path, or
+// the process-local lock when path is "".
+func sqliteTryLock(path, key string) (bool, error) {
+	if path == "" {
+		sqliteMemLocks.Lock()
+		defer sqliteMemLocks.Unlock()
+		if sqliteMemLocks.held[key] {
+			return false, nil
+		}
+		sqliteMemLocks.held[key] = true
+		return true, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, _ = fmt.Fprintf(f, "pid %d\n", os.Getpid())
+	if err := f.Close(); err != nil {
+		// the caller treats an error as not locked, so don't leave the
+		// file blocking every later run
+		_ = os.Remove(path)
+		return false, err
+	}
+	return true, nil
+}
+
+// AdvisoryLock creates the lock file exclusively, retrying until timeout.
+// For an in-memory database it takes a lock held by this process instead.
+// A run that crashes leaves the file behind; delete it once no run is
+// active.
+func (sqliteDriver) AdvisoryLock(ctx context.Context, conn *sql.Conn, key string, timeout time.Duration) (bool, error) {
+	path, err := sqliteLockPath(ctx, conn, key)
+	if err != nil {
+		return false, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if ok, err := sqliteTryLock(path, key); ok || err != nil {
+			return ok, err
+		}
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+		t := time.NewTimer(sqliteLockPoll)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (sqliteDriver) AdvisoryUnlock(ctx context.Context, conn *sql.Conn, key string) error {
+	path, err := sqliteLockPath(ctx, conn, key)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		sqliteMemLocks.Lock()
+		delete(sqliteMemLocks.held, key)
+		sqliteMemLocks.Unlock()
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
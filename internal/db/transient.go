@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// IsTransient reports whether err looks like an infrastructure hiccup that
+// may succeed if retried on a fresh connection: a dropped or refused
+// connection, a server shutting down, too many connections, a deadlock or
+// lock wait timeout. Errors in the SQL itself, such as syntax errors or
+// constraint violations, are not transient.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		switch me.Number {
+		case 1040, // too many connections
+			1053, // server shutdown in progress
+			1205, // lock wait timeout
+			1213: // deadlock
+			return true
+		}
+		return false
+	}
+	var pe *pq.Error
+	if errors.As(err, &pe) {
+		switch code := string(pe.Code); code {
+		case "40001", // serialization failure
+			"40P01", // deadlock
+			"53300", // too many connections
+			"57P01", // admin shutdown
+			"57P02", // crash shutdown
+			"57P03": // cannot connect now
+			return true
+		default:
+			return strings.HasPrefix(code, "08") // connection exception
+		}
+	}
+	return false
+}
+
+// IsAuthError reports whether err is the server refusing the credentials or
+// access to the database, which retrying can't fix.
+func IsAuthError(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		switch me.Number {
+		case 1044, // access denied to database
+			1045, // access denied for user
+			1698: // access denied, auth plugin
+			return true
+		}
+		return false
+	}
+	var pe *pq.Error
+	if errors.As(err, &pe) {
+		return strings.HasPrefix(string(pe.Code), "28") // invalid authorization
+	}
+	return false
+}
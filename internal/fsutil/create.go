@@ -0,0 +1,172 @@
+package fsutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// OnConflict says what CreatePair does when a migration file it would write
+// already exists.
+type OnConflict string
+
+const (
+	// ConflictError refuses to create anything (default).
+	ConflictError OnConflict = "error"
+	// ConflictSkip leaves the existing files alone and reports Skipped.
+	ConflictSkip OnConflict = "skip"
+	// ConflictSuffix appends _2, _3, ... to the name until it is free.
+	ConflictSuffix OnConflict = "suffix"
+)
+
+// ErrFileExists is returned by CreatePair under ConflictError.
+var ErrFileExists = errors.New("migration file already exists")
+
+// ParseOnConflict validates an --on-conflict value; empty means ConflictError.
+func ParseOnConflict(s string) (OnConflict, error) {
+	switch OnConflict(s) {
+	case "":
+		return ConflictError, nil
+	case ConflictError, ConflictSkip, ConflictSuffix:
+		return OnConflict(s), nil
+	}
+	return "", fmt.Errorf("invalid on-conflict %q: want error, skip or suffix", s)
+}
+
+// Created reports what CreatePair did.
+type Created struct {
+	Name     string // name used, including any suffix
+	UpPath   string
+	DownPath string
+	Skipped  bool // ConflictSkip found existing files and wrote nothing
+}
+
+// Templates hold text/template sources for new migration files; an empty
+// template leaves its file empty.
+type Templates struct {
+	Up   string
+	Down string
+}
+
+// TemplateData is what templates can reference: {{.Version}}, {{.Name}},
+// {{.Timestamp}} (RFC 3339, UTC) and {{.Direction}} ("up" or "down").
+type TemplateData struct {
+	Version   string
+	Name      string
+	Timestamp string
+	Direction string
+}
+
+// LoadTemplates reads template files: shared applies to both directions and
+// up or down, when set, override it for theirs. Empty paths are skipped.
+func LoadTemplates(shared, up, down string) (Templates, error) {
+	var t Templates
+	read := func(path string, dst ...*string) error {
+		if path == "" {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, d := range dst {
+			*d = string(b)
+		}
+		return nil
+	}
+	if err := read(shared, &t.Up, &t.Down); err != nil {
+		return t, err
+	}
+	if err := read(up, &t.Up); err != nil {
+		return t, err
+	}
+	return t, read(down, &t.Down)
+}
+
+// CreatePair writes empty {version}_{name}.up{ext} and .down{ext} files in
+// dir, handling existing files according to onConflict. Files are created
+// exclusively, so a concurrent writer can't be overwritten either.
+func CreatePair(dir, version, name, ext string, onConflict OnConflict) (Created, error) {
+	return CreatePairFrom(dir, version, name, ext, onConflict, Templates{})
+}
+
+// CreatePairFrom is CreatePair with the files' contents rendered from tmpl.
+// Templates are parsed before anything is written.
+func CreatePairFrom(dir, version, name, ext string, onConflict OnConflict, tmpl Templates) (Created, error) {
+	up, err := template.New("up").Parse(tmpl.Up)
+	if err != nil {
+		return Created{}, fmt.Errorf("up template: %w", err)
+	}
+	down, err := template.New("down").Parse(tmpl.Down)
+	if err != nil {
+		return Created{}, fmt.Errorf("down template: %w", err)
+	}
+	if ext == "" {
+		ext = DefaultExt
+	}
+	if onConflict == "" {
+		onConflict = ConflictError
+	}
+	paths := func(n string) (string, string) {
+		base := filepath.Join(dir, version+"_"+n)
+		return base + ".up" + ext, base + ".down" + ext
+	}
+	c := Created{Name: name}
+	c.UpPath, c.DownPath = paths(name)
+	for i := 2; exists(c.UpPath) || exists(c.DownPath); i++ {
+		switch onConflict {
+		case ConflictSkip:
+			c.Skipped = true
+			return c, nil
+		case ConflictSuffix:
+			c.Name = name + "_" + strconv.Itoa(i)
+			c.UpPath, c.DownPath = paths(c.Name)
+		default:
+			return c, fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(c.UpPath))
+		}
+	}
+	data := TemplateData{Version: version, Name: c.Name, Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	var upBody, downBody bytes.Buffer
+	data.Direction = "up"
+	if err := up.Execute(&upBody, data); err != nil {
+		return c, fmt.Errorf("up template: %w", err)
+	}
+	data.Direction = "down"
+	if err := down.Execute(&downBody, data); err != nil {
+		return c, fmt.Errorf("down template: %w", err)
+	}
+	if err := createExcl(c.UpPath, upBody.Bytes()); err != nil {
+		return c, err
+	}
+	if err := createExcl(c.DownPath, downBody.Bytes()); err != nil {
+		_ = os.Remove(c.UpPath)
+		return c, err
+	}
+	return c, nil
+}
+
+func exists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+func createExcl(p string, data []byte) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(p))
+		}
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(p)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,103 @@
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreatePair_OnConflict(t *testing.T) {
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "20250101000000_init.up.sql"), []byte("CREATE TABLE t(id INT);"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	t.Run("error", func(t *testing.T) {
+		dir := setup(t)
+		if _, err := CreatePair(dir, "20250101000000", "init", "", ConflictError); !errors.Is(err, ErrFileExists) {
+			t.Fatalf("expected ErrFileExists, got %v", err)
+		}
+		if b, _ := os.ReadFile(filepath.Join(dir, "20250101000000_init.up.sql")); string(b) != "CREATE TABLE t(id INT);" {
+			t.Fatalf("existing file modified: %q", b)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "20250101000000_init.down.sql")); !os.IsNotExist(err) {
+			t.Fatalf("down file should not be created: %v", err)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		dir := setup(t)
+		c, err := CreatePair(dir, "20250101000000", "init", "", ConflictSkip)
+		if err != nil || !c.Skipped {
+			t.Fatalf("expected skip, got %+v, %v", c, err)
+		}
+		if b, _ := os.ReadFile(c.UpPath); string(b) != "CREATE TABLE t(id INT);" {
+			t.Fatalf("existing file modified: %q", b)
+		}
+	})
+
+	t.Run("suffix", func(t *testing.T) {
+		dir := setup(t)
+		if err := os.WriteFile(filepath.Join(dir, "20250101000000_init_2.down.sql"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := CreatePair(dir, "20250101000000", "init", "", ConflictSuffix)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if c.Name != "init_3" || filepath.Base(c.UpPath) != "20250101000000_init_3.up.sql" {
+			t.Fatalf("unexpected result: %+v", c)
+		}
+		for _, p := range []string{c.UpPath, c.DownPath} {
+			if _, err := os.Stat(p); err != nil {
+				t.Fatalf("not created: %v", err)
+			}
+		}
+	})
+}
+
+func TestParseOnConflict(t *testing.T) {
+	if c, err := ParseOnConflict(""); err != nil || c != ConflictError {
+		t.Fatalf("default: %v, %v", c, err)
+	}
+	if _, err := ParseOnConflict("overwrite"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestCreatePairFromTemplates(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared.tmpl")
+	down := filepath.Join(dir, "down.tmpl")
+	if err := os.WriteFile(shared, []byte("-- {{.Direction}}: {{.Version}} {{.Name}}\n-- ticket: TODO\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(down, []byte("-- revert {{.Name}} ({{.Direction}})\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := LoadTemplates(shared, "", down)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	c, err := CreatePairFrom(dir, "20250101000000", "add_users", "", ConflictError, tmpl)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if b, _ := os.ReadFile(c.UpPath); string(b) != "-- up: 20250101000000 add_users\n-- ticket: TODO\n" {
+		t.Fatalf("up file: %q", b)
+	}
+	if b, _ := os.ReadFile(c.DownPath); string(b) != "-- revert add_users (down)\n" {
+		t.Fatalf("down file: %q", b)
+	}
+
+	if _, err := CreatePairFrom(dir, "20250102000000", "bad", "", ConflictError, Templates{Up: "{{.Author}}"}); err == nil {
+		t.Fatal("expected an error for an unknown template field")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20250102000000_bad.up.sql")); !os.IsNotExist(err) {
+		t.Fatalf("nothing should be written when a template fails: %v", err)
+	}
+}
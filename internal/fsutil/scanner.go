@@ -2,15 +2,58 @@ package fsutil
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
 )
 
-var fileRe = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_\-]+)\.(up|down)\.sql$`)
+// DefaultExt is the migration file extension used when none is configured.
+const DefaultExt = ".sql"
+
+var (
+	extRe     = regexp.MustCompile(`^(\.[a-zA-Z0-9]+)+$`)
+	dialectRe = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+)
+
+// ScanOptions selects which files a scan accepts.
+type ScanOptions struct {
+	// Ext is the file extension including the dot, e.g. ".sql" or ".ddl".
+	// Empty means DefaultExt.
+	Ext string
+	// Dialect, when set, also accepts {version}_{name}.(up|down).{Dialect}{Ext}
+	// and prefers those over the undialected file for the same migration.
+	// Files suffixed with any other dialect are ignored.
+	Dialect string
+	// Recursive also scans subdirectories, so migrations can be organized
+	// in folders such as 2025/01. Versions are still ordered and checked
+	// for duplicates across the whole tree.
+	Recursive bool
+}
+
+func (o ScanOptions) ext() string {
+	if o.Ext == "" {
+		return DefaultExt
+	}
+	return o.Ext
+}
+
+// pattern compiles the file name pattern for o: version, name, direction and
+// an optional dialect suffix.
+func (o ScanOptions) pattern() (*regexp.Regexp, error) {
+	ext := o.ext()
+	if !extRe.MatchString(ext) {
+		return nil, fmt.Errorf("invalid migration file extension %q", ext)
+	}
+	if o.Dialect != "" && !dialectRe.MatchString(o.Dialect) {
+		return nil, fmt.Errorf("invalid dialect %q", o.Dialect)
+	}
+	return regexp.Compile(`^(\d+)_([a-zA-Z0-9_\-]+)\.(up|down)(?:\.([a-zA-Z0-9]+))??` + regexp.QuoteMeta(ext) + `$`)
+}
 
 type Pair struct {
 	Version  string
@@ -23,61 +66,271 @@ type FS interface {
 	fs.FS
 }
 
+// Reasons an entry was left out of a scan.
+const (
+	ReasonPattern     = "not-matching-pattern"
+	ReasonMissingPair = "missing-pair"
+	ReasonDuplicate   = "duplicate"
+	// ReasonDialect marks files for another dialect, or undialected files
+	// superseded by a dialect-specific one. Like ReasonPattern, not an error.
+	ReasonDialect = "other-dialect"
+)
+
+// Ignored is a directory entry that did not become part of a valid pair.
+type Ignored struct {
+	Path   string
+	Reason string
+	Detail string
+}
+
+// Report is the full result of a scan: valid pairs plus everything ignored.
+type Report struct {
+	Pairs   map[string]*Pair
+	Ignored []Ignored
+}
+
+// Err returns the first problem that makes the set unusable (a duplicate or
+// a missing half of a pair). Files not matching the pattern or meant for
+// another dialect are not errors.
+func (r *Report) Err() error {
+	for _, ig := range r.Ignored {
+		if ig.Reason == ReasonMissingPair || ig.Reason == ReasonDuplicate {
+			return errors.New(ig.Detail)
+		}
+	}
+	return nil
+}
+
 // ScanDir scans a local directory on disk.
 func ScanDir(dir string) (map[string]*Pair, error) {
-	entries, err := os.ReadDir(dir)
+	r, err := ScanDirReport(dir)
 	if err != nil {
 		return nil, err
 	}
-	return scan(entries, func(name string) string { return filepath.Join(dir, name) })
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return r.Pairs, nil
 }
 
-// ScanEmbedded scans an embedded fs under a root dir path (logical path).
+// ScanEmbedded scans any fs.FS (embedded or not) under a root dir path (logical path).
 func ScanEmbedded(fsys fs.FS, root string) (map[string]*Pair, error) {
-	entries, err := fs.ReadDir(fsys, root)
+	r, err := ScanEmbeddedReport(fsys, root)
 	if err != nil {
 		return nil, err
 	}
-	return scan(entries, func(name string) string { return filepath.Join(root, name) })
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return r.Pairs, nil
 }
 
-func scan(entries []fs.DirEntry, full func(name string) string) (map[string]*Pair, error) {
-	out := map[string]*Pair{}
-	for _, e := range entries {
-		if e.IsDir() {
+// ScanDirReport is ScanDir without failing on malformed entries; they are
+// reported in Report.Ignored instead.
+func ScanDirReport(dir string) (*Report, error) {
+	return ScanDirReportWith(dir, ScanOptions{})
+}
+
+// ScanDirReportWith is ScanDirReport with a custom extension or dialect.
+func ScanDirReportWith(dir string, opts ScanOptions) (*Report, error) {
+	re, err := opts.pattern()
+	if err != nil {
+		return nil, err
+	}
+	// stat first so a missing dir is reported by its own path, not os.DirFS's "."
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	names, err := list(os.DirFS(dir), ".", opts.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	return scan(names, func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }, re, opts), nil
+}
+
+// ScanSplitDirReportWith scans a layout that keeps up files under upDir and
+// down files under downDir, pairing them by version and name. Down files in
+// upDir and up files in downDir are ignored as not matching. A migration
+// whose other half is missing from the other root is reported as
+// ReasonMissingPair.
+func ScanSplitDirReportWith(upDir, downDir string, opts ScanOptions) (*Report, error) {
+	re, err := opts.pattern()
+	if err != nil {
+		return nil, err
+	}
+	var files []splitFile
+	for _, half := range []struct{ dir, typ string }{{upDir, "up"}, {downDir, "down"}} {
+		if _, err := os.Stat(half.dir); err != nil {
+			return nil, err
+		}
+		names, err := list(os.DirFS(half.dir), ".", opts.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			files = append(files, splitFile{path: path.Join(filepath.ToSlash(half.dir), n), typ: half.typ})
+		}
+	}
+	return scanSplit(files, filepath.FromSlash, upDir, downDir, re, opts), nil
+}
+
+// ScanSplitEmbeddedReportWith is ScanSplitDirReportWith for roots in fsys.
+func ScanSplitEmbeddedReportWith(fsys fs.FS, upRoot, downRoot string, opts ScanOptions) (*Report, error) {
+	re, err := opts.pattern()
+	if err != nil {
+		return nil, err
+	}
+	var files []splitFile
+	for _, half := range []struct{ root, typ string }{{upRoot, "up"}, {downRoot, "down"}} {
+		names, err := list(fsys, half.root, opts.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			files = append(files, splitFile{path: path.Join(half.root, n), typ: half.typ})
+		}
+	}
+	return scanSplit(files, func(name string) string { return name }, upRoot, downRoot, re, opts), nil
+}
+
+// splitFile is a file found under the root for one direction.
+type splitFile struct {
+	path string // slash-separated
+	typ  string // "up" or "down"
+}
+
+// scanSplit runs scan over both roots' files after setting aside those of
+// the wrong direction, and names the root a missing half was expected in.
+func scanSplit(files []splitFile, full func(name string) string, upRoot, downRoot string, re *regexp.Regexp, opts ScanOptions) *Report {
+	var names []string
+	var wrong []Ignored
+	for _, f := range files {
+		if m := re.FindStringSubmatch(path.Base(f.path)); m != nil && m[3] != f.typ {
+			wrong = append(wrong, Ignored{Path: full(f.path), Reason: ReasonPattern, Detail: m[3] + " file in the " + f.typ + " root"})
 			continue
 		}
-		m := fileRe.FindStringSubmatch(e.Name())
+		names = append(names, f.path)
+	}
+	rep := scan(names, full, re, opts)
+	for i, ig := range rep.Ignored {
+		if ig.Reason != ReasonMissingPair {
+			continue
+		}
+		key := strings.TrimPrefix(ig.Detail, "missing pair for ")
+		if ig.Path != "" && re.FindStringSubmatch(path.Base(filepath.ToSlash(ig.Path)))[3] == "up" {
+			rep.Ignored[i].Detail = fmt.Sprintf("missing pair for %s: no down file under %s", key, downRoot)
+		} else {
+			rep.Ignored[i].Detail = fmt.Sprintf("missing pair for %s: no up file under %s", key, upRoot)
+		}
+	}
+	rep.Ignored = append(rep.Ignored, wrong...)
+	return rep
+}
+
+// ScanEmbeddedReport is ScanEmbedded without failing on malformed entries.
+func ScanEmbeddedReport(fsys fs.FS, root string) (*Report, error) {
+	return ScanEmbeddedReportWith(fsys, root, ScanOptions{})
+}
+
+// ScanEmbeddedReportWith is ScanEmbeddedReport with a custom extension or dialect.
+func ScanEmbeddedReportWith(fsys fs.FS, root string, opts ScanOptions) (*Report, error) {
+	re, err := opts.pattern()
+	if err != nil {
+		return nil, err
+	}
+	if root == "" {
+		root = "."
+	}
+	names, err := list(fsys, root, opts.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	return scan(names, func(name string) string { return path.Join(root, name) }, re, opts), nil
+}
+
+// list returns the files under root in fsys as slash-separated paths
+// relative to root: only root's own files, or with recursive every file in
+// the tree below it.
+func list(fsys fs.FS, root string, recursive bool) ([]string, error) {
+	var names []string
+	if !recursive {
+		entries, err := fs.ReadDir(fsys, root)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+		return names, nil
+	}
+	err := fs.WalkDir(fsys, root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		rel := strings.TrimPrefix(p, root+"/")
+		if root == "." {
+			rel = p
+		}
+		names = append(names, rel)
+		return nil
+	})
+	return names, err
+}
+
+func scan(names []string, full func(name string) string, re *regexp.Regexp, opts ScanOptions) *Report {
+	out := map[string]*Pair{}
+	// specific tracks which halves came from a dialect-specific file.
+	specific := map[string]bool{}
+	var ignored []Ignored
+	for _, n := range names {
+		m := re.FindStringSubmatch(path.Base(n))
 		if m == nil {
+			ignored = append(ignored, Ignored{Path: full(n), Reason: ReasonPattern, Detail: "name does not match {version}_{name}.(up|down)" + opts.ext()})
+			continue
+		}
+		version, name, typ, suffix := m[1], m[2], m[3], m[4]
+		if suffix != "" && suffix != opts.Dialect {
+			ignored = append(ignored, Ignored{Path: full(n), Reason: ReasonDialect, Detail: "file is for dialect " + suffix})
 			continue
 		}
-		version, name, typ := m[1], m[2], m[3]
 		key := version + ":" + name
 		p := out[key]
 		if p == nil {
 			p = &Pair{Version: version, Name: name}
 			out[key] = p
 		}
-		switch typ {
-		case "up":
-			if p.UpPath != "" {
-				return nil, errors.New("duplicate up file for version " + version)
-			}
-			p.UpPath = full(e.Name())
-		case "down":
-			if p.DownPath != "" {
-				return nil, errors.New("duplicate down file for version " + version)
-			}
-			p.DownPath = full(e.Name())
+		slot := &p.UpPath
+		if typ == "down" {
+			slot = &p.DownPath
+		}
+		half := key + ":" + typ
+		isSpecific := suffix != ""
+		switch {
+		case *slot == "":
+		case isSpecific && !specific[half]:
+			ignored = append(ignored, Ignored{Path: *slot, Reason: ReasonDialect, Detail: "superseded by " + full(n)})
+		case !isSpecific && specific[half]:
+			ignored = append(ignored, Ignored{Path: full(n), Reason: ReasonDialect, Detail: "superseded by " + *slot})
+			continue
+		default:
+			ignored = append(ignored, Ignored{Path: full(n), Reason: ReasonDuplicate, Detail: "duplicate " + typ + " file for version " + version})
+			continue
 		}
+		*slot = full(n)
+		specific[half] = isSpecific
 	}
 	// Validate all have both up/down
-	for k, p := range out {
+	for _, k := range SortKeys(out) {
+		p := out[k]
 		if p.UpPath == "" || p.DownPath == "" {
-			return nil, errors.New("missing pair for " + k)
+			path := p.UpPath + p.DownPath
+			ignored = append(ignored, Ignored{Path: path, Reason: ReasonMissingPair, Detail: "missing pair for " + k})
+			delete(out, k)
 		}
 	}
-	return out, nil
+	return &Report{Pairs: out, Ignored: ignored}
 }
 
 func SortKeys(m map[string]*Pair) []string {
@@ -31,3 +31,208 @@ func TestScanDirAndSortKeys(t *testing.T) {
 		t.Fatalf("unexpected keys: %#v", keys)
 	}
 }
+
+func TestScanDirReportMissingPair(t *testing.T) {
+	dir := t.TempDir()
+	for _, n := range []string{"1_a.up.sql", "1_a.down.sql", "01_a.up.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte("--"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// "01" and "1" are distinct versions, so 01_a has no down half.
+	r, err := ScanDirReport(dir)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(r.Pairs) != 1 || len(r.Ignored) != 1 || r.Ignored[0].Reason != ReasonMissingPair {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	if _, err := ScanDir(dir); err == nil {
+		t.Fatal("ScanDir must still fail on a missing pair")
+	}
+}
+
+func TestScanDirReportWithExtAndDialect(t *testing.T) {
+	dir := t.TempDir()
+	for _, n := range []string{
+		"1_shared.up.sql", "1_shared.down.sql",
+		"2_idx.up.mysql.sql", "2_idx.down.mysql.sql",
+		"2_idx.up.pg.sql", "2_idx.down.pg.sql",
+		"3_over.up.sql", "3_over.down.sql", "3_over.up.pg.sql",
+		"4_ddl.up.ddl", "4_ddl.down.ddl",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte("--"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := ScanDirReportWith(dir, ScanOptions{Dialect: "pg"})
+	if err != nil {
+		t.Fatalf("scan pg: %v", err)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("pg report: %v", err)
+	}
+	if len(r.Pairs) != 3 {
+		t.Fatalf("expected 3 pg pairs, got %+v", r.Pairs)
+	}
+	if p := r.Pairs["2:idx"]; filepath.Base(p.UpPath) != "2_idx.up.pg.sql" {
+		t.Fatalf("expected pg file, got %s", p.UpPath)
+	}
+	if p := r.Pairs["3:over"]; filepath.Base(p.UpPath) != "3_over.up.pg.sql" || filepath.Base(p.DownPath) != "3_over.down.sql" {
+		t.Fatalf("expected dialect file to win with generic down, got %+v", p)
+	}
+
+	r, err = ScanDirReportWith(dir, ScanOptions{Dialect: "mysql"})
+	if err != nil {
+		t.Fatalf("scan mysql: %v", err)
+	}
+	if p := r.Pairs["2:idx"]; p == nil || filepath.Base(p.UpPath) != "2_idx.up.mysql.sql" {
+		t.Fatalf("expected mysql file, got %+v", p)
+	}
+
+	r, err = ScanDirReportWith(dir, ScanOptions{Ext: ".ddl"})
+	if err != nil {
+		t.Fatalf("scan ddl: %v", err)
+	}
+	if len(r.Pairs) != 1 || r.Pairs["4:ddl"] == nil {
+		t.Fatalf("expected only the .ddl pair, got %+v", r.Pairs)
+	}
+
+	if _, err := ScanDirReportWith(dir, ScanOptions{Ext: "sql"}); err == nil {
+		t.Fatal("expected error for extension without a dot")
+	}
+}
+
+func writeTree(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, n := range names {
+		p := filepath.Join(dir, filepath.FromSlash(n))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("--"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir,
+		"2025/02/20250201000000_later.up.sql", "2025/02/20250201000000_later.down.sql",
+		"2024/12/20241201000000_first.up.sql", "2024/12/20241201000000_first.down.sql",
+		"20250101000000_top.up.sql", "20250101000000_top.down.sql",
+		// halves of one pair may sit in different folders
+		"2025/03/20250301000000_split.up.sql", "2025/03/down/20250301000000_split.down.sql",
+	)
+
+	flat, err := ScanDirReportWith(dir, ScanOptions{})
+	if err != nil {
+		t.Fatalf("flat scan: %v", err)
+	}
+	if len(flat.Pairs) != 1 || flat.Pairs["20250101000000:top"] == nil {
+		t.Fatalf("flat scan must ignore subdirectories, got %+v", flat.Pairs)
+	}
+
+	r, err := ScanDirReportWith(dir, ScanOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("recursive scan: %v", err)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	keys := SortKeys(r.Pairs)
+	want := []string{"20241201000000:first", "20250101000000:top", "20250201000000:later", "20250301000000:split"}
+	if len(keys) != len(want) {
+		t.Fatalf("keys = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("keys = %v, want %v", keys, want)
+		}
+	}
+	if got := r.Pairs["20241201000000:first"].UpPath; got != filepath.Join(dir, "2024", "12", "20241201000000_first.up.sql") {
+		t.Fatalf("unexpected path %s", got)
+	}
+
+	// the same tree through an fs.FS
+	e, err := ScanEmbeddedReportWith(os.DirFS(filepath.Dir(dir)), filepath.Base(dir), ScanOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("fs scan: %v", err)
+	}
+	if got := e.Pairs["20250201000000:later"]; got == nil || got.DownPath != filepath.Base(dir)+"/2025/02/20250201000000_later.down.sql" {
+		t.Fatalf("unexpected fs pair %+v", got)
+	}
+}
+
+func TestScanRecursiveDuplicateAcrossFolders(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir,
+		"a/1_init.up.sql", "a/1_init.down.sql",
+		"b/1_init.up.sql", "b/1_init.down.sql",
+	)
+	r, err := ScanDirReportWith(dir, ScanOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if err := r.Err(); err == nil {
+		t.Fatal("expected a duplicate across folders")
+	}
+	var dups int
+	for _, ig := range r.Ignored {
+		if ig.Reason == ReasonDuplicate {
+			dups++
+		}
+	}
+	if dups != 2 {
+		t.Fatalf("expected both halves reported as duplicates, got %+v", r.Ignored)
+	}
+}
+
+func TestScanSplitRoots(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir,
+		"up/1_init.up.sql", "down/1_init.down.sql",
+		"up/2_users.up.sql", "down/2_users.down.sql",
+		"up/3_orphan.up.sql",
+		"down/4_stray.down.sql",
+		"up/5_misplaced.down.sql",
+	)
+	up, down := filepath.Join(dir, "up"), filepath.Join(dir, "down")
+	r, err := ScanSplitDirReportWith(up, down, ScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(r.Pairs) != 2 {
+		t.Fatalf("pairs = %+v", r.Pairs)
+	}
+	p := r.Pairs["2:users"]
+	if p == nil || p.UpPath != filepath.Join(up, "2_users.up.sql") || p.DownPath != filepath.Join(down, "2_users.down.sql") {
+		t.Fatalf("unexpected pair %+v", p)
+	}
+	details := map[string]string{}
+	for _, ig := range r.Ignored {
+		details[filepath.Base(ig.Path)] = ig.Reason + ": " + ig.Detail
+	}
+	if got := details["3_orphan.up.sql"]; got != ReasonMissingPair+": missing pair for 3:orphan: no down file under "+down {
+		t.Fatalf("orphan up: %q", got)
+	}
+	if got := details["4_stray.down.sql"]; got != ReasonMissingPair+": missing pair for 4:stray: no up file under "+up {
+		t.Fatalf("stray down: %q", got)
+	}
+	if got := details["5_misplaced.down.sql"]; got != ReasonPattern+": down file in the up root" {
+		t.Fatalf("misplaced: %q", got)
+	}
+	if r.Err() == nil {
+		t.Fatal("an incomplete pairing must be an error")
+	}
+
+	e, err := ScanSplitEmbeddedReportWith(os.DirFS(dir), "up", "down", ScanOptions{})
+	if err != nil {
+		t.Fatalf("fs scan: %v", err)
+	}
+	if got := e.Pairs["1:init"]; got == nil || got.UpPath != "up/1_init.up.sql" || got.DownPath != "down/1_init.down.sql" {
+		t.Fatalf("unexpected fs pair %+v", got)
+	}
+}
@@ -0,0 +1,120 @@
+// Package health exposes migration state over HTTP so gomigratex can run as
+// a sidecar readiness gate.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/migrator"
+)
+
+// Pinger reports whether the database is reachable; *sql.DB satisfies it.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Server serves /healthz, /ready and /status. Planning is read-only and
+// never takes the migration lock.
+type Server struct {
+	DB      Pinger
+	Source  migrator.FileSource
+	Storage *migrator.Storage
+
+	// CacheTTL reuses the last plan for this long so frequent probes don't
+	// hammer the database. Zero plans on every request.
+	CacheTTL time.Duration
+
+	mu       sync.Mutex
+	cached   *migrator.Plan
+	cacheErr error
+	cachedAt time.Time
+	now      func() time.Time // tests replace it
+}
+
+// Status is the JSON body of /status.
+type Status struct {
+	UpToDate bool            `json:"up_to_date"`
+	Applied  int             `json:"applied"`
+	Pending  []PendingStatus `json:"pending"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// PendingStatus identifies one pending migration.
+type PendingStatus struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+}
+
+// Handler returns a mux with the health endpoints registered.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/ready", s.ready)
+	mux.HandleFunc("/status", s.status)
+	return mux
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	if err := s.DB.PingContext(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (s *Server) ready(w http.ResponseWriter, r *http.Request) {
+	plan, err := s.plan(r.Context())
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case len(plan.Pending) > 0:
+		http.Error(w, "pending migrations", http.StatusServiceUnavailable)
+	default:
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ready\n"))
+	}
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	plan, err := s.plan(r.Context())
+	st := Status{Pending: []PendingStatus{}}
+	code := http.StatusOK
+	if err != nil {
+		st.Error = err.Error()
+		code = http.StatusServiceUnavailable
+	} else {
+		st.UpToDate = len(plan.Pending) == 0
+		for _, row := range plan.Applied {
+			if row.Status == "success" {
+				st.Applied++
+			}
+		}
+		for _, fp := range plan.Pending {
+			st.Pending = append(st.Pending, PendingStatus{Version: fp.Version, Name: fp.Name})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(st)
+}
+
+// plan returns the cached plan while it is fresh, otherwise replans.
+func (s *Server) plan(ctx context.Context) (*migrator.Plan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	if s.CacheTTL > 0 && !s.cachedAt.IsZero() && now().Sub(s.cachedAt) < s.CacheTTL {
+		return s.cached, s.cacheErr
+	}
+	s.cached, s.cacheErr = migrator.DiscoverAndPlan(ctx, s.Source, s.Storage)
+	s.cachedAt = now()
+	return s.cached, s.cacheErr
+}
@@ -0,0 +1,113 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+	"github.com/mirajehossain/gomigratex/internal/migrator"
+)
+
+var columns = []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+
+func newServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"20250101000000_init.up.sql":   "CREATE TABLE t1(id INT);",
+		"20250101000000_init.down.sql": "DROP TABLE t1;",
+		"20250102000000_more.up.sql":   "CREATE TABLE t2(id INT);",
+		"20250102000000_more.down.sql": "DROP TABLE t2;",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &Server{
+		DB:      db,
+		Source:  migrator.FileSource{RootDir: dir},
+		Storage: &migrator.Storage{DB: db, Table: "schema_migrations"},
+	}, mock
+}
+
+func get(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHealthz(t *testing.T) {
+	s, mock := newServer(t)
+	mock.ExpectPing()
+	if rec := get(t, s.Handler(), "/healthz"); rec.Code != http.StatusOK {
+		t.Fatalf("healthz: %d", rec.Code)
+	}
+}
+
+func TestReadyAndStatus(t *testing.T) {
+	s, mock := newServer(t)
+	h := s.Handler()
+	chk1 := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+	chk2 := checksum.SHA256([]byte("CREATE TABLE t2(id INT);"))
+
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", chk1, time.Now(), "tester", int64(1), "success", int64(1), "dev", nil))
+	if rec := get(t, h, "/ready"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ready with pending: %d", rec.Code)
+	}
+
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", chk1, time.Now(), "tester", int64(1), "success", int64(1), "dev", nil))
+	rec := get(t, h, "/status")
+	var st Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if st.UpToDate || st.Applied != 1 || len(st.Pending) != 1 || st.Pending[0].Name != "more" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", chk1, time.Now(), "tester", int64(1), "success", int64(1), "dev", nil).
+		AddRow("20250102000000", "more", chk2, time.Now(), "tester", int64(1), "success", int64(2), "dev", nil))
+	if rec := get(t, h, "/ready"); rec.Code != http.StatusOK {
+		t.Fatalf("ready when up to date: %d", rec.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestPlanCache(t *testing.T) {
+	s, mock := newServer(t)
+	now := time.Unix(0, 0)
+	s.now = func() time.Time { return now }
+	s.CacheTTL = time.Minute
+	h := s.Handler()
+
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns))
+	get(t, h, "/ready")
+	get(t, h, "/status") // served from cache, no query
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns))
+	get(t, h, "/ready")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations after expiry: %v", err)
+	}
+}
@@ -5,57 +5,313 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mirajehossain/gomigratex/internal/db"
 )
 
+// Advisory is a database advisory lock held on a dedicated connection; the
+// driver supplies the lock SQL (GET_LOCK on MySQL, pg_advisory_lock on Postgres).
+type Advisory struct {
+	driver db.Driver
+	conn   *sql.Conn
+	key    string
+	held   bool
+
+	mu        sync.Mutex // guards conn and held against the heartbeat
+	heartbeat time.Duration
+	stop      chan struct{} // closes to stop the running heartbeat
+	done      chan struct{} // closed when the running heartbeat exits
+	lost      chan error
+}
+
 // MySQL advisory lock using GET_LOCK/RELEASE_LOCK on a dedicated connection.
-type MySQL struct {
-	conn *sql.Conn
-	key  string
-	held bool
+type MySQL = Advisory
+
+func NewMySQL(_ *sql.DB, key string) *MySQL {
+	return New(db.MySQL, key)
 }
 
-func NewMySQL(db *sql.DB, key string) *MySQL {
-	return &MySQL{key: key}
+// New returns an advisory lock on key using d's locking primitives.
+func New(d db.Driver, key string) *Advisory {
+	return &Advisory{driver: d, key: key, lost: make(chan error, 1)}
 }
 
-func (m *MySQL) Acquire(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+// SetHeartbeat makes Acquire start a keepalive that checks the lock
+// connection every interval, so server idle timeouts such as MySQL's
+// wait_timeout don't drop it during a long migration, and reports on Lost
+// if the lock is gone. Zero (the default) disables it.
+func (m *Advisory) SetHeartbeat(interval time.Duration) { m.heartbeat = interval }
+
+// Lost receives ErrLost, at most once per Acquire, when the heartbeat finds
+// the lock connection dead. Watch it and stop migrating when it fires.
+func (m *Advisory) Lost() <-chan error { return m.lost }
+
+// ErrNotAcquired means the lock wait timed out: another run holds the lock.
+var ErrNotAcquired = errors.New("failed to acquire advisory lock (timeout or error)")
+
+func (m *Advisory) Acquire(ctx context.Context, pool *sql.DB, timeout time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.held {
 		return nil
 	}
 	var err error
-	m.conn, err = db.Conn(ctx)
+	m.conn, err = pool.Conn(ctx)
 	if err != nil {
 		return err
 	}
-	// GET_LOCK(name, timeout_seconds)
-	row := m.conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", m.key, int(timeout.Seconds()))
-	var got sql.NullInt64
-	if err := row.Scan(&got); err != nil {
+	got, err := m.driver.AdvisoryLock(ctx, m.conn, m.key, timeout)
+	if err != nil {
 		_ = m.conn.Close()
 		return err
 	}
-	if !got.Valid || got.Int64 != 1 {
+	if !got {
 		_ = m.conn.Close()
-		return errors.New("failed to acquire advisory lock (timeout or error)")
+		return ErrNotAcquired
 	}
 	m.held = true
+	if m.heartbeat > 0 {
+		m.startHeartbeat()
+	}
 	return nil
 }
 
-func (m *MySQL) Release(ctx context.Context) error {
+// startHeartbeat checks the lock every m.heartbeat until stopped or lost.
+func (m *Advisory) startHeartbeat() {
+	stop, done, every := make(chan struct{}), make(chan struct{}), m.heartbeat
+	m.stop, m.done = stop, done
+	go func() {
+		defer close(done)
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), every)
+			err := m.Check(ctx)
+			cancel()
+			if errors.Is(err, ErrLost) {
+				select {
+				case m.lost <- err:
+				default:
+				}
+				return
+			}
+		}
+	}()
+}
+
+// stopHeartbeat stops a running heartbeat and waits for it to exit.
+func (m *Advisory) stopHeartbeat() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop, m.done = nil, nil
+}
+
+// Mode selects how a run takes the lock.
+type Mode string
+
+const (
+	// ModeWait waits up to the lock timeout for another run to finish (default).
+	ModeWait Mode = "wait"
+	// ModeNoWait fails immediately with db.ErrLockTimeout if another run
+	// holds the lock.
+	ModeNoWait Mode = "nowait"
+	// ModeSkip takes no lock at all, for disposable databases such as in CI.
+	// Concurrent runs are not serialized, so callers should warn.
+	ModeSkip Mode = "skip"
+)
+
+// ParseMode validates a lock mode; empty means ModeWait.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "":
+		return ModeWait, nil
+	case ModeWait, ModeNoWait, ModeSkip:
+		return Mode(s), nil
+	}
+	return "", fmt.Errorf("invalid lock mode %q: want wait, nowait or skip", s)
+}
+
+// AcquireMode takes the lock as mode says. Under ModeNoWait a held lock is
+// reported as db.ErrLockTimeout, also matching ErrNotAcquired; under
+// ModeSkip nothing is acquired and Release is a no-op.
+func (m *Advisory) AcquireMode(ctx context.Context, pool *sql.DB, mode Mode, timeout time.Duration) error {
+	switch mode {
+	case ModeSkip:
+		return nil
+	case ModeNoWait:
+		err := m.Acquire(ctx, pool, 0)
+		if errors.Is(err, ErrNotAcquired) {
+			return fmt.Errorf("%w: %w", db.ErrLockTimeout, err)
+		}
+		return err
+	}
+	return m.Acquire(ctx, pool, timeout)
+}
+
+// TryAcquire attempts the lock without waiting. It reports false, with no
+// error, when another run already holds it, so callers can treat "a
+// migration is already in progress" as a no-op instead of a failure.
+func (m *Advisory) TryAcquire(ctx context.Context, pool *sql.DB) (bool, error) {
+	err := m.Acquire(ctx, pool, 0)
+	if errors.Is(err, ErrNotAcquired) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m *Advisory) Release(ctx context.Context) error {
+	m.stopHeartbeat()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if !m.held || m.conn == nil {
 		return nil
 	}
-	row := m.conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", m.key)
-	var rel sql.NullInt64
-	_ = row.Scan(&rel) // do not fail on release
+	// do not fail on release, and still unlock when ctx was cancelled by
+	// an aborted run
+	_ = m.driver.AdvisoryUnlock(context.WithoutCancel(ctx), m.conn, m.key)
 	m.held = false
 	return m.conn.Close()
 }
 
-func (m *MySQL) Key() string { return m.key }
+func (m *Advisory) Key() string { return m.key }
+
+// ErrLost means the lock's connection died while the lock was held. The
+// server releases session locks on disconnect, so another run may have
+// migrated concurrently.
+var ErrLost = errors.New("lost advisory lock: its connection died, another run may have migrated concurrently")
+
+// Check reports whether the lock is still held by pinging its dedicated
+// connection, which poolers and proxies may kill. A dead connection marks
+// the lock released and returns ErrLost. Call it between migrations (see
+// migrator.Runner.LockCheck) to fail fast instead of racing another run.
+func (m *Advisory) Check(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.held || m.conn == nil {
+		return nil
+	}
+	err := m.conn.PingContext(ctx)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	_ = m.conn.Close()
+	m.held = false
+	return fmt.Errorf("%w: %v", ErrLost, err)
+}
+
+// Reacquire takes the lock again on a fresh connection after Check returned
+// ErrLost. It is only safe before planning: another run may have migrated
+// while the lock was lost, so discard any plan made under the old lock.
+func (m *Advisory) Reacquire(ctx context.Context, pool *sql.DB, timeout time.Duration) error {
+	m.stopHeartbeat()
+	m.mu.Lock()
+	if m.held && m.conn != nil {
+		_ = m.conn.Close()
+	}
+	m.held = false
+	m.mu.Unlock()
+	return m.Acquire(ctx, pool, timeout)
+}
 
 func KeyFor(database, table string) string {
+	return KeyForScope(ScopeTable, database, table)
+}
+
+// Scope selects how widely a lock key serializes runs.
+type Scope string
+
+const (
+	// ScopeTable locks one migration set (tracking table) in a database, so
+	// unrelated sets in the same database don't block each other (default).
+	ScopeTable Scope = "per-table"
+	// ScopeDatabase locks the whole database, for when ordering across
+	// migration sets matters.
+	ScopeDatabase Scope = "per-database"
+)
+
+// ParseScope validates a lock scope; empty means ScopeTable.
+func ParseScope(s string) (Scope, error) {
+	switch Scope(s) {
+	case "":
+		return ScopeTable, nil
+	case ScopeTable, ScopeDatabase:
+		return Scope(s), nil
+	}
+	return "", fmt.Errorf("invalid lock scope %q: want per-table or per-database", s)
+}
+
+// KeyForScope builds the lock key for table in database under scope:
+// gomigratex:<database>:<table> per table, gomigratex:<database> per database.
+func KeyForScope(scope Scope, database, table string) string {
+	if scope == ScopeDatabase {
+		return "gomigratex:" + database
+	}
 	return fmt.Sprintf("gomigratex:%s:%s", database, table)
 }
+
+// DatabaseFromDSN returns the database name of dsn, in any form db.Open
+// accepts. MySQL DSNs go through the driver's own parser, so unix sockets,
+// multi-host addresses and DSN parameters don't confuse it; postgres:// URLs
+// name it in their path. SQLite has no database name.
+func DatabaseFromDSN(dsn string) (string, error) {
+	d, dsn, err := db.DriverFor(dsn)
+	if err != nil {
+		return "", err
+	}
+	var name string
+	switch d {
+	case db.Postgres:
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		name = strings.TrimPrefix(u.Path, "/")
+	case db.SQLite:
+	default:
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "", err
+		}
+		name = cfg.DBName
+	}
+	if name == "" {
+		return "", errors.New("DSN has no database name; set lock_db_name")
+	}
+	return name, nil
+}
+
+// KeyForDSN builds the lock key for table in the database named by dsn.
+// dbName, when non-empty, overrides the name parsed from the DSN so
+// equivalent DSNs (or logically distinct databases behind one DSN form)
+// share or split the lock as intended.
+func KeyForDSN(dsn, dbName, table string) (string, error) {
+	return KeyForDSNScope(ScopeTable, dsn, dbName, table)
+}
+
+// KeyForDSNScope is KeyForDSN with the key built under scope; see
+// KeyForScope.
+func KeyForDSNScope(scope Scope, dsn, dbName, table string) (string, error) {
+	if dbName == "" {
+		var err error
+		if dbName, err = DatabaseFromDSN(dsn); err != nil {
+			return "", err
+		}
+	}
+	return KeyForScope(scope, dbName, table), nil
+}
@@ -1,9 +1,254 @@
 package lock
 
-import "testing"
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
 
 func TestKeyFor(t *testing.T) {
 	if KeyFor("db", "t") != "gomigratex:db:t" {
 		t.Fatal("key format mismatch")
 	}
 }
+
+func TestDatabaseFromDSN(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"user:pass@tcp(localhost:3306)/app?parseTime=true": "app",
+		"user:pass@unix(/var/run/mysqld/mysqld.sock)/app":  "app",
+		"user@tcp(db1:3306,db2:3306)/orders":               "orders",
+		"user:p@ss/word@tcp(h:3306)/billing":               "billing",
+		"mysql://user@tcp(h:3306)/billing":                 "billing",
+		"postgres://user@h:5432/reports?sslmode=disable":   "reports",
+	} {
+		got, err := DatabaseFromDSN(dsn)
+		if err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", dsn, got, err, want)
+		}
+	}
+	if _, err := DatabaseFromDSN("user@unix(/tmp/mysql.sock)/"); err == nil {
+		t.Error("expected error for DSN without database")
+	}
+}
+
+func TestKeyForDSN(t *testing.T) {
+	k, err := KeyForDSN("u@unix(/tmp/mysql.sock)/app", "", "schema_migrations")
+	if err != nil || k != "gomigratex:app:schema_migrations" {
+		t.Fatalf("got %q, %v", k, err)
+	}
+	k, err = KeyForDSN("u@unix(/tmp/mysql.sock)/", "tenant_a", "schema_migrations")
+	if err != nil || k != "gomigratex:tenant_a:schema_migrations" {
+		t.Fatalf("override: got %q, %v", k, err)
+	}
+}
+
+func TestTryAcquire(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	key := KeyFor("app", "schema_migrations")
+
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 0).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(0))
+	l := NewMySQL(db, key)
+	ok, err := l.TryAcquire(context.Background(), db)
+	if err != nil || ok {
+		t.Fatalf("held elsewhere: ok=%v err=%v", ok, err)
+	}
+
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 0).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	ok, err = l.TryAcquire(context.Background(), db)
+	if err != nil || !ok {
+		t.Fatalf("free: ok=%v err=%v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestKeyForScope(t *testing.T) {
+	if k := KeyForScope(ScopeTable, "app", "billing_migrations"); k != "gomigratex:app:billing_migrations" {
+		t.Fatalf("per-table: %s", k)
+	}
+	a := KeyForScope(ScopeDatabase, "app", "billing_migrations")
+	b := KeyForScope(ScopeDatabase, "app", "search_migrations")
+	if a != "gomigratex:app" || a != b {
+		t.Fatalf("per-database keys must ignore the table: %s, %s", a, b)
+	}
+	if s, err := ParseScope(""); err != nil || s != ScopeTable {
+		t.Fatalf("default scope: %v, %v", s, err)
+	}
+	if _, err := ParseScope("global"); err == nil {
+		t.Fatal("expected error for unknown scope")
+	}
+}
+
+func TestCheckDetectsKilledConnection(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	key := KeyFor("app", "schema_migrations")
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 5).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	l := NewMySQL(db, key)
+	if err := l.Acquire(ctx, db, 5*time.Second); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	mock.ExpectPing()
+	if err := l.Check(ctx); err != nil {
+		t.Fatalf("healthy connection: %v", err)
+	}
+
+	// sqlmock forgets its DSN once every connection closes; keep one open so
+	// Reacquire can dial a fresh connection
+	keep, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("conn: %v", err)
+	}
+	defer keep.Close()
+
+	// a pooler kills the lock connection
+	mock.ExpectPing().WillReturnError(driver.ErrBadConn)
+	if err := l.Check(ctx); !errors.Is(err, ErrLost) {
+		t.Fatalf("expected ErrLost, got %v", err)
+	}
+	if err := l.Check(ctx); err != nil {
+		t.Fatalf("released lock should not be checked again: %v", err)
+	}
+
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 5).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	if err := l.Reacquire(ctx, db, 5*time.Second); err != nil {
+		t.Fatalf("reacquire: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestAcquireMode(t *testing.T) {
+	pool, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer pool.Close()
+	key := KeyFor("app", "schema_migrations")
+	ctx := context.Background()
+
+	// skip: no lock query at all
+	if err := NewMySQL(pool, key).AcquireMode(ctx, pool, ModeSkip, 30*time.Second); err != nil {
+		t.Fatalf("skip: %v", err)
+	}
+
+	// nowait: timeout 0 and an immediate ErrLockTimeout
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 0).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(0))
+	err = NewMySQL(pool, key).AcquireMode(ctx, pool, ModeNoWait, 30*time.Second)
+	if !errors.Is(err, db.ErrLockTimeout) || !errors.Is(err, ErrNotAcquired) {
+		t.Fatalf("nowait: expected ErrLockTimeout, got %v", err)
+	}
+
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 30).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	if err := NewMySQL(pool, key).AcquireMode(ctx, pool, ModeWait, 30*time.Second); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+	if _, err := ParseMode("block"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestHeartbeatReportsLostLock(t *testing.T) {
+	pool, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer pool.Close()
+	key := KeyFor("app", "schema_migrations")
+
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 5).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(driver.ErrBadConn) // wait_timeout dropped the session
+	l := NewMySQL(pool, key)
+	l.SetHeartbeat(5 * time.Millisecond)
+	if err := l.Acquire(context.Background(), pool, 5*time.Second); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	select {
+	case err := <-l.Lost():
+		if !errors.Is(err, ErrLost) {
+			t.Fatalf("expected ErrLost, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("heartbeat did not report the lost lock")
+	}
+	if err := l.Release(context.Background()); err != nil {
+		t.Fatalf("release after loss: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestReleaseStopsHeartbeat(t *testing.T) {
+	pool, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer pool.Close()
+	key := KeyFor("app", "schema_migrations")
+
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 5).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	mock.ExpectQuery("SELECT RELEASE_LOCK").WithArgs(key).WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(1))
+	l := NewMySQL(pool, key)
+	l.SetHeartbeat(time.Hour)
+	if err := l.Acquire(context.Background(), pool, 5*time.Second); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	done := l.done
+	if err := l.Release(context.Background()); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatal("heartbeat still running after Release")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestReleaseWithCancelledContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	key := KeyFor("app", "schema_migrations")
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs(key, 0).WillReturnRows(sqlmock.NewRows([]string{"l"}).AddRow(1))
+	mock.ExpectQuery("SELECT RELEASE_LOCK").WithArgs(key).WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(1))
+
+	l := NewMySQL(db, key)
+	if ok, err := l.TryAcquire(context.Background(), db); err != nil || !ok {
+		t.Fatalf("acquire: ok=%v err=%v", ok, err)
+	}
+	// an aborted run releases with its cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
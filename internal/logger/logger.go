@@ -1,48 +1,130 @@
 package logger
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ErrStrictWarnings is returned by CheckStrict when warnings were logged.
+var ErrStrictWarnings = errors.New("warnings treated as errors (strict)")
+
 type Logger struct {
-	json bool
+	json     bool
+	out      io.Writer
+	slog     *slog.Logger
+	level    slog.LevelVar
+	mu       sync.Mutex
+	warnings int
 }
 
 func New(jsonOutput bool) *Logger {
-	l := &Logger{json: jsonOutput}
+	l := &Logger{json: jsonOutput, out: os.Stdout}
 	log.SetFlags(0)
 	return l
 }
 
-func (l *Logger) log(level string, msg string, fields map[string]any) {
+// NewWithHandler returns a Logger that sends records to h, e.g. the handler
+// of an application's own *slog.Logger. Fields become record attributes.
+// Every level is passed on, so h's own level decides unless SetLevel is used.
+func NewWithHandler(h slog.Handler) *Logger {
+	l := &Logger{slog: slog.New(h)}
+	l.level.Set(slog.LevelDebug)
+	return l
+}
+
+// ParseLevel parses a --log-level value: debug, info, warn or error. Empty
+// means info.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q: want debug, info, warn or error", s)
+}
+
+// SetLevel drops messages below level. Warnings are still counted for
+// CheckStrict when filtered out. It is safe to call while other goroutines
+// log.
+func (l *Logger) SetLevel(level slog.Level) { l.level.Set(level) }
+
+func (l *Logger) log(level slog.Level, msg string, fields map[string]any) {
+	if level < l.level.Level() {
+		return
+	}
+	if l.slog != nil {
+		attrs := make([]slog.Attr, 0, len(fields))
+		for k, v := range fields {
+			attrs = append(attrs, slog.Any(k, v))
+		}
+		sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+		l.slog.LogAttrs(context.Background(), level, msg, attrs...)
+		return
+	}
+	name := level.String()
 	if !l.json {
 		if len(fields) > 0 {
 			b, _ := json.Marshal(fields)
-			fmt.Printf("[%s] %s %s\n", level, msg, string(b))
+			fmt.Fprintf(l.out, "[%s] %s %s\n", name, msg, string(b))
 		} else {
-			fmt.Printf("[%s] %s\n", level, msg)
+			fmt.Fprintf(l.out, "[%s] %s\n", name, msg)
 		}
 		return
 	}
 	payload := map[string]any{
 		"ts":    time.Now().UTC().Format(time.RFC3339Nano),
-		"level": level,
+		"level": name,
 		"msg":   msg,
 	}
 	for k, v := range fields {
 		payload[k] = v
 	}
-	enc := json.NewEncoder(os.Stdout)
+	enc := json.NewEncoder(l.out)
 	_ = enc.Encode(payload)
 }
 
-func (l *Logger) Info(msg string, fields map[string]any)  { l.log("INFO", msg, fields) }
-func (l *Logger) Warn(msg string, fields map[string]any)  { l.log("WARN", msg, fields) }
-func (l *Logger) Error(msg string, fields map[string]any) { l.log("ERROR", msg, fields) }
+// Debug logs per-migration detail such as progress, hidden unless the level
+// is debug.
+func (l *Logger) Debug(msg string, fields map[string]any) { l.log(slog.LevelDebug, msg, fields) }
+func (l *Logger) Info(msg string, fields map[string]any)  { l.log(slog.LevelInfo, msg, fields) }
+func (l *Logger) Warn(msg string, fields map[string]any) {
+	l.mu.Lock()
+	l.warnings++
+	l.mu.Unlock()
+	l.log(slog.LevelWarn, msg, fields)
+}
+func (l *Logger) Error(msg string, fields map[string]any) { l.log(slog.LevelError, msg, fields) }
 
 // JSONEnabled reports whether this logger is configured to emit JSON output.
 func (l *Logger) JSONEnabled() bool { return l.json }
+
+// Warnings returns how many warnings have been logged.
+func (l *Logger) Warnings() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.warnings
+}
+
+// CheckStrict returns ErrStrictWarnings if strict is set and any warning was
+// logged, so a command can fail instead of reporting success.
+func (l *Logger) CheckStrict(strict bool) error {
+	if n := l.Warnings(); strict && n > 0 {
+		return fmt.Errorf("%w: %d warning(s)", ErrStrictWarnings, n)
+	}
+	return nil
+}
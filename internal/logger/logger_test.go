@@ -1,6 +1,14 @@
 package logger
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
 
 func TestJSONEnabled(t *testing.T) {
 	l := New(false)
@@ -12,3 +20,87 @@ func TestJSONEnabled(t *testing.T) {
 		t.Fatal("expected true")
 	}
 }
+
+func TestCheckStrict(t *testing.T) {
+	l := New(false)
+	if err := l.CheckStrict(true); err != nil {
+		t.Fatalf("no warnings yet: %v", err)
+	}
+	l.Info("fine", nil)
+	l.Warn("out-of-order migration", map[string]any{"version": "1"})
+	if l.Warnings() != 1 {
+		t.Fatalf("expected 1 warning, got %d", l.Warnings())
+	}
+	if err := l.CheckStrict(false); err != nil {
+		t.Fatalf("non-strict must not fail: %v", err)
+	}
+	if err := l.CheckStrict(true); !errors.Is(err, ErrStrictWarnings) {
+		t.Fatalf("expected ErrStrictWarnings, got %v", err)
+	}
+}
+
+func TestSetLevelFilters(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(false)
+	l.out = &buf
+	l.Debug("progress", map[string]any{"version": "1"})
+	if buf.Len() != 0 {
+		t.Fatalf("debug shown at info level: %q", buf.String())
+	}
+	lvl, err := ParseLevel("debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetLevel(lvl)
+	l.Debug("progress", map[string]any{"version": "1"})
+	if buf.String() != "[DEBUG] progress {\"version\":\"1\"}\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	buf.Reset()
+	l.SetLevel(slog.LevelError)
+	l.Warn("filtered", nil)
+	if buf.Len() != 0 || l.Warnings() != 1 {
+		t.Fatalf("warn must be filtered but counted: %q, %d", buf.String(), l.Warnings())
+	}
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
+
+// TestSetLevelConcurrent changes the level while other goroutines log; run
+// with -race.
+func TestSetLevelConcurrent(t *testing.T) {
+	l := NewWithHandler(slog.NewTextHandler(io.Discard, nil))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Debug("progress", nil)
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		l.SetLevel(slog.Level(j % 8))
+	}
+	wg.Wait()
+}
+
+func TestNewWithHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWithHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	l.Debug("hidden by the handler", nil)
+	l.Warn("out-of-order migration", map[string]any{"version": "2", "name": "add_users"})
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Fatalf("handler level not honored: %q", out)
+	}
+	if !strings.Contains(out, `level=WARN msg="out-of-order migration" name=add_users version=2`) {
+		t.Fatalf("unexpected record: %q", out)
+	}
+	if l.Warnings() != 1 {
+		t.Fatalf("expected 1 warning, got %d", l.Warnings())
+	}
+}
@@ -0,0 +1,64 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ApplyUpAllOrNothing applies files in one transaction, recording their rows
+// in it too, and commits once at the end: either every migration is applied
+// or none is. Progress reports "applied" as each migration runs (not yet
+// durable), then "committed" for each once the transaction commits, or
+// "rolled-back" for each applied one if a later migration fails. On MySQL,
+// DDL commits implicitly, so this only protects data changes there; a
+// warning is sent to OnWarn. Approver, LockCheck and StatementTimeout apply
+// and directives needing their own connection are rejected, as in
+// ApplyUpTx; a denial commits the migrations before it. A dry run is the
+// same as ApplyUp's.
+func (r *Runner) ApplyUpAllOrNothing(ctx context.Context, files []FilePair, dryRun bool, progress func(stage string, fp FilePair, row *Row, err error)) ([]Row, error) {
+	if dryRun {
+		return r.ApplyUp(ctx, files, true, progress)
+	}
+	if d := r.Storage.driver(); !d.TransactionalDDL() {
+		r.warn("all-or-nothing on %s is best-effort: DDL statements commit implicitly and can't be rolled back", d.Name())
+	}
+	var opts *sql.TxOptions
+	if r.Isolation != sql.LevelDefault {
+		opts = &sql.TxOptions{Isolation: r.Isolation}
+	}
+	tx, err := r.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]FilePair, len(files))
+	for _, fp := range files {
+		byKey[Key(fp.Version, fp.Name)] = fp
+	}
+	report := func(stage string, rows []Row, err error) {
+		if progress == nil {
+			return
+		}
+		for i := range rows {
+			progress(stage, byKey[Key(rows[i].Version, rows[i].Name)], &rows[i], err)
+		}
+	}
+	applied, err := r.ApplyUpTx(ctx, tx, files, func(stage string, fp FilePair, row *Row, err error) {
+		if stage == "success" {
+			stage = "applied"
+		}
+		if progress != nil {
+			progress(stage, fp, row, err)
+		}
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		report("rolled-back", applied, err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		report("rolled-back", applied, err)
+		return nil, err
+	}
+	report("committed", applied, nil)
+	return applied, nil
+}
@@ -0,0 +1,215 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestApplyUpAllOrNothing(t *testing.T) {
+	files := []FilePair{
+		{Version: "1", Name: "a", UpBytes: []byte("CREATE TABLE a (id INT)")},
+		{Version: "2", Name: "b", UpBytes: []byte("CREATE TABLE b (id INT)")},
+	}
+	for _, fail := range []bool{false, true} {
+		sqldb, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO \"schema_migrations\" .* SELECT").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT execution_order").
+			WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+		if fail {
+			mock.ExpectExec("CREATE TABLE b").WillReturnError(errors.New("boom"))
+			mock.ExpectRollback()
+		} else {
+			mock.ExpectExec("CREATE TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("INSERT INTO \"schema_migrations\" .* SELECT").WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectQuery("SELECT execution_order").
+				WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(2)))
+			mock.ExpectCommit()
+		}
+
+		r := NewRunner(sqldb, "schema_migrations", "tester")
+		r.Storage.Driver = db.Postgres
+		var warned bool
+		r.OnWarn = func(string) { warned = true }
+		var stages []string
+		applied, err := r.ApplyUpAllOrNothing(context.Background(), files, false, func(stage string, fp FilePair, row *Row, err error) {
+			stages = append(stages, stage+":"+fp.Version)
+		})
+		want := "start:1 applied:1 start:2 applied:2 committed:1 committed:2"
+		if fail {
+			want = "start:1 applied:1 start:2 error:2 rolled-back:1"
+			if err == nil || applied != nil {
+				t.Fatalf("expected a failure with nothing applied, got %+v, %v", applied, err)
+			}
+		} else if err != nil || len(applied) != 2 {
+			t.Fatalf("apply: %+v, %v", applied, err)
+		}
+		if got := strings.Join(stages, " "); got != want {
+			t.Fatalf("stages (fail=%v) = %s, want %s", fail, got, want)
+		}
+		if warned {
+			t.Fatal("no warning expected with transactional DDL")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("expectations (fail=%v): %v", fail, err)
+		}
+		sqldb.Close()
+	}
+}
+
+func TestApplyUp_AllOrNothingField(t *testing.T) {
+	sqldb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqldb.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"schema_migrations\" .* SELECT").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	mock.ExpectCommit()
+
+	r := NewRunner(sqldb, "schema_migrations", "tester")
+	r.Storage.Driver = db.Postgres
+	r.AllOrNothing = true
+	applied, err := r.ApplyUp(context.Background(), []FilePair{{Version: "1", Name: "a", UpBytes: []byte("CREATE TABLE a (id INT)")}}, false, nil)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("apply: %+v, %v", applied, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUpAllOrNothing_WarnsOnMySQL(t *testing.T) {
+	sqldb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqldb.Close()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	r := NewRunner(sqldb, "schema_migrations", "tester")
+	var warnings []string
+	r.OnWarn = func(msg string) { warnings = append(warnings, msg) }
+	if _, err := r.ApplyUpAllOrNothing(context.Background(), nil, false, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "best-effort") {
+		t.Fatalf("warnings = %v", warnings)
+	}
+}
+
+func TestApplyUpTx_ApproverAndLockCheck(t *testing.T) {
+	files := []FilePair{
+		{Version: "1", Name: "a", UpBytes: []byte("INSERT INTO a VALUES (1)")},
+		{Version: "2", Name: "b", UpBytes: []byte("INSERT INTO b VALUES (1)")},
+	}
+	sqldb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqldb.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO a").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO `schema_migrations` .* SELECT").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	mock.ExpectCommit()
+
+	r := NewRunner(sqldb, "schema_migrations", "tester")
+	var checks int
+	r.LockCheck = func(context.Context) error { checks++; return nil }
+	r.Approver = ApproverFunc(func(_ context.Context, fp FilePair) (bool, error) { return fp.Version == "1", nil })
+	var stages []string
+	applied, err := r.ApplyUpAllOrNothing(context.Background(), files, false, func(stage string, fp FilePair, row *Row, err error) {
+		stages = append(stages, stage+":"+fp.Version)
+	})
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("apply: %+v, %v", applied, err)
+	}
+	if got, want := strings.Join(stages, " "), "start:1 applied:1 denied:2 committed:1"; got != want {
+		t.Fatalf("stages = %s, want %s", got, want)
+	}
+	if checks != 1 {
+		t.Fatalf("LockCheck ran %d times, want 1", checks)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUpTx_LockCheckFailureStops(t *testing.T) {
+	sqldb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqldb.Close()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	r := NewRunner(sqldb, "schema_migrations", "tester")
+	lost := errors.New("lock lost")
+	r.LockCheck = func(context.Context) error { return lost }
+	files := []FilePair{{Version: "1", Name: "a", UpBytes: []byte("INSERT INTO a VALUES (1)")}}
+	if _, err := r.ApplyUpAllOrNothing(context.Background(), files, false, nil); !errors.Is(err, lost) {
+		t.Fatalf("expected the LockCheck error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUpTx_StatementTimeout(t *testing.T) {
+	sqldb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqldb.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE big").WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	r := NewRunner(sqldb, "schema_migrations", "tester")
+	r.StatementTimeout = 20 * time.Millisecond
+	files := []FilePair{{Version: "1", Name: "slow", UpBytes: []byte("UPDATE big SET x = 1")}}
+	if _, err := r.ApplyUpAllOrNothing(context.Background(), files, false, nil); !errors.Is(err, ErrMigrationTimeout) {
+		t.Fatalf("expected ErrMigrationTimeout, got %v", err)
+	}
+}
+
+func TestApplyUpTx_RejectsConnectionDirectives(t *testing.T) {
+	for name, fp := range map[string]FilePair{
+		"fk-checks": {Version: "1", Name: "a", UpBytes: []byte("DELETE FROM a"), FKChecksOff: true},
+		"isolation": {Version: "1", Name: "a", UpBytes: []byte("DELETE FROM a"), Isolation: sql.LevelSerializable},
+	} {
+		sqldb, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		r := NewRunner(sqldb, "schema_migrations", "tester")
+		if _, err := r.ApplyUpAllOrNothing(context.Background(), []FilePair{fp}, false, nil); err == nil || !strings.Contains(err.Error(), "caller transaction") {
+			t.Fatalf("%s: expected a rejection, got %v", name, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: expectations: %v", name, err)
+		}
+		sqldb.Close()
+	}
+}
@@ -0,0 +1,53 @@
+package migrator
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+// AnalyzeResult is the outcome of analyzing one table.
+type AnalyzeResult struct {
+	Table string
+	Err   error
+}
+
+// Analyze runs the driver's ANALYZE statement (ANALYZE TABLE on MySQL) on
+// each table outside any migration transaction so the optimizer picks up
+// fresh statistics after schema changes. It is best-effort: failures are
+// reported per table and never abort the rest.
+func (r *Runner) Analyze(ctx context.Context, tables []string) []AnalyzeResult {
+	d := r.Storage.driver()
+	out := make([]AnalyzeResult, 0, len(tables))
+	for _, t := range tables {
+		_, err := r.DB.ExecContext(ctx, d.AnalyzeSQL(db.QuoteTable(d, t)))
+		out = append(out, AnalyzeResult{Table: t, Err: err})
+	}
+	return out
+}
+
+// analyzeAfterUp analyzes AnalyzeAfter, plus the tables changed by files
+// with AnalyzeAllChanged, once a batch has applied files.
+func (r *Runner) analyzeAfterUp(ctx context.Context, files []FilePair) {
+	if len(files) == 0 {
+		return
+	}
+	tables := append([]string(nil), r.AnalyzeAfter...)
+	if r.AnalyzeAllChanged {
+		seen := map[string]bool{}
+		for _, t := range tables {
+			seen[strings.ToLower(t)] = true
+		}
+		for _, t := range ChangedTables(files) {
+			if !seen[strings.ToLower(t)] {
+				tables = append(tables, t)
+			}
+		}
+	}
+	for _, res := range r.Analyze(ctx, tables) {
+		if res.Err != nil {
+			r.warn("analyze %s: %v", res.Table, res.Err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestAnalyzeIsBestEffort(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectExec("ANALYZE TABLE `users`").WillReturnError(errors.New("denied"))
+	mock.ExpectExec("ANALYZE TABLE `ops`.`audit`").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	res := r.Analyze(context.Background(), []string{"users", "ops.audit"})
+	if len(res) != 2 || res[0].Err == nil || res[1].Err != nil {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestAnalyzeUsesDriverSyntax(t *testing.T) {
+	for _, tc := range []struct {
+		driver db.Driver
+		want   string
+	}{
+		{db.Postgres, `ANALYZE "ops"."audit"`},
+		{db.SQLite, `ANALYZE "ops"."audit"`},
+	} {
+		sqldb, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		mock.ExpectExec(tc.want).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		r := NewRunner(sqldb, "schema_migrations", "tester")
+		r.Storage.Driver = tc.driver
+		if res := r.Analyze(context.Background(), []string{"ops.audit"}); res[0].Err != nil {
+			t.Fatalf("%s: analyze: %v", tc.driver.Name(), res[0].Err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: expectations: %v", tc.driver.Name(), err)
+		}
+		sqldb.Close()
+	}
+}
+
+func TestApplyUp_AnalyzesAfterBatch(t *testing.T) {
+	sqldb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqldb.Close()
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE orders ADD COLUMN note TEXT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	mock.ExpectExec("ANALYZE TABLE `users`").WillReturnError(errors.New("denied"))
+	mock.ExpectExec("ANALYZE TABLE `orders`").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r := NewRunner(sqldb, "schema_migrations", "tester")
+	r.AnalyzeAfter = []string{"users"}
+	r.AnalyzeAllChanged = true
+	var warnings []string
+	r.OnWarn = func(msg string) { warnings = append(warnings, msg) }
+	files := []FilePair{{Version: "20250101000000", Name: "note", UpBytes: []byte("ALTER TABLE orders ADD COLUMN note TEXT;"), Checksum: "x"}}
+	if _, err := r.ApplyUp(context.Background(), files, false, nil); err != nil {
+		t.Fatalf("a failed analyze must not fail the run: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "analyze users") {
+		t.Fatalf("expected a warning for the failed analyze, got %q", warnings)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package migrator
+
+import (
+	"os"
+	"strings"
+)
+
+// appliedByMax is the width of the applied_by column on MySQL and Postgres.
+const appliedByMax = 255
+
+// ciSystem describes where a CI system keeps the actor, commit and run of a
+// job. Detect is set only on that system's runners.
+type ciSystem struct {
+	name, detect, actor, sha, run string
+}
+
+var ciSystems = []ciSystem{
+	{"github", "GITHUB_ACTIONS", "GITHUB_ACTOR", "GITHUB_SHA", "GITHUB_RUN_ID"},
+	{"gitlab", "GITLAB_CI", "GITLAB_USER_LOGIN", "CI_COMMIT_SHA", "CI_PIPELINE_ID"},
+	{"circleci", "CIRCLECI", "CIRCLE_USERNAME", "CIRCLE_SHA1", "CIRCLE_BUILD_NUM"},
+	{"buildkite", "BUILDKITE", "BUILDKITE_BUILD_CREATOR", "BUILDKITE_COMMIT", "BUILDKITE_BUILD_NUMBER"},
+	{"jenkins", "JENKINS_URL", "BUILD_USER_ID", "GIT_COMMIT", "BUILD_NUMBER"},
+}
+
+// EnrichAppliedBy appends the host and, on a recognized CI system, the
+// job's actor, commit and run to who, e.g.
+// "runner@build-7 ci=github actor=alice sha=4f2a9c1e0b3d run=912". The
+// result is cut to fit the applied_by column.
+func EnrichAppliedBy(who string) string {
+	host, _ := os.Hostname()
+	return enrichAppliedBy(who, host, os.Getenv)
+}
+
+func enrichAppliedBy(who, host string, getenv func(string) string) string {
+	var b strings.Builder
+	b.WriteString(who)
+	if host != "" {
+		b.WriteString("@" + host)
+	}
+	for _, ci := range ciSystems {
+		if getenv(ci.detect) == "" {
+			continue
+		}
+		b.WriteString(" ci=" + ci.name)
+		if v := getenv(ci.actor); v != "" {
+			b.WriteString(" actor=" + v)
+		}
+		if v := getenv(ci.sha); v != "" {
+			if len(v) > 12 {
+				v = v[:12]
+			}
+			b.WriteString(" sha=" + v)
+		}
+		if v := getenv(ci.run); v != "" {
+			b.WriteString(" run=" + v)
+		}
+		break
+	}
+	out := b.String()
+	if len(out) > appliedByMax {
+		out = out[:appliedByMax]
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package migrator
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestEnrichAppliedBy(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	if got := enrichAppliedBy("runner", "build-7", env(nil)); got != "runner@build-7" {
+		t.Fatalf("outside CI: %q", got)
+	}
+	got := enrichAppliedBy("runner", "build-7", env(map[string]string{
+		"GITHUB_ACTIONS": "true", "GITHUB_ACTOR": "alice",
+		"GITHUB_SHA": "4f2a9c1e0b3d5a6b7c8d9e0f1a2b3c4d5e6f7a8b", "GITHUB_RUN_ID": "912",
+	}))
+	if got != "runner@build-7 ci=github actor=alice sha=4f2a9c1e0b3d run=912" {
+		t.Fatalf("github: %q", got)
+	}
+	got = enrichAppliedBy("deploy", "", env(map[string]string{"GITLAB_CI": "true", "CI_COMMIT_SHA": "abc123"}))
+	if got != "deploy ci=gitlab sha=abc123" {
+		t.Fatalf("gitlab: %q", got)
+	}
+	if got := enrichAppliedBy(strings.Repeat("x", 300), "h", env(nil)); len(got) != appliedByMax {
+		t.Fatalf("must fit the column, got %d bytes", len(got))
+	}
+}
+
+func TestApplyUp_AppliedByMeta(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_ACTOR", "alice")
+	t.Setenv("GITHUB_SHA", "")
+	t.Setenv("GITHUB_RUN_ID", "")
+	host, _ := os.Hostname()
+	want := "deployer@" + host + " ci=github actor=alice"
+	if host == "" {
+		want = "deployer ci=github actor=alice"
+	}
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").
+		WithArgs("1", "a", sqlmock.AnyArg(), sqlmock.AnyArg(), want, sqlmock.AnyArg(), "success", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	r := NewRunner(db, "schema_migrations", "deployer")
+	r.AppliedByMeta = true
+	applied, err := r.ApplyUp(context.Background(), []FilePair{{Version: "1", Name: "a", UpBytes: []byte("SELECT 1")}}, false, nil)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if applied[0].AppliedBy != want {
+		t.Fatalf("applied_by = %q, want %q", applied[0].AppliedBy, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package migrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Approver gates each migration in ApplyUp. Approve returning false leaves
+// the migration and everything after it pending and ends the run without an
+// error; returning an error aborts the run.
+type Approver interface {
+	Approve(ctx context.Context, fp FilePair) (bool, error)
+}
+
+// ApproverFunc adapts a function to Approver.
+type ApproverFunc func(ctx context.Context, fp FilePair) (bool, error)
+
+// Approve calls f.
+func (f ApproverFunc) Approve(ctx context.Context, fp FilePair) (bool, error) {
+	return f(ctx, fp)
+}
+
+// HTTPApprover asks an external service to approve each migration. It POSTs
+// {"version", "name", "checksum"} as JSON to URL: a 2xx response approves,
+// 403 denies, and anything else is an error.
+type HTTPApprover struct {
+	URL    string
+	Client *http.Client // nil uses a client with a 10s timeout
+}
+
+// Approve implements Approver.
+func (a *HTTPApprover) Approve(ctx context.Context, fp FilePair) (bool, error) {
+	body, err := json.Marshal(map[string]string{"version": fp.Version, "name": fp.Name, "checksum": fp.Checksum})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode/100 == 2:
+		return true, nil
+	case resp.StatusCode == http.StatusForbidden:
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return false, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+}
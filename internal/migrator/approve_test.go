@@ -0,0 +1,111 @@
+package migrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestApplyUp_ApproverDenialStopsRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	// nothing for 2 or 3: sqlmock fails the run if either is executed
+
+	files := []FilePair{
+		{Version: "1", Name: "a", UpBytes: []byte("CREATE TABLE a (id INT)")},
+		{Version: "2", Name: "b", UpBytes: []byte("CREATE TABLE b (id INT)")},
+		{Version: "3", Name: "c", UpBytes: []byte("CREATE TABLE c (id INT)")},
+	}
+	var asked []string
+	var stages []string
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.Approver = ApproverFunc(func(ctx context.Context, fp FilePair) (bool, error) {
+		asked = append(asked, fp.Version)
+		return fp.Version != "2", nil
+	})
+	applied, err := r.ApplyUp(context.Background(), files, false, func(stage string, fp FilePair, row *Row, err error) {
+		stages = append(stages, stage+":"+fp.Version)
+	})
+	if err != nil {
+		t.Fatalf("a denial must not be an error: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != "1" {
+		t.Fatalf("applied = %+v", applied)
+	}
+	if len(asked) != 2 {
+		t.Fatalf("approver asked for %v, want 1 and 2 only", asked)
+	}
+	if got := stages[len(stages)-1]; got != "denied:2" {
+		t.Fatalf("last stage = %s", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUp_ApproverErrorAborts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+
+	boom := errors.New("approval service down")
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.Approver = ApproverFunc(func(context.Context, FilePair) (bool, error) { return false, boom })
+	_, err = r.ApplyUp(context.Background(), []FilePair{{Version: "1", Name: "a", UpBytes: []byte("SELECT 1")}}, false, nil)
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected approver error, got %v", err)
+	}
+}
+
+func TestHTTPApprover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch body["version"] {
+		case "1":
+			w.WriteHeader(http.StatusOK)
+		case "2":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.Error(w, "unknown migration", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	a := &HTTPApprover{URL: srv.URL}
+	ctx := context.Background()
+	if ok, err := a.Approve(ctx, FilePair{Version: "1", Name: "a"}); !ok || err != nil {
+		t.Fatalf("expected approval, got %v, %v", ok, err)
+	}
+	if ok, err := a.Approve(ctx, FilePair{Version: "2", Name: "b"}); ok || err != nil {
+		t.Fatalf("expected denial, got %v, %v", ok, err)
+	}
+	// ApplyUp adds the "approval of" context, so the error carries only the response
+	if _, err := a.Approve(ctx, FilePair{Version: "3", Name: "c"}); err == nil || err.Error() != "500 Internal Server Error: unknown migration" {
+		t.Fatalf("expected the 500 response as the error, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package migrator
+
+import (
+	"context"
+	"strings"
+)
+
+// BaselineMismatch is a table a baselined migration creates that does not
+// exist in the target database.
+type BaselineMismatch struct {
+	Version string
+	Name    string
+	Table   string
+}
+
+// VerifyBaseline sanity-checks a fake baseline up to and including version:
+// every table created by those migrations' up SQL must already exist. It is
+// read-only and heuristic; mismatches are meant to be reported as warnings
+// before (or instead of) calling ForceBaseline.
+func (r *Runner) VerifyBaseline(ctx context.Context, all []FilePair, version string) ([]BaselineMismatch, error) {
+	var out []BaselineMismatch
+	for _, fp := range all {
+		if fp.Version > version {
+			continue
+		}
+		for _, table := range createdTables(string(fp.UpBytes)) {
+			ok, err := r.tableExists(ctx, table)
+			if err != nil {
+				return out, err
+			}
+			if !ok {
+				out = append(out, BaselineMismatch{Version: fp.Version, Name: fp.Name, Table: table})
+			}
+		}
+	}
+	return out, nil
+}
+
+// tableExists looks table, possibly schema-qualified, up in the catalog of
+// the runner's driver.
+func (r *Runner) tableExists(ctx context.Context, table string) (bool, error) {
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		schema, name = "", table
+	}
+	q, args := r.Storage.driver().TableExistsSQL(schema, name)
+	var n int
+	if err := r.DB.QueryRowContext(ctx, q, args...).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,70 @@
+package migrator
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestVerifyBaseline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	all := []FilePair{
+		{Version: "1", Name: "users", UpBytes: []byte("CREATE TABLE users (id INT);")},
+		{Version: "2", Name: "orders", UpBytes: []byte("CREATE TABLE orders (id INT);")},
+		{Version: "3", Name: "later", UpBytes: []byte("CREATE TABLE later (id INT);")},
+	}
+	count := func(n int) *sqlmock.Rows { return sqlmock.NewRows([]string{"n"}).AddRow(n) }
+	mock.ExpectQuery("information_schema.tables").WithArgs("users").WillReturnRows(count(1))
+	mock.ExpectQuery("information_schema.tables").WithArgs("orders").WillReturnRows(count(0))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	mismatches, err := r.VerifyBaseline(context.Background(), all, "2")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Table != "orders" || mismatches[0].Version != "2" {
+		t.Fatalf("unexpected mismatches: %+v", mismatches)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestVerifyBaseline_UsesDriverCatalog(t *testing.T) {
+	all := []FilePair{{Version: "1", Name: "users", UpBytes: []byte("CREATE TABLE users (id INT); CREATE TABLE audit.log (id INT);")}}
+	for _, tc := range []struct {
+		driver    db.Driver
+		query     string
+		qualified string
+		args      []driver.Value
+	}{
+		{db.Postgres, "table_schema = current_schema\\(\\) AND table_name = \\$1", "table_schema = \\$1 AND table_name = \\$2", []driver.Value{"audit", "log"}},
+		{db.SQLite, "FROM sqlite_master WHERE type = 'table' AND name = \\?", `FROM "audit".sqlite_master`, []driver.Value{"log"}},
+	} {
+		sqldb, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		count := func(n int) *sqlmock.Rows { return sqlmock.NewRows([]string{"n"}).AddRow(n) }
+		mock.ExpectQuery(tc.query).WithArgs("users").WillReturnRows(count(1))
+		mock.ExpectQuery(tc.qualified).WithArgs(tc.args...).WillReturnRows(count(1))
+
+		r := NewRunner(sqldb, "schema_migrations", "tester")
+		r.Storage.Driver = tc.driver
+		if _, err := r.VerifyBaseline(context.Background(), all, "1"); err != nil {
+			t.Fatalf("%s: verify: %v", tc.driver.Name(), err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: expectations: %v", tc.driver.Name(), err)
+		}
+		sqldb.Close()
+	}
+}
@@ -0,0 +1,119 @@
+package migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DirDiff compares two migration sources by file contents alone, e.g. a
+// feature branch against main. Keys are version:name.
+type DirDiff struct {
+	OnlyA   []string      `json:"only_a"`
+	OnlyB   []string      `json:"only_b"`
+	Changed []ChangedPair `json:"changed"`
+}
+
+// ChangedPair is a migration present in both sources whose up or down file
+// differs.
+type ChangedPair struct {
+	Key         string `json:"key"`
+	UpA         string `json:"up_checksum_a"`
+	UpB         string `json:"up_checksum_b"`
+	DownDiffers bool   `json:"down_differs"`
+}
+
+// Empty reports whether the sources hold the same migrations.
+func (d DirDiff) Empty() bool {
+	return len(d.OnlyA) == 0 && len(d.OnlyB) == 0 && len(d.Changed) == 0
+}
+
+// DiffSources discovers and loads a and b and reports the migrations only one
+// of them has and those whose files differ. No database is involved. Both
+// sources are checksummed with their own Checksum settings, so use the same
+// for each.
+func DiffSources(a, b FileSource) (DirDiff, error) {
+	filesA, err := loadSource(a)
+	if err != nil {
+		return DirDiff{}, err
+	}
+	filesB, err := loadSource(b)
+	if err != nil {
+		return DirDiff{}, err
+	}
+	inB := make(map[string]FilePair, len(filesB))
+	for _, fp := range filesB {
+		inB[Key(fp.Version, fp.Name)] = fp
+	}
+	var out DirDiff
+	seen := make(map[string]bool, len(filesA))
+	for _, fa := range filesA {
+		k := Key(fa.Version, fa.Name)
+		seen[k] = true
+		fb, ok := inB[k]
+		if !ok {
+			out.OnlyA = append(out.OnlyA, k)
+			continue
+		}
+		upDiffers := !strings.EqualFold(fa.Checksum, fb.Checksum)
+		downDiffers := !strings.EqualFold(fa.DownChecksum, fb.DownChecksum)
+		if upDiffers || downDiffers {
+			out.Changed = append(out.Changed, ChangedPair{Key: k, UpA: fa.Checksum, UpB: fb.Checksum, DownDiffers: downDiffers})
+		}
+	}
+	for _, fb := range filesB {
+		if k := Key(fb.Version, fb.Name); !seen[k] {
+			out.OnlyB = append(out.OnlyB, k)
+		}
+	}
+	return out, nil
+}
+
+// loadSource discovers src, failing on duplicates or missing halves, and
+// loads every file.
+func loadSource(src FileSource) ([]FilePair, error) {
+	d, err := Discover(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	if err := d.Load(); err != nil {
+		return nil, err
+	}
+	return d.Files, nil
+}
+
+// WriteDirDiff writes d as a list of "+ key" (only in b), "- key" (only in
+// a) and "~ key" (changed) lines, or as JSON when asJSON is set. nameA and
+// nameB label the sources in the plain form.
+func WriteDirDiff(w io.Writer, d DirDiff, nameA, nameB string, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	var b strings.Builder
+	if d.Empty() {
+		fmt.Fprintf(&b, "%s and %s have the same migrations\n", nameA, nameB)
+	}
+	for _, k := range d.OnlyA {
+		fmt.Fprintf(&b, "- %s (only in %s)\n", k, nameA)
+	}
+	for _, k := range d.OnlyB {
+		fmt.Fprintf(&b, "+ %s (only in %s)\n", k, nameB)
+	}
+	for _, c := range d.Changed {
+		what := "up file differs"
+		if strings.EqualFold(c.UpA, c.UpB) {
+			what = "down file differs"
+		} else if c.DownDiffers {
+			what = "up and down files differ"
+		}
+		fmt.Fprintf(&b, "~ %s (%s)\n", c.Key, what)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,59 @@
+package migrator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiffSources(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	writePair(t, a, "20250101000000", "init", "CREATE TABLE t(id INT);", "DROP TABLE t;")
+	writePair(t, b, "20250101000000", "init", "CREATE TABLE t(id INT);", "DROP TABLE t;")
+	writePair(t, a, "20250102000000", "removed", "CREATE TABLE r(id INT);", "DROP TABLE r;")
+	writePair(t, b, "20250103000000", "added", "CREATE TABLE n(id INT);", "DROP TABLE n;")
+	writePair(t, a, "20250104000000", "edited", "CREATE TABLE e(id INT);", "DROP TABLE e;")
+	writePair(t, b, "20250104000000", "edited", "CREATE TABLE e(id BIGINT);", "DROP TABLE e;")
+	writePair(t, a, "20250105000000", "down_only", "CREATE TABLE d(id INT);", "DROP TABLE d;")
+	writePair(t, b, "20250105000000", "down_only", "CREATE TABLE d(id INT);", "DROP TABLE IF EXISTS d;")
+
+	diff, err := DiffSources(FileSource{RootDir: a}, FileSource{RootDir: b})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(diff.OnlyA) != 1 || diff.OnlyA[0] != "20250102000000:removed" {
+		t.Fatalf("only a = %v", diff.OnlyA)
+	}
+	if len(diff.OnlyB) != 1 || diff.OnlyB[0] != "20250103000000:added" {
+		t.Fatalf("only b = %v", diff.OnlyB)
+	}
+	if len(diff.Changed) != 2 || diff.Changed[0].Key != "20250104000000:edited" || diff.Changed[0].DownDiffers ||
+		diff.Changed[1].Key != "20250105000000:down_only" || !diff.Changed[1].DownDiffers {
+		t.Fatalf("changed = %+v", diff.Changed)
+	}
+
+	var text strings.Builder
+	if err := WriteDirDiff(&text, diff, "main", "feature", false); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	want := "- 20250102000000:removed (only in main)\n" +
+		"+ 20250103000000:added (only in feature)\n" +
+		"~ 20250104000000:edited (up file differs)\n" +
+		"~ 20250105000000:down_only (down file differs)\n"
+	if text.String() != want {
+		t.Fatalf("text:\n%s\nwant:\n%s", text.String(), want)
+	}
+	var js strings.Builder
+	if err := WriteDirDiff(&js, diff, "main", "feature", true); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded DirDiff
+	if err := json.Unmarshal([]byte(js.String()), &decoded); err != nil || len(decoded.Changed) != 2 {
+		t.Fatalf("json round trip: %v %+v", err, decoded)
+	}
+
+	same, err := DiffSources(FileSource{RootDir: a}, FileSource{RootDir: a})
+	if err != nil || !same.Empty() {
+		t.Fatalf("same dir: %+v %v", same, err)
+	}
+}
@@ -0,0 +1,100 @@
+package migrator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const directivePrefix = "-- gomigratex:"
+
+// shortDirectivePrefix is accepted as an alias of directivePrefix.
+const shortDirectivePrefix = "-- migratex:"
+
+// directives holds `-- gomigratex:key: value` comments from the leading
+// comment block of a migration file.
+type directives map[string]string
+
+func parseDirectives(b []byte) directives {
+	d := directives{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break // directives must precede the first statement
+		}
+		rest, ok := strings.CutPrefix(line, directivePrefix)
+		if !ok {
+			if rest, ok = strings.CutPrefix(line, shortDirectivePrefix); !ok {
+				continue
+			}
+		}
+		key, val, _ := strings.Cut(rest, ":")
+		d[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return d
+}
+
+// positiveInt returns the directive as an integer > 0, or 0 when absent.
+func (d directives) positiveInt(key string) (int, error) {
+	v, ok := d[key]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s directive %q: want a positive integer", key, v)
+	}
+	return n, nil
+}
+
+// duration returns the directive parsed with time.ParseDuration, or 0 when absent.
+func (d directives) duration(key string) (time.Duration, error) {
+	v, ok := d[key]
+	if !ok {
+		return 0, nil
+	}
+	dur, err := time.ParseDuration(v)
+	if err != nil || dur < 0 {
+		return 0, fmt.Errorf("invalid %s directive %q: want a duration like 30s", key, v)
+	}
+	return dur, nil
+}
+
+// flag reports whether a valueless directive such as no-transaction is
+// present; an explicit true/false value is also accepted.
+func (d directives) flag(key string) (bool, error) {
+	v, ok := d[key]
+	if !ok {
+		return false, nil
+	}
+	if v == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s directive %q: want no value or true/false", key, v)
+	}
+	return b, nil
+}
+
+// off reports whether an on/off directive is set to off; absent means on.
+func (d directives) off(key string) (bool, error) {
+	v, ok := d[key]
+	if !ok {
+		return false, nil
+	}
+	switch strings.ToLower(v) {
+	case "on":
+		return false, nil
+	case "off":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid %s directive %q: want on or off", key, v)
+}
@@ -0,0 +1,35 @@
+package migrator
+
+import "testing"
+
+func TestParseDirectives(t *testing.T) {
+	src := []byte("-- ticket: OPS-1\n-- gomigratex:batch-commit: 1000\n\nINSERT INTO t VALUES (1);\n-- gomigratex:ignored: yes\n")
+	d := parseDirectives(src)
+	n, err := d.positiveInt("batch-commit")
+	if err != nil || n != 1000 {
+		t.Fatalf("batch-commit = %d, %v", n, err)
+	}
+	if _, ok := d["ignored"]; ok {
+		t.Fatal("directives after the first statement must be ignored")
+	}
+	if _, err := parseDirectives([]byte("-- gomigratex:batch-commit: zero\n")).positiveInt("batch-commit"); err == nil {
+		t.Fatal("expected error for invalid batch-commit")
+	}
+}
+
+func TestParseDirectivesNoTransaction(t *testing.T) {
+	for src, want := range map[string]bool{
+		"-- gomigratex:no-transaction\nCREATE INDEX CONCURRENTLY i ON t(id);": true,
+		"-- migratex:no-transaction\nCREATE INDEX CONCURRENTLY i ON t(id);":   true,
+		"-- gomigratex:no-transaction: false\nSELECT 1;":                      false,
+		"SELECT 1;\n-- gomigratex:no-transaction\n":                           false,
+	} {
+		got, err := parseDirectives([]byte(src)).flag("no-transaction")
+		if err != nil || got != want {
+			t.Errorf("%q: got %v, %v; want %v", src, got, err, want)
+		}
+	}
+	if _, err := parseDirectives([]byte("-- gomigratex:no-transaction: maybe\n")).flag("no-transaction"); err == nil {
+		t.Fatal("expected error for invalid flag value")
+	}
+}
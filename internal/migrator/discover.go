@@ -0,0 +1,246 @@
+package migrator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+	"github.com/mirajehossain/gomigratex/internal/fsutil"
+	"github.com/mirajehossain/gomigratex/internal/sqlsplit"
+)
+
+// Discovery is the filesystem view of a migration source, independent of any
+// database state.
+type Discovery struct {
+	Source  FileSource
+	Files   []FilePair       // valid pairs in version order; contents empty until Load
+	Ignored []fsutil.Ignored // entries left out, with reasons
+	// Overrides lists migrations replaced by a later layer; Changed is only
+	// known after Load.
+	Overrides []LayerOverride
+}
+
+// LayerOverride records a migration from one layer shadowed by a later
+// layer's file with the same version and name.
+type LayerOverride struct {
+	Key      string
+	Shadowed string // up file path of the replaced migration
+	By       string // up file path of the migration used instead
+	// Changed is true when the files differ, i.e. the override actually
+	// changes what runs rather than repeating the same migration.
+	Changed bool
+
+	shadowed FilePair
+}
+
+// Discover scans src for migration pairs without reading their contents.
+// Malformed entries (not matching the pattern, missing their other half,
+// duplicated) are reported in Ignored rather than failing; use Err to treat
+// them as fatal. Errors are returned only when the source can't be read.
+func Discover(src FileSource) (*Discovery, error) {
+	d := &Discovery{Source: src}
+	if (src.UpRootDir == "") != (src.DownRootDir == "") {
+		return nil, errors.New("up and down root directories must be set together")
+	}
+	if src.UpRootDir != "" && src.RootDir != "" {
+		return nil, errors.New("set either a root directory or separate up and down root directories, not both")
+	}
+	if src.RootDir != "" || src.UpRootDir != "" || src.FS != nil || (len(src.Inline) == 0 && len(src.Go) == 0 && len(src.Layers) == 0) {
+		if err := d.scan(0, src.FS, src.RootDir); err != nil {
+			return nil, err
+		}
+	}
+	for i, l := range src.Layers {
+		if err := d.scan(i+1, l.FS, l.RootDir); err != nil {
+			return nil, err
+		}
+	}
+	if len(src.Layers) > 0 {
+		sortFiles(d.Files)
+	}
+	if len(src.Inline) > 0 {
+		if err := d.mergeInline(src.Inline); err != nil {
+			return nil, err
+		}
+	}
+	if len(src.Go) > 0 {
+		if err := d.mergeGo(src.Go); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// scan adds the pairs found in one layer (0 is the base FS/RootDir). A pair
+// with the same version and name as an earlier layer's replaces it; the same
+// version under a different name is an error.
+func (d *Discovery) scan(layer int, fsys fs.FS, root string) error {
+	var rep *fsutil.Report
+	var err error
+	opts := fsutil.ScanOptions{Ext: d.Source.Ext, Dialect: d.Source.Dialect, Recursive: d.Source.Recursive}
+	split := layer == 0 && d.Source.UpRootDir != ""
+	switch {
+	case split && fsys != nil:
+		rep, err = fsutil.ScanSplitEmbeddedReportWith(fsys, d.Source.UpRootDir, d.Source.DownRootDir, opts)
+	case split:
+		rep, err = fsutil.ScanSplitDirReportWith(d.Source.UpRootDir, d.Source.DownRootDir, opts)
+	case fsys != nil:
+		rep, err = fsutil.ScanEmbeddedReportWith(fsys, root, opts)
+	default:
+		rep, err = fsutil.ScanDirReportWith(root, opts)
+	}
+	if err != nil {
+		return err
+	}
+	d.Ignored = append(d.Ignored, rep.Ignored...)
+	byKey := make(map[string]int, len(d.Files)) // earlier layers only
+	versions := make(map[string]string, len(d.Files))
+	for i, fp := range d.Files {
+		byKey[Key(fp.Version, fp.Name)] = i
+		versions[fp.Version] = fp.UpPath
+	}
+	for _, k := range fsutil.SortKeys(rep.Pairs) {
+		p := rep.Pairs[k]
+		fp := FilePair{Version: p.Version, Name: p.Name, UpPath: p.UpPath, DownPath: p.DownPath, layer: layer}
+		key := Key(p.Version, p.Name)
+		i, ok := byKey[key]
+		if !ok {
+			if prev, clash := versions[p.Version]; clash {
+				return fmt.Errorf("migration %s_%s in layer %d collides with %s", p.Version, p.Name, layer, prev)
+			}
+			d.Files = append(d.Files, fp)
+			continue
+		}
+		d.Overrides = append(d.Overrides, LayerOverride{Key: key, Shadowed: d.Files[i].UpPath, By: p.UpPath, shadowed: d.Files[i]})
+		d.Files[i] = fp
+	}
+	return nil
+}
+
+func (d *Discovery) mergeInline(inline []InlineMigration) error {
+	versions := map[string]string{}
+	for _, fp := range d.Files {
+		versions[fp.Version] = fp.UpPath
+	}
+	for _, m := range inline {
+		if m.Version == "" || m.Name == "" {
+			return fmt.Errorf("inline migration needs both version and name (got %q, %q)", m.Version, m.Name)
+		}
+		if prev, ok := versions[m.Version]; ok {
+			return fmt.Errorf("inline migration %s_%s collides with %s", m.Version, m.Name, prev)
+		}
+		versions[m.Version] = "inline migration " + m.Version + "_" + m.Name
+		d.Files = append(d.Files, FilePair{
+			Version: m.Version, Name: m.Name,
+			UpBytes: []byte(m.Up), DownBytes: []byte(m.Down), inline: true,
+		})
+	}
+	sortFiles(d.Files)
+	return nil
+}
+
+func sortFiles(files []FilePair) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Version == files[j].Version {
+			return files[i].Name < files[j].Name
+		}
+		return files[i].Version < files[j].Version
+	})
+}
+
+// Err returns the first duplicate or missing-pair problem, if any.
+func (d *Discovery) Err() error {
+	return (&fsutil.Report{Ignored: d.Ignored}).Err()
+}
+
+// Load reads every file's contents and fills in checksums and directives.
+func (d *Discovery) Load() error {
+	for i := range d.Files {
+		if err := d.load(&d.Files[i]); err != nil {
+			return err
+		}
+	}
+	if len(d.Overrides) == 0 {
+		return nil
+	}
+	loaded := make(map[string]FilePair, len(d.Files))
+	for _, fp := range d.Files {
+		loaded[Key(fp.Version, fp.Name)] = fp
+	}
+	for i := range d.Overrides {
+		o := &d.Overrides[i]
+		up, err := d.readFile(o.shadowed.layer, o.shadowed.UpPath)
+		if err != nil {
+			return err
+		}
+		down, err := d.readFile(o.shadowed.layer, o.shadowed.DownPath)
+		if err != nil {
+			return err
+		}
+		fp := loaded[o.Key]
+		o.Changed = !bytes.Equal(up, fp.UpBytes) || !bytes.Equal(down, fp.DownBytes)
+	}
+	return nil
+}
+
+func (d *Discovery) load(fp *FilePair) error {
+	var err error
+	if !fp.inline {
+		fp.UpBytes, err = d.readFile(fp.layer, fp.UpPath)
+		if err != nil {
+			return err
+		}
+		fp.DownBytes, err = d.readFile(fp.layer, fp.DownPath)
+		if err != nil {
+			return err
+		}
+	}
+	sum := d.Source.Checksum
+	if sum == nil {
+		sum = checksum.SHA256
+	}
+	fp.Checksum = sum(fp.UpBytes) // checksum on up file, before binding
+	fp.DownChecksum = sum(fp.DownBytes)
+	fp.UpParams = sqlsplit.NamedParams(string(fp.UpBytes))
+	fp.DownParams = sqlsplit.NamedParams(string(fp.DownBytes))
+	dirs := parseDirectives(fp.UpBytes)
+	if fp.BatchCommit, err = dirs.positiveInt("batch-commit"); err != nil {
+		return fmt.Errorf("%s: %w", fp.UpPath, err)
+	}
+	if fp.PauseAfter, err = dirs.duration("pause-after"); err != nil {
+		return fmt.Errorf("%s: %w", fp.UpPath, err)
+	}
+	if fp.EstDuration, err = dirs.duration("est-duration"); err != nil {
+		return fmt.Errorf("%s: %w", fp.UpPath, err)
+	}
+	if fp.NoTx, err = dirs.flag("no-transaction"); err != nil {
+		return fmt.Errorf("%s: %w", fp.UpPath, err)
+	}
+	if v, ok := dirs["isolation"]; ok {
+		if fp.Isolation, err = ParseIsolation(v); err != nil {
+			return fmt.Errorf("%s: invalid isolation directive: %w", fp.UpPath, err)
+		}
+	}
+	if fp.FKChecksOff, err = dirs.off("fk-checks"); err != nil {
+		return fmt.Errorf("%s: %w", fp.UpPath, err)
+	}
+	if fp.DownNoTx, err = parseDirectives(fp.DownBytes).flag("no-transaction"); err != nil {
+		return fmt.Errorf("%s: %w", fp.DownPath, err)
+	}
+	return nil
+}
+
+func (d *Discovery) readFile(layer int, path string) ([]byte, error) {
+	fsys := d.Source.FS
+	if layer > 0 {
+		fsys = d.Source.Layers[layer-1].FS
+	}
+	if fsys != nil {
+		return fs.ReadFile(fsys, path)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,224 @@
+package migrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+	"github.com/mirajehossain/gomigratex/internal/fsutil"
+)
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "20250102000000_orphan.up.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if len(d.Files) != 1 || d.Files[0].Name != "init" {
+		t.Fatalf("unexpected files: %+v", d.Files)
+	}
+	if d.Files[0].UpBytes != nil {
+		t.Fatal("contents must not be read before Load")
+	}
+	reasons := map[string]string{}
+	for _, ig := range d.Ignored {
+		reasons[filepath.Base(ig.Path)] = ig.Reason
+	}
+	if reasons["README.md"] != fsutil.ReasonPattern || reasons["20250102000000_orphan.up.sql"] != fsutil.ReasonMissingPair {
+		t.Fatalf("unexpected ignored entries: %+v", d.Ignored)
+	}
+	if d.Err() == nil {
+		t.Fatal("missing pair should make Err non-nil")
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if d.Files[0].Checksum == "" || string(d.Files[0].DownBytes) != "DROP TABLE t1;" {
+		t.Fatalf("load did not fill contents: %+v", d.Files[0])
+	}
+
+	if _, err := Discover(FileSource{RootDir: filepath.Join(dir, "missing")}); err == nil {
+		t.Fatal("expected error for unreadable source")
+	}
+}
+
+func TestDiscoverInline(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250102000000", "files", "CREATE TABLE f(id INT);", "DROP TABLE f;")
+
+	inline := []InlineMigration{
+		{Version: "20250101000000", Name: "first", Up: "CREATE TABLE a(id INT);", Down: "DROP TABLE a;"},
+		{Version: "20250103000000", Name: "last", Up: "CREATE TABLE c(id INT);", Down: "DROP TABLE c;"},
+	}
+	d, err := Discover(FileSource{RootDir: dir, Inline: inline})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(d.Files) != 3 || d.Files[0].Name != "first" || d.Files[1].Name != "files" || d.Files[2].Name != "last" {
+		t.Fatalf("unexpected merged order: %+v", d.Files)
+	}
+	if d.Files[0].Checksum != checksum.SHA256([]byte("CREATE TABLE a(id INT);")) {
+		t.Fatal("inline checksum must be computed over inline content")
+	}
+
+	// inline only
+	d, err = Discover(FileSource{Inline: inline})
+	if err != nil || len(d.Files) != 2 {
+		t.Fatalf("inline-only discover: %v, %+v", err, d)
+	}
+
+	// version collision
+	inline = append(inline, InlineMigration{Version: "20250102000000", Name: "dup", Up: "SELECT 1;"})
+	if _, err := Discover(FileSource{RootDir: dir, Inline: inline}); err == nil {
+		t.Fatal("expected collision error")
+	}
+}
+
+func TestDiscoverArbitraryFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"gen/20250101000000_init.up.sql":   {Data: []byte("CREATE TABLE t(id INT);")},
+		"gen/20250101000000_init.down.sql": {Data: []byte("DROP TABLE t;")},
+		"20250102000000_root.up.sql":       {Data: []byte("SELECT 1;")},
+		"20250102000000_root.down.sql":     {Data: []byte("SELECT 1;")},
+	}
+	// Embedded is not set: a non-nil FS is used regardless.
+	d, err := Discover(FileSource{FS: fsys, RootDir: "gen"})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(d.Files) != 1 || string(d.Files[0].UpBytes) != "CREATE TABLE t(id INT);" {
+		t.Fatalf("unexpected files: %+v", d.Files)
+	}
+
+	d, err = Discover(FileSource{FS: fsys})
+	if err != nil {
+		t.Fatalf("discover root: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load root: %v", err)
+	}
+	if len(d.Files) != 1 || d.Files[0].Name != "root" {
+		t.Fatalf("unexpected root files: %+v", d.Files)
+	}
+}
+
+func TestDiscoverLayers(t *testing.T) {
+	base := fstest.MapFS{
+		"20250101000000_init.up.sql":    {Data: []byte("CREATE TABLE t(id INT);")},
+		"20250101000000_init.down.sql":  {Data: []byte("DROP TABLE t;")},
+		"20250102000000_seed.up.sql":    {Data: []byte("INSERT INTO t VALUES (1);")},
+		"20250102000000_seed.down.sql":  {Data: []byte("DELETE FROM t;")},
+		"20250103000000_index.up.sql":   {Data: []byte("CREATE INDEX i ON t(id);")},
+		"20250103000000_index.down.sql": {Data: []byte("DROP INDEX i ON t;")},
+	}
+	dir := t.TempDir()
+	writePair(t, dir, "20250102000000", "seed", "INSERT INTO t VALUES (2);", "DELETE FROM t;")
+	writePair(t, dir, "20250103000000", "index", "CREATE INDEX i ON t(id);", "DROP INDEX i ON t;")
+	writePair(t, dir, "20250104000000", "extra", "CREATE TABLE extra(id INT);", "DROP TABLE extra;")
+
+	d, err := Discover(FileSource{FS: base, Layers: []Layer{{RootDir: dir}}})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var got []string
+	for _, fp := range d.Files {
+		got = append(got, fp.Name+"="+string(fp.UpBytes))
+	}
+	want := []string{
+		"init=CREATE TABLE t(id INT);",
+		"seed=INSERT INTO t VALUES (2);",
+		"index=CREATE INDEX i ON t(id);",
+		"extra=CREATE TABLE extra(id INT);",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("files = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("files = %q, want %q", got, want)
+		}
+	}
+	if len(d.Overrides) != 2 {
+		t.Fatalf("expected 2 overrides, got %+v", d.Overrides)
+	}
+	for _, o := range d.Overrides {
+		if changed := o.Key == Key("20250102000000", "seed"); o.Changed != changed {
+			t.Fatalf("override %s: Changed = %v", o.Key, o.Changed)
+		}
+		if filepath.Dir(o.By) != dir {
+			t.Fatalf("override %s: By = %s", o.Key, o.By)
+		}
+	}
+}
+
+func TestDiscoverLayersVersionCollision(t *testing.T) {
+	base := fstest.MapFS{
+		"20250101000000_init.up.sql":   {Data: []byte("CREATE TABLE t(id INT);")},
+		"20250101000000_init.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "other", "SELECT 1;", "SELECT 1;")
+	if _, err := Discover(FileSource{FS: base, Layers: []Layer{{RootDir: dir}}}); err == nil {
+		t.Fatal("expected collision error")
+	}
+}
+
+func TestDiscoverSplitRoots(t *testing.T) {
+	dir := t.TempDir()
+	upDir, downDir := filepath.Join(dir, "up"), filepath.Join(dir, "down")
+	for path, body := range map[string]string{
+		filepath.Join(upDir, "20250101000000_init.up.sql"):     "CREATE TABLE t1(id INT);",
+		filepath.Join(downDir, "20250101000000_init.down.sql"): "DROP TABLE t1;",
+		filepath.Join(upDir, "20250102000000_more.up.sql"):     "CREATE TABLE t2(id INT);",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := Discover(FileSource{UpRootDir: upDir, DownRootDir: downDir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if len(d.Files) != 1 || d.Files[0].DownPath != filepath.Join(downDir, "20250101000000_init.down.sql") {
+		t.Fatalf("unexpected files: %+v", d.Files)
+	}
+	if err := d.Err(); err == nil || err.Error() != "missing pair for 20250102000000:more: no down file under "+downDir {
+		t.Fatalf("expected incomplete pairing error, got %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if string(d.Files[0].DownBytes) != "DROP TABLE t1;" {
+		t.Fatalf("down not loaded from the down root: %q", d.Files[0].DownBytes)
+	}
+
+	if _, err := Discover(FileSource{UpRootDir: upDir}); err == nil {
+		t.Fatal("expected an error for an up root without a down root")
+	}
+	if _, err := Discover(FileSource{RootDir: dir, UpRootDir: upDir, DownRootDir: downDir}); err == nil {
+		t.Fatal("expected an error for a root dir with split roots")
+	}
+}
@@ -0,0 +1,58 @@
+package migrator
+
+import (
+	"context"
+	"sort"
+)
+
+// DownAll reverts every applied migration, latest first, one at a time.
+// Before each revert the next row is re-read from the tracking table
+// (LastApplied), so an interrupted run can simply be restarted: it resumes
+// from whatever is still applied. checkpoint, if non-nil, is called after
+// each revert has been committed and its row deleted. Returns the number of
+// migrations reverted.
+func (r *Runner) DownAll(ctx context.Context, lookup map[string]FilePair, dryRun bool, progress func(stage string, fp FilePair, row *Row, err error), checkpoint func(reverted Row)) (int, error) {
+	if dryRun {
+		rows, err := r.appliedNewestFirst(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return len(rows), r.ApplyDown(ctx, rows, lookup, true, progress)
+	}
+	n := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		last, err := r.LastApplied(ctx, 1)
+		if err != nil {
+			return n, err
+		}
+		if len(last) == 0 {
+			return n, nil
+		}
+		if err := r.ApplyDown(ctx, last, lookup, false, progress); err != nil {
+			return n, err
+		}
+		n++
+		if checkpoint != nil {
+			checkpoint(last[0])
+		}
+	}
+}
+
+// appliedNewestFirst returns successful rows in reverse execution order.
+func (r *Runner) appliedNewestFirst(ctx context.Context) ([]Row, error) {
+	all, err := r.Storage.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, 0, len(all))
+	for _, row := range all {
+		if row.Status == "success" {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ExecutionOrder > rows[j].ExecutionOrder })
+	return rows, nil
+}
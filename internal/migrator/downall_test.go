@@ -0,0 +1,65 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDownAll_ResumesAfterInterruption(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	last := func(version, name string, order int64) {
+		mock.ExpectQuery("SELECT version, name, checksum.*ORDER BY execution_order DESC LIMIT").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(version, name, "x", time.Now(), "tester", int64(1), "success", order, "dev", nil))
+	}
+	lookup := map[string]FilePair{
+		"1:a": {Version: "1", Name: "a", DownBytes: []byte("DROP TABLE a;")},
+		"2:b": {Version: "2", Name: "b", DownBytes: []byte("DROP TABLE b;")},
+	}
+	r := NewRunner(db, "schema_migrations", "tester")
+	var checkpoints []string
+	checkpoint := func(row Row) { checkpoints = append(checkpoints, Key(row.Version, row.Name)) }
+
+	// first run: b reverts, then a fails (interruption)
+	last("2", "b", 2)
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM `schema_migrations`").WillReturnResult(sqlmock.NewResult(0, 1))
+	last("1", "a", 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE a").WillReturnError(errors.New("connection lost"))
+	mock.ExpectRollback()
+
+	n, err := r.DownAll(context.Background(), lookup, false, nil, checkpoint)
+	if err == nil || n != 1 {
+		t.Fatalf("expected interruption after 1 revert, got n=%d err=%v", n, err)
+	}
+
+	// resume: ordering is recomputed from what's still applied
+	last("1", "a", 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM `schema_migrations`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns))
+
+	n, err = r.DownAll(context.Background(), lookup, false, nil, checkpoint)
+	if err != nil || n != 1 {
+		t.Fatalf("resume: n=%d err=%v", n, err)
+	}
+	if len(checkpoints) != 2 || checkpoints[0] != "2:b" || checkpoints[1] != "1:a" {
+		t.Fatalf("unexpected checkpoints: %v", checkpoints)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DryRunFormat selects how WriteDryRun renders the planned SQL.
+type DryRunFormat string
+
+const (
+	// DryRunText lists each statement under a header naming its migration,
+	// for review.
+	DryRunText DryRunFormat = "text"
+	// DryRunSQL writes one script, each statement terminated, that can be
+	// saved and run by hand. Statements with parameters keep their
+	// placeholders, so a script using params is not runnable as is.
+	DryRunSQL DryRunFormat = "sql"
+)
+
+// ParseDryRunFormat validates a dry-run format; empty means DryRunText.
+func ParseDryRunFormat(s string) (DryRunFormat, error) {
+	switch DryRunFormat(s) {
+	case "":
+		return DryRunText, nil
+	case DryRunText, DryRunSQL:
+		return DryRunFormat(s), nil
+	}
+	return "", fmt.Errorf("invalid dry-run format %q: want text or sql", s)
+}
+
+// WriteDryRun writes the statements ApplyUp would execute for files, split
+// and with parameters bound as they would be sent. Bind values are shown in
+// a comment next to the statement using them. Nothing is executed.
+func (r *Runner) WriteDryRun(w io.Writer, files []FilePair, format DryRunFormat) error {
+	for _, fp := range files {
+		if err := r.checkParams(fp, fp.UpBytes); err != nil {
+			return err
+		}
+	}
+	for i, fp := range files {
+		stmts, err := r.statements(fp.UpBytes, true)
+		if err != nil {
+			return err
+		}
+		if format == DryRunSQL {
+			err = r.writeScript(w, fp, stmts)
+		} else {
+			err = writeText(w, fp, stmts, i == 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeText(w io.Writer, fp FilePair, stmts []stmt, first bool) error {
+	var b strings.Builder
+	if !first {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "==> %s %s (%d statements)\n", fp.Version, fp.Name, len(stmts))
+	for i, s := range stmts {
+		fmt.Fprintf(&b, "-- [%d]%s\n%s\n", i+1, bindComment(s), s.query)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeScript writes fp as part of a runnable script. On MySQL a statement
+// containing ';' (a routine or trigger body) is wrapped in DELIMITER so the
+// mysql client doesn't split it.
+func (r *Runner) writeScript(w io.Writer, fp FilePair, stmts []stmt) error {
+	mysql := r.Storage.driver().Name() == "mysql"
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- %s_%s\n", fp.Version, fp.Name)
+	for _, s := range stmts {
+		if c := bindComment(s); c != "" {
+			fmt.Fprintf(&b, "--%s (replace the placeholders before running)\n", c)
+		}
+		q := s.query
+		if mysql && strings.Contains(q, ";") {
+			fmt.Fprintf(&b, "DELIMITER $$\n%s$$\nDELIMITER ;\n", q)
+			continue
+		}
+		fmt.Fprintf(&b, "%s;\n", q)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// bindComment describes a statement's bind values, or "" without any.
+func bindComment(s stmt) string {
+	if len(s.args) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" binds: %v", s.args)
+}
+
+// DryRunSummary closes a dry run: how much would run and for roughly how long
+// the advisory lock would be held.
+type DryRunSummary struct {
+	Migrations int `json:"migrations"`
+	// EstimatedMS is the sum of EstimatePlan's per-migration estimates.
+	EstimatedMS int64 `json:"estimated_ms"`
+	// LockHoldMS adds the waits ApplyUp makes between migrations while
+	// holding the lock (PauseBetween and pause-after directives).
+	LockHoldMS int64 `json:"estimated_lock_hold_ms"`
+}
+
+// SummarizeDryRun estimates files against history like EstimatePlan and
+// adds the configured waits to get the expected lock hold time. Replica lag
+// waits can't be predicted and are not included.
+func (r *Runner) SummarizeDryRun(files []FilePair, history map[string]Row) DryRunSummary {
+	estimates, hold := EstimatePlan(files, history, r.PauseBetween)
+	var work time.Duration
+	for _, e := range estimates {
+		work += e.Duration
+	}
+	return DryRunSummary{Migrations: len(files), EstimatedMS: work.Milliseconds(), LockHoldMS: hold.Milliseconds()}
+}
+
+// WriteDryRunSummary writes s as a closing line, or as one JSON object when
+// asJSON is set.
+func WriteDryRunSummary(w io.Writer, s DryRunSummary, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(s)
+	}
+	ms := func(n int64) time.Duration { return time.Duration(n) * time.Millisecond }
+	_, err := fmt.Fprintf(w, "-- %d migrations, estimated %s, advisory lock held for about %s\n", s.Migrations, ms(s.EstimatedMS), ms(s.LockHoldMS))
+	return err
+}
@@ -0,0 +1,83 @@
+package migrator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDryRun(t *testing.T) {
+	r := NewRunner(nil, "schema_migrations", "tester")
+	r.Params = map[string]any{"tenant": "t-1"}
+	files := []FilePair{
+		{Version: "20250101000000", Name: "init", UpBytes: []byte("CREATE TABLE t1(id INT);\nCREATE TABLE t2(id INT);")},
+		{Version: "20250102000000", Name: "seed", UpBytes: []byte("INSERT INTO t1 VALUES (:tenant);"), UpParams: []string{"tenant"}},
+		{Version: "20250103000000", Name: "proc", UpBytes: []byte("DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END$$\nDELIMITER ;\n")},
+	}
+
+	var text strings.Builder
+	if err := r.WriteDryRun(&text, files, DryRunText); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	for _, want := range []string{
+		"==> 20250101000000 init (2 statements)\n-- [1]\nCREATE TABLE t1(id INT)\n-- [2]\nCREATE TABLE t2(id INT)\n",
+		"==> 20250102000000 seed (1 statements)\n-- [1] binds: [t-1]\nINSERT INTO t1 VALUES (?)\n",
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var script strings.Builder
+	if err := r.WriteDryRun(&script, files, DryRunSQL); err != nil {
+		t.Fatalf("sql: %v", err)
+	}
+	want := "-- 20250101000000_init\nCREATE TABLE t1(id INT);\nCREATE TABLE t2(id INT);\n\n" +
+		"-- 20250102000000_seed\n-- binds: [t-1] (replace the placeholders before running)\nINSERT INTO t1 VALUES (?);\n\n" +
+		"-- 20250103000000_proc\nDELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END$$\nDELIMITER ;\n\n"
+	if script.String() != want {
+		t.Fatalf("sql output:\n%s\nwant:\n%s", script.String(), want)
+	}
+
+	r.Params = nil
+	if err := r.WriteDryRun(&script, files, DryRunSQL); !errors.Is(err, ErrMissingParam) {
+		t.Fatalf("expected ErrMissingParam, got %v", err)
+	}
+	if _, err := ParseDryRunFormat("yaml"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestDryRunSummary_LockHold(t *testing.T) {
+	r := NewRunner(nil, "schema_migrations", "tester")
+	r.PauseBetween = 2 * time.Second
+	files := []FilePair{
+		{Version: "1", Name: "a", EstDuration: time.Minute},
+		{Version: "2", Name: "b", PauseAfter: 10 * time.Second},
+		{Version: "3", Name: "c", EstDuration: 30 * time.Second},
+	}
+	history := map[string]Row{
+		"2:b": {Version: "2", Name: "b", DurationMS: 5000, Status: "success"},
+	}
+	s := r.SummarizeDryRun(files, history)
+	// 60s + 5s + 30s of work, plus 2s after a and 10s after b
+	if s.Migrations != 3 || s.EstimatedMS != 95000 || s.LockHoldMS != 107000 {
+		t.Fatalf("summary = %+v", s)
+	}
+
+	var text strings.Builder
+	if err := WriteDryRunSummary(&text, s, false); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	if want := "-- 3 migrations, estimated 1m35s, advisory lock held for about 1m47s\n"; text.String() != want {
+		t.Fatalf("text = %q, want %q", text.String(), want)
+	}
+	var js strings.Builder
+	if err := WriteDryRunSummary(&js, s, true); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if want := `{"migrations":3,"estimated_ms":95000,"estimated_lock_hold_ms":107000}` + "\n"; js.String() != want {
+		t.Fatalf("json = %q, want %q", js.String(), want)
+	}
+}
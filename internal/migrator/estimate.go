@@ -0,0 +1,102 @@
+package migrator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// EstimateSource says where a migration's estimated duration came from.
+type EstimateSource string
+
+const (
+	// EstimateDirective is the file's `-- gomigratex:est-duration` value.
+	EstimateDirective EstimateSource = "directive"
+	// EstimateHistory is the duration recorded for the same migration,
+	// e.g. in another environment's applied rows.
+	EstimateHistory EstimateSource = "history"
+	// EstimateAverage is the mean duration of every successfully recorded
+	// migration, used when nothing specific is known.
+	EstimateAverage EstimateSource = "average"
+	// EstimateUnknown means there was no directive and no history at all.
+	EstimateUnknown EstimateSource = "unknown"
+)
+
+// Estimate is the expected duration of one pending migration.
+type Estimate struct {
+	Version  string
+	Name     string
+	Duration time.Duration
+	Source   EstimateSource
+}
+
+// EstimatePlan estimates how long applying pending will take, for scheduling
+// a maintenance window. Each migration uses, in order of preference, its
+// est-duration directive, the duration recorded for it in history, or the
+// average duration of history. Only successful rows count. history is keyed like
+// Storage.GetAll; pass the target's rows, or another environment's imported
+// with ReadApplied. The total includes the waits ApplyUp makes between
+// migrations: each file's PauseAfter, or pauseBetween (Runner.PauseBetween)
+// without one; nothing is added after the last. It is an estimate: data
+// volumes and load differ between runs.
+func EstimatePlan(pending []FilePair, history map[string]Row, pauseBetween time.Duration) ([]Estimate, time.Duration) {
+	var sum time.Duration
+	var n int64
+	for _, row := range history {
+		if row.Status == "success" && row.DurationMS > 0 {
+			sum += time.Duration(row.DurationMS) * time.Millisecond
+			n++
+		}
+	}
+	var avg time.Duration
+	if n > 0 {
+		avg = sum / time.Duration(n)
+	}
+
+	out := make([]Estimate, 0, len(pending))
+	var total time.Duration
+	for i, fp := range pending {
+		e := Estimate{Version: fp.Version, Name: fp.Name, Source: EstimateUnknown}
+		row := history[Key(fp.Version, fp.Name)]
+		if fp.EstDuration > 0 {
+			e.Duration, e.Source = fp.EstDuration, EstimateDirective
+		} else if row.Status == "success" && row.DurationMS > 0 {
+			e.Duration, e.Source = time.Duration(row.DurationMS)*time.Millisecond, EstimateHistory
+		} else if n > 0 {
+			e.Duration, e.Source = avg, EstimateAverage
+		}
+		total += e.Duration
+		if i < len(pending)-1 {
+			total += pauseAfter(fp, pauseBetween)
+		}
+		out = append(out, e)
+	}
+	return out, total
+}
+
+// pauseAfter is how long ApplyUp waits after fp when another migration
+// follows: its pause-after directive, or between without one.
+func pauseAfter(fp FilePair, between time.Duration) time.Duration {
+	if fp.PauseAfter > 0 {
+		return fp.PauseAfter
+	}
+	return between
+}
+
+// WriteEstimate writes estimates as a table followed by their total.
+func WriteEstimate(w io.Writer, estimates []Estimate, total time.Duration) error {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tESTIMATE\tSOURCE")
+	for _, e := range estimates {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Version, e.Name, e.Duration.Round(time.Millisecond), e.Source)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(&b, "Total: %s for %d migrations\n", total.Round(time.Millisecond), len(estimates))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,75 @@
+package migrator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimatePlan_MixesHistoryAndDirectives(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "backfill", "-- gomigratex:est-duration: 5m\nUPDATE t SET x = 1;", "")
+	writePair(t, dir, "20250102000000", "staged", "ALTER TABLE t ADD c INT;", "")
+	writePair(t, dir, "20250103000000", "fresh", "CREATE TABLE u (id INT);", "")
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	history := map[string]Row{
+		"20240101000000:old_a":  {Version: "20240101000000", Name: "old_a", DurationMS: 1000, Status: "success"},
+		"20240102000000:old_b":  {Version: "20240102000000", Name: "old_b", DurationMS: 3000, Status: "success"},
+		"20250102000000:staged": {Version: "20250102000000", Name: "staged", DurationMS: 8000, Status: "success"},
+		"20240103000000:broken": {Version: "20240103000000", Name: "broken", DurationMS: 90000, Status: "failed"},
+	}
+
+	got, total := EstimatePlan(d.Files, history, 0)
+	want := []Estimate{
+		{Version: "20250101000000", Name: "backfill", Duration: 5 * time.Minute, Source: EstimateDirective},
+		{Version: "20250102000000", Name: "staged", Duration: 8 * time.Second, Source: EstimateHistory},
+		{Version: "20250103000000", Name: "fresh", Duration: 4 * time.Second, Source: EstimateAverage},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d estimates, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("estimate %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if total != 5*time.Minute+12*time.Second {
+		t.Fatalf("total = %s", total)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteEstimate(&buf, got, total); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Total: 5m12s for 3 migrations") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestEstimatePlan_NoHistory(t *testing.T) {
+	got, total := EstimatePlan([]FilePair{{Version: "1", Name: "a", PauseAfter: time.Second}}, nil, 0)
+	if got[0].Source != EstimateUnknown || total != 0 {
+		t.Fatalf("got %+v, total %s", got, total)
+	}
+}
+
+func TestEstimatePlan_PausesOnlyBetweenMigrations(t *testing.T) {
+	files := []FilePair{
+		{Version: "1", Name: "a", EstDuration: time.Second},
+		{Version: "2", Name: "b", EstDuration: time.Second, PauseAfter: time.Minute},
+		{Version: "3", Name: "c", EstDuration: time.Second, PauseAfter: time.Hour},
+	}
+	// a waits PauseBetween, b its own pause-after, and c is last so its
+	// pause-after never runs
+	_, total := EstimatePlan(files, nil, 10*time.Second)
+	if want := 3*time.Second + 10*time.Second + time.Minute; total != want {
+		t.Fatalf("total = %s, want %s", total, want)
+	}
+}
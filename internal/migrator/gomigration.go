@@ -0,0 +1,127 @@
+package migrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// GoMigrationFunc is one direction of a Go migration. It runs in a
+// transaction the runner commits when it returns nil.
+type GoMigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+// GoMigration is a migration written in Go, for changes SQL can't express
+// well such as backfills that call application code. It sorts by Version
+// with SQL files and is tracked the same way.
+//
+// Nothing about a function can be checksummed, so drift detection uses
+// Source, typically the migration's own file embedded with go:embed, or
+// failing that Revision, a string to change whenever Up changes. With
+// neither, only the version and name are checksummed.
+type GoMigration struct {
+	Version  string
+	Name     string
+	Up       GoMigrationFunc
+	Down     GoMigrationFunc // nil if the migration can't be reverted
+	Revision string
+	Source   []byte
+}
+
+var (
+	goRegistryMu sync.Mutex
+	goRegistry   []GoMigration
+)
+
+// RegisterGoMigration adds a Go migration that DiscoverAndPlan merges with
+// every source it plans. Call it from an init function. It panics on a
+// missing version, name or up function, or on a version registered twice.
+func RegisterGoMigration(version, name string, up, down GoMigrationFunc) {
+	RegisterGoMigrationWith(GoMigration{Version: version, Name: name, Up: up, Down: down})
+}
+
+// RegisterGoMigrationWith is RegisterGoMigration for a migration with a
+// Revision or Source.
+func RegisterGoMigrationWith(m GoMigration) {
+	if err := m.validate(); err != nil {
+		panic(err)
+	}
+	goRegistryMu.Lock()
+	defer goRegistryMu.Unlock()
+	for _, prev := range goRegistry {
+		if prev.Version == m.Version {
+			panic(fmt.Sprintf("go migration %s_%s: version already registered by %s_%s", m.Version, m.Name, prev.Version, prev.Name))
+		}
+	}
+	goRegistry = append(goRegistry, m)
+}
+
+// RegisteredGoMigrations returns a copy of the registered Go migrations.
+func RegisteredGoMigrations() []GoMigration {
+	goRegistryMu.Lock()
+	defer goRegistryMu.Unlock()
+	return append([]GoMigration(nil), goRegistry...)
+}
+
+func (m GoMigration) validate() error {
+	if m.Version == "" || m.Name == "" {
+		return fmt.Errorf("go migration needs both version and name (got %q, %q)", m.Version, m.Name)
+	}
+	if m.Up == nil {
+		return fmt.Errorf("go migration %s_%s has no up function", m.Version, m.Name)
+	}
+	return nil
+}
+
+// descriptor is what stands in for a Go migration's up file: comments that
+// change exactly when its checksum should.
+func (m GoMigration) descriptor() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- go migration %s_%s\n", m.Version, m.Name)
+	if len(m.Source) > 0 {
+		sum := sha256.Sum256(m.Source)
+		fmt.Fprintf(&b, "-- source %s\n", hex.EncodeToString(sum[:]))
+	} else if m.Revision != "" {
+		fmt.Fprintf(&b, "-- revision %s\n", m.Revision)
+	}
+	return []byte(b.String())
+}
+
+// mergeGo adds Go migrations like mergeInline adds inline ones.
+func (d *Discovery) mergeGo(migrations []GoMigration) error {
+	versions := map[string]string{}
+	for _, fp := range d.Files {
+		versions[fp.Version] = Key(fp.Version, fp.Name)
+	}
+	for _, m := range migrations {
+		if err := m.validate(); err != nil {
+			return err
+		}
+		if prev, ok := versions[m.Version]; ok {
+			return fmt.Errorf("go migration %s_%s collides with %s", m.Version, m.Name, prev)
+		}
+		versions[m.Version] = Key(m.Version, m.Name)
+		fp := FilePair{
+			Version: m.Version, Name: m.Name, UpBytes: m.descriptor(),
+			inline: true, isGo: true, goUp: m.Up, goDown: m.Down,
+		}
+		if m.Down != nil {
+			fp.DownBytes = []byte(fmt.Sprintf("-- go migration %s_%s down\n", m.Version, m.Name))
+		}
+		d.Files = append(d.Files, fp)
+	}
+	sortFiles(d.Files)
+	return nil
+}
+
+// execGoDown runs fp's down function in its own transaction.
+func (r *Runner) execGoDown(ctx context.Context, fp FilePair) error {
+	if fp.goDown == nil {
+		return errors.New("go migration has no down function")
+	}
+	return r.inTx(ctx, txSettings{isolation: r.Isolation}, func(tx *sql.Tx) error { return fp.goDown(ctx, tx) })
+}
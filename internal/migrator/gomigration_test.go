@@ -0,0 +1,125 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDiscoverAndPlan_GoMigrations(t *testing.T) {
+	saved := goRegistry
+	goRegistry = nil
+	t.Cleanup(func() { goRegistry = saved })
+
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	writePair(t, dir, "20250103000000", "index", "CREATE INDEX i ON t1(v);", "DROP INDEX i ON t1;")
+
+	var ran []string
+	up := func(ctx context.Context, tx *sql.Tx) error {
+		ran = append(ran, "up")
+		_, err := tx.ExecContext(ctx, "UPDATE t1 SET v = 1")
+		return err
+	}
+	down := func(ctx context.Context, tx *sql.Tx) error {
+		ran = append(ran, "down")
+		return nil
+	}
+	RegisterGoMigration("20250102000000", "backfill", up, down)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	var names []string
+	for _, fp := range plan.Pending {
+		names = append(names, fp.Name)
+	}
+	if strings.Join(names, ",") != "init,backfill,index" {
+		t.Fatalf("pending order = %v", names)
+	}
+	goFP := plan.Pending[1]
+	if !goFP.isGo || goFP.Checksum == "" || goFP.DownChecksum == "" {
+		t.Fatalf("go migration not loaded: %+v", goFP)
+	}
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(1)))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE t1 SET v = 1")).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(2)))
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM `schema_migrations`").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	applied, err := r.ApplyUp(context.Background(), []FilePair{goFP}, false, nil)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := r.ApplyDown(context.Background(), applied, map[string]FilePair{Key(goFP.Version, goFP.Name): goFP}, false, nil); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if strings.Join(ran, ",") != "up,down" {
+		t.Fatalf("ran = %v", ran)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestGoMigrationChecksumAndCollisions(t *testing.T) {
+	noop := func(context.Context, *sql.Tx) error { return nil }
+	load := func(m GoMigration) FilePair {
+		t.Helper()
+		d, err := Discover(FileSource{Go: []GoMigration{m}})
+		if err != nil {
+			t.Fatalf("discover: %v", err)
+		}
+		if err := d.Load(); err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		return d.Files[0]
+	}
+	base := GoMigration{Version: "1", Name: "a", Up: noop}
+	r1, r2 := base, base
+	r1.Revision, r2.Revision = "v1", "v2"
+	if load(r1).Checksum == load(r2).Checksum {
+		t.Fatal("revision must change the checksum")
+	}
+	s1, s2 := base, base
+	s1.Source, s2.Source = []byte("package a // v1"), []byte("package a // v2")
+	if load(s1).Checksum == load(s2).Checksum {
+		t.Fatal("source must change the checksum")
+	}
+	if fp := load(base); fp.DownBytes != nil || fp.goDown != nil {
+		t.Fatalf("no down function must mean no down file: %+v", fp)
+	}
+
+	src := FileSource{
+		Inline: []InlineMigration{{Version: "1", Name: "sql", Up: "SELECT 1"}},
+		Go:     []GoMigration{base},
+	}
+	if _, err := Discover(src); err == nil || !strings.Contains(err.Error(), "collides") {
+		t.Fatalf("expected version collision, got %v", err)
+	}
+	if _, err := Discover(FileSource{Go: []GoMigration{{Version: "1", Name: "a"}}}); err == nil {
+		t.Fatal("expected error for a migration without an up function")
+	}
+}
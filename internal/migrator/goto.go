@@ -0,0 +1,79 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GotoSteps is the work needed to bring the schema to a target version.
+// At most one of Up and Down is non-empty.
+type GotoSteps struct {
+	Target FilePair
+	Up     []FilePair // pending migrations up to and including the target, in order
+	Down   []Row      // applied migrations above the target, latest first
+}
+
+// PlanGoto computes how to move from plan's applied set to target, which is
+// resolved against plan.All with ResolveTarget. Pending migrations at or
+// below the target are applied; successful migrations above it are reverted
+// and must all have a down file. Both at once is an error: reverting would
+// leave the pending ones unapplied, so the schema would match neither the
+// target nor any recorded state.
+func PlanGoto(plan *Plan, target string) (GotoSteps, error) {
+	fp, err := ResolveTarget(plan.All, target)
+	if err != nil {
+		return GotoSteps{}, err
+	}
+	steps := GotoSteps{Target: fp}
+	files := make(map[string]FilePair, len(plan.All))
+	for _, f := range plan.All {
+		files[Key(f.Version, f.Name)] = f
+	}
+	for k, row := range plan.Applied {
+		if row.Status != "success" || row.Version <= fp.Version {
+			continue
+		}
+		if f, ok := files[k]; !ok || (f.DownPath == "" && len(f.DownBytes) == 0) {
+			return GotoSteps{}, fmt.Errorf("missing down file for %s", k)
+		}
+		steps.Down = append(steps.Down, row)
+	}
+	sort.Slice(steps.Down, func(i, j int) bool { return steps.Down[i].ExecutionOrder > steps.Down[j].ExecutionOrder })
+	for _, p := range plan.Pending {
+		if p.Version <= fp.Version {
+			steps.Up = append(steps.Up, p)
+		}
+	}
+	if len(steps.Down) > 0 && len(steps.Up) > 0 {
+		keys := make([]string, len(steps.Up))
+		for i, p := range steps.Up {
+			keys[i] = Key(p.Version, p.Name)
+		}
+		return GotoSteps{}, fmt.Errorf("cannot go down to %s: pending migrations at or below it would stay unapplied: %s; apply them first", Key(fp.Version, fp.Name), strings.Join(keys, ", "))
+	}
+	return steps, nil
+}
+
+// Goto migrates up or down to target as computed by PlanGoto. It returns the
+// steps it took; in dry-run nothing is executed.
+func (r *Runner) Goto(ctx context.Context, plan *Plan, target string, dryRun bool, progress func(stage string, fp FilePair, row *Row, err error)) (GotoSteps, error) {
+	steps, err := PlanGoto(plan, target)
+	if err != nil {
+		return steps, err
+	}
+	if len(steps.Down) > 0 {
+		lookup := make(map[string]FilePair, len(plan.All))
+		for _, fp := range plan.All {
+			lookup[Key(fp.Version, fp.Name)] = fp
+		}
+		return steps, r.ApplyDown(ctx, steps.Down, lookup, dryRun, progress)
+	}
+	if len(steps.Up) > 0 {
+		if _, err := r.ApplyUp(ctx, steps.Up, dryRun, progress); err != nil {
+			return steps, err
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,78 @@
+package migrator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func gotoPlan() *Plan {
+	all := []FilePair{
+		{Version: "20250101000000", Name: "a", DownBytes: []byte("DROP TABLE a;")},
+		{Version: "20250102000000", Name: "b", DownBytes: []byte("DROP TABLE b;")},
+		{Version: "20250103000000", Name: "c", DownBytes: []byte("DROP TABLE c;")},
+		{Version: "20250104000000", Name: "d", DownBytes: []byte("DROP TABLE d;")},
+	}
+	return &Plan{
+		All:     all,
+		Pending: all[2:],
+		Applied: map[string]Row{
+			Key("20250101000000", "a"): {Version: "20250101000000", Name: "a", Status: "success", ExecutionOrder: 1},
+			Key("20250102000000", "b"): {Version: "20250102000000", Name: "b", Status: "success", ExecutionOrder: 2},
+		},
+	}
+}
+
+func TestPlanGoto_Up(t *testing.T) {
+	steps, err := PlanGoto(gotoPlan(), "20250103000000")
+	if err != nil {
+		t.Fatalf("goto: %v", err)
+	}
+	if len(steps.Down) != 0 || len(steps.Up) != 1 || steps.Up[0].Name != "c" {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+}
+
+func TestPlanGoto_Down(t *testing.T) {
+	steps, err := PlanGoto(gotoPlan(), "20250101000000")
+	if err != nil {
+		t.Fatalf("goto: %v", err)
+	}
+	if len(steps.Up) != 0 || len(steps.Down) != 1 || steps.Down[0].Name != "b" {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+
+	plan := gotoPlan()
+	plan.All[1].DownBytes = nil
+	if _, err := PlanGoto(plan, "20250101000000"); err == nil {
+		t.Fatal("expected missing down file error")
+	}
+}
+
+func TestPlanGoto_DownWithPendingBelowTarget(t *testing.T) {
+	// b was merged late: d is applied, b is still pending below the target c
+	plan := gotoPlan()
+	plan.Pending = []FilePair{plan.All[1]}
+	plan.Applied = map[string]Row{
+		Key("20250101000000", "a"): {Version: "20250101000000", Name: "a", Status: "success", ExecutionOrder: 1},
+		Key("20250103000000", "c"): {Version: "20250103000000", Name: "c", Status: "success", ExecutionOrder: 2},
+		Key("20250104000000", "d"): {Version: "20250104000000", Name: "d", Status: "success", ExecutionOrder: 3},
+	}
+	_, err := PlanGoto(plan, "20250103000000")
+	if err == nil || !strings.Contains(err.Error(), "20250102000000:b") {
+		t.Fatalf("expected an error naming the pending migration, got %v", err)
+	}
+
+	// nothing pending below the target: d alone is reverted
+	plan.Pending = nil
+	steps, err := PlanGoto(plan, "20250103000000")
+	if err != nil || len(steps.Down) != 1 || steps.Down[0].Name != "d" {
+		t.Fatalf("unexpected steps: %+v, %v", steps, err)
+	}
+}
+
+func TestPlanGoto_UnknownTarget(t *testing.T) {
+	if _, err := PlanGoto(gotoPlan(), "20990101000000"); !errors.Is(err, ErrTargetNotFound) {
+		t.Fatalf("expected ErrTargetNotFound, got %v", err)
+	}
+}
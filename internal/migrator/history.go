@@ -0,0 +1,88 @@
+package migrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// History returns every tracking row, failed ones included, ordered by
+// execution order. With limit > 0 only the latest limit rows are returned,
+// still oldest first. Unlike a plan it reads the table alone, without
+// looking at files. The table keeps one row per migration, so a failure
+// that was later retried successfully shows only as the success.
+func (s *Storage) History(ctx context.Context, limit int) ([]Row, error) {
+	q := fmt.Sprintf(`SELECT %s FROM %s ORDER BY execution_order, applied_at`, rowColumns, s.table())
+	var args []any
+	if limit > 0 {
+		q = fmt.Sprintf(`SELECT %s FROM %s ORDER BY execution_order DESC, applied_at DESC LIMIT ?`, rowColumns, s.table())
+		args = append(args, limit)
+	}
+	rows, err := s.DB.QueryContext(ctx, s.q(q), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Row
+	for rows.Next() {
+		r, err := scanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+	return out, nil
+}
+
+// historyEntry is a Row as written by WriteHistory in JSON.
+type historyEntry struct {
+	ExecutionOrder int64     `json:"execution_order"`
+	Version        string    `json:"version"`
+	Name           string    `json:"name"`
+	Status         string    `json:"status"`
+	AppliedAt      time.Time `json:"applied_at"`
+	AppliedBy      string    `json:"applied_by"`
+	DurationMS     int64     `json:"duration_ms"`
+	ToolVersion    string    `json:"tool_version"`
+}
+
+// WriteHistory writes rows from History as a table, or as a JSON array when
+// asJSON is set.
+func WriteHistory(w io.Writer, rows []Row, asJSON bool) error {
+	if asJSON {
+		entries := make([]historyEntry, len(rows))
+		for i, r := range rows {
+			entries[i] = historyEntry{
+				ExecutionOrder: r.ExecutionOrder, Version: r.Version, Name: r.Name, Status: r.Status,
+				AppliedAt: r.AppliedAt, AppliedBy: r.AppliedBy, DurationMS: r.DurationMS, ToolVersion: r.ToolVersion,
+			}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ORDER\tVERSION\tNAME\tSTATUS\tAPPLIED AT\tAPPLIED BY\tDURATION")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", r.ExecutionOrder, r.Version, r.Name, r.Status,
+			r.AppliedAt.UTC().Format(time.RFC3339), r.AppliedBy, time.Duration(r.DurationMS)*time.Millisecond)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
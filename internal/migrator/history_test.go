@@ -0,0 +1,71 @@
+package migrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum.* FROM `schema_migrations` ORDER BY execution_order, applied_at$").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("1", "init", "c1", at, "alice", int64(120), "success", int64(1), "dev", nil).
+			AddRow("2", "broken", "c2", at.Add(time.Hour), "bob", int64(3400), "failed", int64(2), "dev", nil))
+	// with a limit the newest rows are read and returned oldest first
+	mock.ExpectQuery("ORDER BY execution_order DESC, applied_at DESC LIMIT \\?").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("3", "c", "c3", at, "carol", int64(1), "success", int64(3), "dev", nil).
+			AddRow("2", "broken", "c2", at, "bob", int64(3400), "failed", int64(2), "dev", nil))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	rows, err := st.History(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(rows) != 2 || rows[1].Status != "failed" {
+		t.Fatalf("rows = %+v", rows)
+	}
+
+	var text strings.Builder
+	if err := WriteHistory(&text, rows, false); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	for _, want := range []string{"ORDER", "1      1        init    success  2025-01-02T03:04:05Z  alice", "failed", "3.4s"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+	var js strings.Builder
+	if err := WriteHistory(&js, rows, true); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal([]byte(js.String()), &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(decoded) != 2 || decoded[1]["status"] != "failed" || decoded[1]["applied_by"] != "bob" || decoded[0]["execution_order"] != float64(1) {
+		t.Fatalf("json = %s", js.String())
+	}
+
+	last, err := st.History(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("history limit: %v", err)
+	}
+	if len(last) != 2 || last[0].Version != "2" || last[1].Version != "3" {
+		t.Fatalf("limited rows = %+v", last)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
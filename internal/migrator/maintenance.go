@@ -0,0 +1,24 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WithMaintenance runs onSQL, then fn, then offSQL. offSQL always runs once
+// onSQL has started, even if fn fails or ctx is cancelled, so the
+// application is never left stuck in maintenance mode. Errors from fn and
+// offSQL are joined.
+func (r *Runner) WithMaintenance(ctx context.Context, onSQL, offSQL []string, fn func() error) (err error) {
+	defer func() {
+		offCtx := context.WithoutCancel(ctx)
+		if offErr := execStatements(offCtx, r.DB, offSQL...); offErr != nil {
+			err = errors.Join(err, fmt.Errorf("maintenance off: %w", offErr))
+		}
+	}()
+	if err := execStatements(ctx, r.DB, onSQL...); err != nil {
+		return fmt.Errorf("maintenance on: %w", err)
+	}
+	return fn()
+}
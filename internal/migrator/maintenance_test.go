@@ -0,0 +1,82 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWithMaintenance(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		mock.ExpectExec("UPDATE app_flags SET maintenance = 1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE app_flags SET maintenance = 0").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		r := NewRunner(db, "schema_migrations", "tester")
+		ran := false
+		err = r.WithMaintenance(context.Background(),
+			[]string{"UPDATE app_flags SET maintenance = 1"},
+			[]string{"UPDATE app_flags SET maintenance = 0"},
+			func() error {
+				ran = true
+				if fail {
+					return errors.New("migration failed")
+				}
+				return nil
+			})
+		if !ran {
+			t.Fatal("fn not called")
+		}
+		if fail != (err != nil) {
+			t.Fatalf("fail=%v but err=%v", fail, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("off SQL must run (fail=%v): %v", fail, err)
+		}
+		db.Close()
+	}
+}
+
+func TestApplyUp_Maintenance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectExec("UPDATE app_flags SET maintenance = 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE t1").WillReturnError(errors.New("boom"))
+	mock.ExpectRollback()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	mock.ExpectExec("UPDATE app_flags SET maintenance = 0").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.MaintenanceOnSQL = []string{"UPDATE app_flags SET maintenance = 1"}
+	r.MaintenanceOffSQL = []string{"UPDATE app_flags SET maintenance = 0"}
+	files := []FilePair{{Version: "20250101000000", Name: "init", UpBytes: []byte("CREATE TABLE t1(id INT);"), Checksum: "x"}}
+	if _, err := r.ApplyUp(context.Background(), files, false, nil); err == nil {
+		t.Fatal("expected the migration error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("maintenance must bracket the batch: %v", err)
+	}
+
+	// nothing to apply: the flag is left alone
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(1)))
+	if _, err := r.ApplyUp(context.Background(), nil, false, nil); err != nil {
+		t.Fatalf("empty batch: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
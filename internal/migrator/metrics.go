@@ -0,0 +1,109 @@
+package migrator
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/fsutil"
+)
+
+// MetricsFile collects a run's progress and writes a single JSON summary
+// when it finishes, for CI to archive or parse. Pass its Progress method as
+// the ApplyUp/ApplyDown callback, then call Write.
+type MetricsFile struct {
+	Path     string
+	LockWait time.Duration // time spent acquiring the advisory lock
+	// CurrentVersion is reported as current_version; when empty, the highest
+	// version applied in this run is used.
+	CurrentVersion string
+
+	mu         sync.Mutex
+	started    time.Time
+	stages     map[string]int
+	migrations []migrationMetric
+}
+
+type migrationMetric struct {
+	Version    string `json:"version"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type metricsSummary struct {
+	Success         bool              `json:"success"`
+	Error           string            `json:"error,omitempty"`
+	Stages          map[string]int    `json:"stages"`
+	Migrations      []migrationMetric `json:"migrations"`
+	Applied         int               `json:"applied"`
+	TotalDurationMS int64             `json:"total_duration_ms"`
+	LockWaitMS      int64             `json:"lock_wait_ms"`
+	CurrentVersion  string            `json:"current_version,omitempty"`
+	FinishedAt      time.Time         `json:"finished_at"`
+}
+
+// Progress records a progress callback.
+func (m *MetricsFile) Progress(stage string, fp FilePair, row *Row, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started.IsZero() {
+		m.started = time.Now()
+	}
+	if m.stages == nil {
+		m.stages = map[string]int{}
+	}
+	m.stages[stage]++
+	if stage == "start" {
+		return
+	}
+	mm := migrationMetric{Version: fp.Version, Name: fp.Name, Status: stage}
+	if row != nil {
+		mm.DurationMS = row.DurationMS
+	}
+	m.migrations = append(m.migrations, mm)
+}
+
+// Write atomically writes the summary for a run that applied rows and ended
+// with runErr.
+func (m *MetricsFile) Write(applied []Row, runErr error) error {
+	b, err := json.MarshalIndent(m.summary(applied, runErr), "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(m.Path, b, 0o644)
+}
+
+func (m *MetricsFile) summary(applied []Row, runErr error) metricsSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sum := metricsSummary{
+		Success:        runErr == nil,
+		Stages:         m.stages,
+		Migrations:     m.migrations,
+		Applied:        len(applied),
+		LockWaitMS:     m.LockWait.Milliseconds(),
+		CurrentVersion: m.CurrentVersion,
+		FinishedAt:     time.Now().UTC(),
+	}
+	if sum.Stages == nil {
+		sum.Stages = map[string]int{}
+	}
+	if sum.Migrations == nil {
+		sum.Migrations = []migrationMetric{}
+	}
+	if runErr != nil {
+		sum.Error = runErr.Error()
+	}
+	if !m.started.IsZero() {
+		sum.TotalDurationMS = time.Since(m.started).Milliseconds()
+	}
+	if sum.CurrentVersion == "" {
+		for _, r := range applied {
+			if r.Version > sum.CurrentVersion {
+				sum.CurrentVersion = r.Version
+			}
+		}
+	}
+	return sum
+}
@@ -0,0 +1,57 @@
+package migrator
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMetricsFileSummary(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "metrics.json")
+	m := &MetricsFile{Path: p, LockWait: 1500 * time.Millisecond}
+
+	a := FilePair{Version: "20250101000000", Name: "a"}
+	b := FilePair{Version: "20250102000000", Name: "b"}
+	rowA := Row{Version: a.Version, Name: a.Name, DurationMS: 12, Status: "success"}
+	rowB := Row{Version: b.Version, Name: b.Name, DurationMS: 3, Status: "failed"}
+	m.Progress("start", a, &rowA, nil)
+	m.Progress("success", a, &rowA, nil)
+	m.Progress("start", b, &rowB, nil)
+	m.Progress("error", b, &rowB, errors.New("boom"))
+
+	if err := m.Write([]Row{rowA}, errors.New("migration failed")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var sum metricsSummary
+	if err := json.Unmarshal(raw, &sum); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if sum.Success || sum.Error != "migration failed" || sum.Applied != 1 {
+		t.Fatalf("unexpected outcome: %+v", sum)
+	}
+	if sum.Stages["start"] != 2 || sum.Stages["success"] != 1 || sum.Stages["error"] != 1 {
+		t.Fatalf("unexpected stage counts: %v", sum.Stages)
+	}
+	if len(sum.Migrations) != 2 || sum.Migrations[0].DurationMS != 12 || sum.Migrations[1].Status != "error" {
+		t.Fatalf("unexpected migrations: %+v", sum.Migrations)
+	}
+	if sum.LockWaitMS != 1500 || sum.CurrentVersion != "20250101000000" {
+		t.Fatalf("unexpected lock wait/current version: %+v", sum)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		t.Fatalf("decode fields: %v", err)
+	}
+	for _, key := range []string{"stages", "migrations", "total_duration_ms", "lock_wait_ms", "success"} {
+		if _, ok := fields[key]; !ok {
+			t.Fatalf("missing field %q", key)
+		}
+	}
+}
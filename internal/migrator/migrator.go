@@ -3,20 +3,135 @@ package migrator
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"os/user"
 	"strings"
 	"time"
 
-	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/checksum"
 )
 
+// ToolVersion is recorded as tool_version on every row this build writes. Set
+// it at build time with
+// -ldflags "-X github.com/mirajehossain/gomigratex/internal/migrator.ToolVersion=v1.2.3".
+var ToolVersion = "dev"
+
+func toolVersion() string {
+	if ToolVersion == "" {
+		return "dev"
+	}
+	return ToolVersion
+}
+
 type Runner struct {
 	DB        *sql.DB
 	Storage   *Storage
 	AppliedBy string
+
+	// AppliedByMeta appends the host and CI job details to the recorded
+	// applied_by; see EnrichAppliedBy.
+	AppliedByMeta bool
+
+	// Params supplies values for :name parameters in migration SQL. They are
+	// passed as bind arguments, never spliced into the SQL text.
+	Params map[string]any
+
+	// ExecWholeFile sends each migration file in a single Exec instead of
+	// splitting it into statements, which requires multiStatements=true in
+	// the DSN and doesn't understand DELIMITER. Files with parameters or
+	// batch-commit are always split.
+	ExecWholeFile bool
+
+	// LockWaitTimeout, when > 0, is issued as the session lock_wait_timeout and
+	// innodb_lock_wait_timeout inside each migration's transaction so blocked
+	// DDL/DML fails fast. On MySQL the setting belongs to the connection, so
+	// migrations then run on a dedicated connection that is reset to the
+	// server defaults afterwards, or discarded if it can't be. Postgres uses
+	// SET LOCAL, which ends with the transaction; on SQLite the busy timeout
+	// stays on the connection.
+	LockWaitTimeout time.Duration
+
+	// StatementTimeout, when > 0, bounds each migration's up or down file:
+	// its statements run under a context that is cancelled after this long.
+	StatementTimeout time.Duration
+
+	// PauseBetween waits between successful migrations in ApplyUp so replicas
+	// can catch up. A file's pause-after directive overrides it. Ignored in dry-run.
+	PauseBetween time.Duration
+
+	// ReplicaLag, when set, holds ApplyUp between migrations until replicas
+	// catch up. Ignored in dry-run.
+	ReplicaLag *ReplicaLagWait
+
+	// LockCheck, when set, runs before each migration in ApplyUp and stops
+	// the run if it fails. Set it to the advisory lock's Check so a lock lost
+	// with its connection fails fast instead of racing another run.
+	LockCheck func(ctx context.Context) error
+
+	// Isolation is the isolation level of migration transactions in ApplyUp
+	// and ApplyDown; a file's isolation directive overrides it for its up
+	// SQL. sql.LevelDefault leaves the server's default.
+	Isolation sql.IsolationLevel
+
+	// ContiguousOrder records failed migrations with execution_order 0
+	// instead of the next number, so the sequence has no holes from failures:
+	// a retry that succeeds takes the number after the last success. By
+	// default a failed row takes the next number and a successful retry
+	// takes a new one, leaving the failed attempt's number unused.
+	ContiguousOrder bool
+
+	// AllOrNothing makes ApplyUp run as ApplyUpAllOrNothing: every
+	// migration and its row in one transaction that commits at the end.
+	AllOrNothing bool
+
+	// Approver, when set, is consulted before each migration in ApplyUp;
+	// see Approver. nil approves everything. Not consulted in dry-run.
+	Approver Approver
+
+	// TotalBudget, when > 0, bounds an ApplyUp batch without interrupting a
+	// migration: before each one, the run stops if the batch has used the
+	// budget or the file's est-duration would overrun it. The rest stay
+	// pending. Ignored in dry-run.
+	TotalBudget time.Duration
+
+	// AnalyzeAfter lists tables ApplyUp analyzes once a batch has applied
+	// migrations, and AnalyzeAllChanged adds the tables they create or
+	// alter (see ChangedTables). Failures are sent to OnWarn. Ignored in
+	// dry-run.
+	AnalyzeAfter      []string
+	AnalyzeAllChanged bool
+
+	// MaintenanceOnSQL and MaintenanceOffSQL, when set, bracket every
+	// ApplyUp batch with something to apply via WithMaintenance. Ignored in
+	// dry-run.
+	MaintenanceOnSQL  []string
+	MaintenanceOffSQL []string
+
+	// OnWarn receives non-fatal diagnostics (e.g. table name case issues).
+	OnWarn func(msg string)
+}
+
+func (r *Runner) warn(format string, args ...any) {
+	if r.OnWarn != nil {
+		r.OnWarn(fmt.Sprintf(format, args...))
+	}
 }
 
+// sleepCtx waits for d or until ctx is done; tests replace it.
+var sleepCtx = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewRunner returns a MySQL runner; set Storage.Driver for other databases.
 func NewRunner(database *sql.DB, table string, appliedBy string) *Runner {
 	return &Runner{
 		DB:        database,
@@ -25,6 +140,38 @@ func NewRunner(database *sql.DB, table string, appliedBy string) *Runner {
 	}
 }
 
+type appliedByKey struct{}
+
+// WithAppliedBy returns a context that attributes migrations run with it to
+// who, overriding Runner.AppliedBy for that run only. Useful in request-scoped
+// code, e.g. to record the logged-in admin without mutating a shared Runner.
+func WithAppliedBy(ctx context.Context, who string) context.Context {
+	return context.WithValue(ctx, appliedByKey{}, who)
+}
+
+// AppliedByFromContext returns the identity set by WithAppliedBy, if any.
+func AppliedByFromContext(ctx context.Context) (string, bool) {
+	who, ok := ctx.Value(appliedByKey{}).(string)
+	return who, ok && strings.TrimSpace(who) != ""
+}
+
+// appliedBy resolves who to record for a run: the context value, then
+// r.AppliedBy, then the OS user, enriched when r.AppliedByMeta is set.
+func (r *Runner) appliedBy(ctx context.Context) string {
+	who, ok := AppliedByFromContext(ctx)
+	switch {
+	case ok:
+	case strings.TrimSpace(r.AppliedBy) != "":
+		who = r.AppliedBy
+	default:
+		who = defaultAppliedBy()
+	}
+	if r.AppliedByMeta {
+		return EnrichAppliedBy(who)
+	}
+	return who
+}
+
 func defaultAppliedBy() string {
 	u, err := user.Current()
 	if err == nil && u.Username != "" {
@@ -34,8 +181,12 @@ func defaultAppliedBy() string {
 }
 
 func (r *Runner) Ensure(ctx context.Context) error {
-	if err := db.EnsureTable(ctx, r.DB, r.Storage.Table); err != nil {
-		return err
+	r.Storage.Table = NormalizeTableName(r.Storage.Table)
+	if r.Storage.driver().Name() == "mysql" {
+		r.checkTableCase(ctx)
+	}
+	if err := r.Storage.driver().EnsureTable(ctx, r.DB, r.Storage.table()); err != nil {
+		return fmt.Errorf("cannot create or upgrade tracking table %s: %w", r.Storage.Table, err)
 	}
 	if strings.TrimSpace(r.AppliedBy) == "" {
 		r.AppliedBy = defaultAppliedBy()
@@ -43,22 +194,295 @@ func (r *Runner) Ensure(ctx context.Context) error {
 	return nil
 }
 
+// setSessionTimeouts applies the configured lock wait timeouts to the session
+// backing tx. It must run on the same connection as the migration statements.
+func (r *Runner) setSessionTimeouts(ctx context.Context, tx Execer) error {
+	if r.LockWaitTimeout <= 0 {
+		return nil
+	}
+	return execStatements(ctx, tx, r.Storage.driver().SessionTimeoutSQL(r.LockWaitTimeout)...)
+}
+
+// ErrMigrationTimeout is wrapped by the error of a migration stopped by a
+// deadline, either StatementTimeout or one on the caller's context, so it
+// can be told apart from an error in the SQL.
+var ErrMigrationTimeout = errors.New("migration timed out")
+
+// MigrationError is returned by ApplyUp, ApplyUpTx and ApplyUpAllOrNothing
+// when a migration fails to run or to be recorded, naming the file that
+// failed. Errors between migrations, such as a lost lock or a replica lag
+// timeout, are returned as they are.
+type MigrationError struct {
+	File FilePair
+	Err  error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %s:%s failed: %v", e.File.Version, e.File.Name, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error { return e.Err }
+
+// migrationCtx bounds one migration's execution by StatementTimeout.
+func (r *Runner) migrationCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.StatementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.StatementTimeout)
+}
+
+// timeoutErr wraps err with ErrMigrationTimeout when ctx, the migration's
+// context, ran out of time.
+func timeoutErr(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrMigrationTimeout, err)
+	}
+	return err
+}
+
+// execUp runs a migration's up SQL according to its directives, bounded by
+// StatementTimeout.
+func (r *Runner) execUp(ctx context.Context, fp FilePair) error {
+	ctx, cancel := r.migrationCtx(ctx)
+	defer cancel()
+	return timeoutErr(ctx, r.execUpIn(ctx, fp))
+}
+
+// execUpIn is execUp once ctx is bounded.
+func (r *Runner) execUpIn(ctx context.Context, fp FilePair) error {
+	if fp.goUp != nil {
+		return r.inTx(ctx, txSettings{isolation: r.Isolation}, func(tx *sql.Tx) error { return fp.goUp(ctx, tx) })
+	}
+	stmts, err := r.statements(fp.UpBytes, fp.BatchCommit > 0)
+	if err != nil {
+		return err
+	}
+	if fp.FKChecksOff && r.Storage.driver().Name() != "mysql" {
+		return fmt.Errorf("fk-checks directive is not supported on %s", r.Storage.driver().Name())
+	}
+	if fp.NoTx {
+		return r.execNoTx(ctx, stmts, fp.FKChecksOff)
+	}
+	ts := txSettings{isolation: r.Isolation, fkOff: fp.FKChecksOff}
+	if fp.Isolation != sql.LevelDefault {
+		ts.isolation = fp.Isolation
+	}
+	if fp.BatchCommit > 0 {
+		return r.execBatched(ctx, stmts, fp.BatchCommit, ts)
+	}
+	return r.execInTx(ctx, stmts, ts)
+}
+
+// txSettings are the per-migration settings of the transactions running it.
+type txSettings struct {
+	isolation sql.IsolationLevel
+	fkOff     bool // disable foreign key checks for the session
+}
+
+// MySQL session statements bracketing a migration marked fk-checks: off.
+// The setting belongs to the session, not the transaction, so such
+// migrations run on a dedicated connection that is restored, or discarded if
+// it can't be, before it goes back to the pool.
+const (
+	fkChecksOffSQL = "SET FOREIGN_KEY_CHECKS=0"
+	fkChecksOnSQL  = "SET FOREIGN_KEY_CHECKS=1"
+)
+
+// execNoTx executes stmts in order on one connection without a transaction,
+// for files marked no-transaction. Session lock timeouts are not set: outside
+// a transaction they would outlive the migration on the pooled connection.
+// With fkOff, foreign key checks are disabled on that connection for the
+// duration.
+func (r *Runner) execNoTx(ctx context.Context, stmts []stmt, fkOff bool) error {
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if fkOff {
+		if err := execStatements(ctx, conn, fkChecksOffSQL); err != nil {
+			return err
+		}
+		defer restoreSession(ctx, conn, fkChecksOnSQL)
+	}
+	return execBound(ctx, conn, stmts)
+}
+
+// restoreSession runs stmts on conn to undo session settings, even after ctx
+// was cancelled. If they fail, conn is discarded instead of going back to the
+// pool with the settings still in effect.
+func restoreSession(ctx context.Context, conn *sql.Conn, stmts ...string) {
+	if err := execStatements(context.WithoutCancel(ctx), conn, stmts...); err != nil {
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+}
+
+// execInTx executes stmts in order inside a single transaction at
+// ts.isolation. With ts.fkOff, foreign key checks are disabled for that
+// transaction's session and restored after it ends.
+func (r *Runner) execInTx(ctx context.Context, stmts []stmt, ts txSettings) error {
+	return r.inTx(ctx, ts, func(tx *sql.Tx) error { return execBound(ctx, tx, stmts) })
+}
+
+// txBeginner is satisfied by *sql.DB and *sql.Conn.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// inTx runs fn in a transaction set up like execInTx's and commits it. When
+// that changes session settings (ts.fkOff, or LockWaitTimeout on MySQL) the
+// transaction runs on a dedicated connection, so they can be restored once
+// the transaction is over, however it ended.
+func (r *Runner) inTx(ctx context.Context, ts txSettings, fn func(tx *sql.Tx) error) error {
+	reset := r.sessionReset(ts)
+	if len(reset) == 0 {
+		return r.inTxOn(ctx, r.DB, ts, fn)
+	}
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer restoreSession(ctx, conn, reset...)
+	return r.inTxOn(ctx, conn, ts, fn)
+}
+
+// sessionReset returns the statements undoing the session settings a
+// transaction set up with ts leaves on its connection.
+func (r *Runner) sessionReset(ts txSettings) []string {
+	var reset []string
+	if ts.fkOff {
+		reset = append(reset, fkChecksOnSQL)
+	}
+	if r.LockWaitTimeout > 0 {
+		reset = append(reset, r.Storage.driver().SessionTimeoutResetSQL()...)
+	}
+	return reset
+}
+
+// inTxOn is inTx on a transaction begun by b.
+func (r *Runner) inTxOn(ctx context.Context, b txBeginner, ts txSettings, fn func(tx *sql.Tx) error) error {
+	var opts *sql.TxOptions
+	if ts.isolation != sql.LevelDefault {
+		opts = &sql.TxOptions{Isolation: ts.isolation}
+	}
+	tx, err := b.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := r.setSessionTimeouts(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if ts.fkOff {
+		if err := execStatements(ctx, tx, fkChecksOffSQL); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execStatements runs stmts in order on ex.
+func execStatements(ctx context.Context, ex Execer, stmts ...string) error {
+	return execBound(ctx, ex, plain(stmts...))
+}
+
+// execBound runs stmts with their arguments in order on ex.
+func execBound(ctx context.Context, ex Execer, stmts []stmt) error {
+	for _, s := range stmts {
+		if _, err := ex.ExecContext(ctx, s.query, s.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execBatched commits every n of stmts, so a huge data load doesn't have to
+// fit in one transaction. If a batch fails, the batches before it stay
+// committed.
+func (r *Runner) execBatched(ctx context.Context, stmts []stmt, n int, ts txSettings) error {
+	for i := 0; i < len(stmts); i += n {
+		end := min(i+n, len(stmts))
+		if err := r.execInTx(ctx, stmts[i:end], ts); err != nil {
+			return fmt.Errorf("batch starting at statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
 func (r *Runner) ApplyUp(ctx context.Context, files []FilePair, dryRun bool, progress func(stage string, fp FilePair, row *Row, err error)) ([]Row, error) {
+	if dryRun || len(files) == 0 || len(r.MaintenanceOnSQL)+len(r.MaintenanceOffSQL) == 0 {
+		return r.applyUp(ctx, files, dryRun, progress)
+	}
+	var applied []Row
+	err := r.WithMaintenance(ctx, r.MaintenanceOnSQL, r.MaintenanceOffSQL, func() (err error) {
+		applied, err = r.applyUp(ctx, files, dryRun, progress)
+		return err
+	})
+	return applied, err
+}
+
+// applyUp is ApplyUp without maintenance mode.
+func (r *Runner) applyUp(ctx context.Context, files []FilePair, dryRun bool, progress func(stage string, fp FilePair, row *Row, err error)) ([]Row, error) {
+	if r.AllOrNothing && !dryRun {
+		return r.ApplyUpAllOrNothing(ctx, files, false, progress)
+	}
+	for _, fp := range files {
+		if err := r.checkParams(fp, fp.UpBytes); err != nil {
+			return nil, err
+		}
+	}
 	applied := make([]Row, 0, len(files))
+	appliedBy := r.appliedBy(ctx)
 	maxOrder, err := r.Storage.MaxExecutionOrder(ctx)
 	if err != nil {
 		return nil, err
 	}
-	for _, fp := range files {
+	batchStart := time.Now()
+	for i, fp := range files {
+		if !dryRun && r.overBudget(time.Since(batchStart), fp) {
+			r.warn("time budget of %s used after %s: %d applied, %d deferred", r.TotalBudget, time.Since(batchStart).Round(time.Millisecond), len(applied), len(files)-i)
+			if progress != nil {
+				for _, rest := range files[i:] {
+					progress("deferred", rest, &Row{Version: rest.Version, Name: rest.Name}, nil)
+				}
+			}
+			r.analyzeAfterUp(ctx, files[:i])
+			return applied, nil
+		}
 		maxOrder++
 		row := Row{
 			Version:        fp.Version,
 			Name:           fp.Name,
 			Checksum:       fp.Checksum,
 			AppliedAt:      time.Now(),
-			AppliedBy:      r.AppliedBy,
+			AppliedBy:      appliedBy,
 			Status:         "success",
 			ExecutionOrder: maxOrder,
+			ToolVersion:    toolVersion(),
+			DownChecksum:   fp.DownChecksum,
+		}
+
+		if !dryRun && r.Approver != nil {
+			ok, err := r.Approver.Approve(ctx, fp)
+			if err != nil {
+				if progress != nil {
+					progress("error", fp, &row, err)
+				}
+				return applied, fmt.Errorf("approval of %s:%s: %w", fp.Version, fp.Name, err)
+			}
+			if !ok {
+				if progress != nil {
+					progress("denied", fp, &row, nil)
+				}
+				r.analyzeAfterUp(ctx, files[:i])
+				return applied, nil
+			}
 		}
 
 		// progress: start
@@ -74,46 +498,154 @@ func (r *Runner) ApplyUp(ctx context.Context, files []FilePair, dryRun bool, pro
 			continue
 		}
 
-		start := time.Now()
-		tx, err := r.DB.BeginTx(ctx, nil)
-		if err != nil {
-			if progress != nil {
-				progress("error", fp, &row, err)
+		if r.LockCheck != nil {
+			if err := r.LockCheck(ctx); err != nil {
+				if progress != nil {
+					progress("error", fp, &row, err)
+				}
+				return applied, err
 			}
-			return nil, err
 		}
 
-		// NOTE: DSN must include multiStatements=true if file has multiple statements
-		if _, err := tx.ExecContext(ctx, string(fp.UpBytes)); err != nil {
-			_ = tx.Rollback()
+		start := time.Now()
+		if err := r.execUp(ctx, fp); err != nil {
 			row.Status = "failed"
 			row.DurationMS = time.Since(start).Milliseconds()
-			_ = r.Storage.Upsert(ctx, row)
+			// recorded even when ctx was cancelled, e.g. by a signal
+			recCtx := context.WithoutCancel(ctx)
+			if r.ContiguousOrder {
+				row.ExecutionOrder = 0
+				_ = r.Storage.Upsert(recCtx, row)
+			} else {
+				_ = r.Storage.UpsertNext(recCtx, &row)
+			}
 			if progress != nil {
 				progress("error", fp, &row, err)
 			}
-			return applied, fmt.Errorf("migration %s:%s failed: %w", fp.Version, fp.Name, err)
+			return applied, &MigrationError{File: fp, Err: err}
 		}
 
-		if err := tx.Commit(); err != nil {
-			row.Status = "failed"
-			row.DurationMS = time.Since(start).Milliseconds()
-			_ = r.Storage.Upsert(ctx, row)
+		row.DurationMS = time.Since(start).Milliseconds()
+		if err := r.Storage.UpsertNext(ctx, &row); err != nil {
 			if progress != nil {
 				progress("error", fp, &row, err)
 			}
-			return applied, err
+			return applied, &MigrationError{File: fp, Err: err}
 		}
+		maxOrder = row.ExecutionOrder
 
-		row.DurationMS = time.Since(start).Milliseconds()
-		if err := r.Storage.Upsert(ctx, row); err != nil {
+		// progress: success
+		if progress != nil {
+			progress("success", fp, &row, nil)
+		}
+		applied = append(applied, row)
+
+		if i < len(files)-1 {
+			if pause := pauseAfter(fp, r.PauseBetween); pause > 0 {
+				if err := sleepCtx(ctx, pause); err != nil {
+					return applied, err
+				}
+			}
+			if r.ReplicaLag != nil {
+				if err := r.ReplicaLag.Wait(ctx); err != nil {
+					return applied, err
+				}
+			}
+		}
+	}
+	if !dryRun {
+		r.analyzeAfterUp(ctx, files)
+	}
+	return applied, nil
+}
+
+// overBudget reports whether starting fp after elapsed would exceed
+// TotalBudget.
+func (r *Runner) overBudget(elapsed time.Duration, fp FilePair) bool {
+	if r.TotalBudget <= 0 {
+		return false
+	}
+	return elapsed >= r.TotalBudget || elapsed+fp.EstDuration > r.TotalBudget
+}
+
+// ApplyUpTx applies files inside tx, a transaction owned by the caller, and
+// records their rows in the same transaction, so migrations commit or roll
+// back atomically with the caller's other work. The caller commits or rolls
+// back tx; nothing is recorded for a failed migration. Only meaningful for
+// transactional statements: MySQL DDL commits implicitly. Approver,
+// LockCheck and StatementTimeout apply per file as in ApplyUp; a denial
+// stops before the denied file. TotalBudget doesn't: deferring part of an
+// atomic batch would defeat it. Files using batch-commit, no-transaction,
+// fk-checks or isolation are rejected, since they need a connection or
+// transaction of their own.
+func (r *Runner) ApplyUpTx(ctx context.Context, tx *sql.Tx, files []FilePair, progress func(stage string, fp FilePair, row *Row, err error)) ([]Row, error) {
+	for _, fp := range files {
+		if err := checkCallerTx(fp); err != nil {
+			return nil, fmt.Errorf("migration %s:%s: %w", fp.Version, fp.Name, err)
+		}
+		if err := r.checkParams(fp, fp.UpBytes); err != nil {
+			return nil, err
+		}
+	}
+	st := &Storage{DB: tx, Table: r.Storage.Table, Driver: r.Storage.Driver}
+	applied := make([]Row, 0, len(files))
+	appliedBy := r.appliedBy(ctx)
+	if err := r.setSessionTimeouts(ctx, tx); err != nil {
+		return nil, err
+	}
+	if reset := r.sessionReset(txSettings{}); len(reset) > 0 {
+		// tx's connection belongs to the caller; leave it as it was
+		defer func() { _ = execStatements(context.WithoutCancel(ctx), tx, reset...) }()
+	}
+	for _, fp := range files {
+		row := Row{
+			Version:      fp.Version,
+			Name:         fp.Name,
+			Checksum:     fp.Checksum,
+			AppliedAt:    time.Now(),
+			AppliedBy:    appliedBy,
+			Status:       "success",
+			ToolVersion:  toolVersion(),
+			DownChecksum: fp.DownChecksum,
+		}
+		if r.Approver != nil {
+			ok, err := r.Approver.Approve(ctx, fp)
+			if err != nil {
+				if progress != nil {
+					progress("error", fp, &row, err)
+				}
+				return applied, fmt.Errorf("approval of %s:%s: %w", fp.Version, fp.Name, err)
+			}
+			if !ok {
+				if progress != nil {
+					progress("denied", fp, &row, nil)
+				}
+				return applied, nil
+			}
+		}
+		if progress != nil {
+			progress("start", fp, &row, nil)
+		}
+		if r.LockCheck != nil {
+			if err := r.LockCheck(ctx); err != nil {
+				if progress != nil {
+					progress("error", fp, &row, err)
+				}
+				return applied, err
+			}
+		}
+		start := time.Now()
+		err := r.execUpTx(ctx, tx, fp)
+		if err == nil {
+			row.DurationMS = time.Since(start).Milliseconds()
+			err = st.UpsertNext(ctx, &row)
+		}
+		if err != nil {
 			if progress != nil {
 				progress("error", fp, &row, err)
 			}
-			return applied, err
+			return applied, &MigrationError{File: fp, Err: err}
 		}
-
-		// progress: success
 		if progress != nil {
 			progress("success", fp, &row, nil)
 		}
@@ -122,7 +654,49 @@ func (r *Runner) ApplyUp(ctx context.Context, files []FilePair, dryRun bool, pro
 	return applied, nil
 }
 
+// checkCallerTx reports why fp can't run inside a caller's transaction, if
+// it can't.
+func checkCallerTx(fp FilePair) error {
+	switch {
+	case fp.goUp != nil:
+		return nil
+	case fp.BatchCommit > 0:
+		return errors.New("batch-commit is not supported inside a caller transaction")
+	case fp.NoTx:
+		return errors.New("no-transaction migrations can't run inside a caller transaction")
+	case fp.FKChecksOff:
+		return errors.New("fk-checks off can't be set inside a caller transaction")
+	case fp.Isolation != sql.LevelDefault:
+		return errors.New("an isolation directive can't change a caller transaction")
+	}
+	return nil
+}
+
+// execUpTx runs fp's up migration in tx, bounded by StatementTimeout.
+func (r *Runner) execUpTx(ctx context.Context, tx *sql.Tx, fp FilePair) error {
+	ctx, cancel := r.migrationCtx(ctx)
+	defer cancel()
+	if fp.goUp != nil {
+		return timeoutErr(ctx, fp.goUp(ctx, tx))
+	}
+	stmts, err := r.statements(fp.UpBytes, false)
+	if err != nil {
+		return err
+	}
+	return timeoutErr(ctx, execBound(ctx, tx, stmts))
+}
+
 func (r *Runner) ApplyDown(ctx context.Context, toRevert []Row, lookup map[string]FilePair, dryRun bool, progress func(stage string, fp FilePair, row *Row, err error)) error {
+	for _, row := range toRevert {
+		if fp, ok := lookup[Key(row.Version, row.Name)]; ok {
+			if err := r.checkParams(fp, fp.DownBytes); err != nil {
+				return err
+			}
+			if downDrifted(row.DownChecksum, fp.DownChecksum) {
+				return fmt.Errorf("%w: %s (db=%s file=%s)", ErrDownDrift, Key(row.Version, row.Name), row.DownChecksum, fp.DownChecksum)
+			}
+		}
+	}
 	for _, row := range toRevert {
 		fp, ok := lookup[row.Version+":"+row.Name]
 		if !ok {
@@ -143,26 +717,23 @@ func (r *Runner) ApplyDown(ctx context.Context, toRevert []Row, lookup map[strin
 			continue
 		}
 
-		tx, err := r.DB.BeginTx(ctx, nil)
-		if err != nil {
-			if progress != nil {
-				progress("error", fp, &row, err)
-			}
-			return err
+		stmts, err := r.statements(fp.DownBytes, false)
+		execCtx, cancel := r.migrationCtx(ctx)
+		if fp.isGo {
+			err = r.execGoDown(execCtx, fp)
+		} else if err == nil && fp.DownNoTx {
+			err = r.execNoTx(execCtx, stmts, false)
+		} else if err == nil {
+			err = r.execInTx(execCtx, stmts, txSettings{isolation: r.Isolation})
 		}
-		if _, err := tx.ExecContext(ctx, string(fp.DownBytes)); err != nil {
-			_ = tx.Rollback()
+		err = timeoutErr(execCtx, err)
+		cancel()
+		if err != nil {
 			if progress != nil {
 				progress("error", fp, &row, err)
 			}
 			return fmt.Errorf("down migration %s:%s failed: %w", row.Version, row.Name, err)
 		}
-		if err := tx.Commit(); err != nil {
-			if progress != nil {
-				progress("error", fp, &row, err)
-			}
-			return err
-		}
 
 		if err := r.Storage.Delete(ctx, row.Version, row.Name); err != nil {
 			if progress != nil {
@@ -178,16 +749,26 @@ func (r *Runner) ApplyDown(ctx context.Context, toRevert []Row, lookup map[strin
 	return nil
 }
 
+// downDrifted reports whether a down file changed since its migration was
+// applied. Rows without a recorded down checksum, or recorded with another
+// checksum algorithm, can't be compared.
+func downDrifted(stored, current string) bool {
+	if stored == "" || current == "" || checksum.Algo(stored) != checksum.Algo(current) {
+		return false
+	}
+	return !strings.EqualFold(stored, current)
+}
+
 func (r *Runner) LastApplied(ctx context.Context, n int) ([]Row, error) {
-	rows, err := r.DB.QueryContext(ctx, "SELECT version, name, checksum, applied_at, applied_by, duration_ms, status, execution_order FROM "+r.Storage.Table+" WHERE status='success' ORDER BY execution_order DESC LIMIT ?", n)
+	rows, err := r.DB.QueryContext(ctx, r.Storage.q("SELECT "+rowColumns+" FROM "+r.Storage.table()+" WHERE status='success' ORDER BY execution_order DESC LIMIT ?"), n)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var out []Row
 	for rows.Next() {
-		var rr Row
-		if err := rows.Scan(&rr.Version, &rr.Name, &rr.Checksum, &rr.AppliedAt, &rr.AppliedBy, &rr.DurationMS, &rr.Status, &rr.ExecutionOrder); err != nil {
+		rr, err := scanRow(rows)
+		if err != nil {
 			return nil, err
 		}
 		out = append(out, rr)
@@ -197,6 +778,7 @@ func (r *Runner) LastApplied(ctx context.Context, n int) ([]Row, error) {
 
 func (r *Runner) ForceBaseline(ctx context.Context, all []FilePair, version string, fake bool) ([]Row, error) {
 	applied := make([]Row, 0)
+	appliedBy := r.appliedBy(ctx)
 	maxOrder, err := r.Storage.MaxExecutionOrder(ctx)
 	if err != nil {
 		return nil, err
@@ -209,27 +791,37 @@ func (r *Runner) ForceBaseline(ctx context.Context, all []FilePair, version stri
 		maxOrder++
 		row := Row{
 			Version: fp.Version, Name: fp.Name, Checksum: fp.Checksum,
-			AppliedAt: time.Now(), AppliedBy: r.AppliedBy, DurationMS: 0,
-			Status: "success", ExecutionOrder: maxOrder,
+			AppliedAt: time.Now(), AppliedBy: appliedBy, DurationMS: 0,
+			Status: "success", ExecutionOrder: maxOrder, ToolVersion: toolVersion(),
+			DownChecksum: fp.DownChecksum,
 		}
 		if !fake {
 			// actually run .up.sql (baseline via executing)
-			tx, err := r.DB.BeginTx(ctx, nil)
-			if err != nil {
-				return applied, err
-			}
-			if _, err := tx.ExecContext(ctx, string(fp.UpBytes)); err != nil {
-				_ = tx.Rollback()
-				return applied, err
-			}
-			if err := tx.Commit(); err != nil {
+			if err := r.execUp(ctx, fp); err != nil {
 				return applied, err
 			}
 		}
-		if err := r.Storage.Upsert(ctx, row); err != nil {
+		if err := r.Storage.UpsertNext(ctx, &row); err != nil {
 			return applied, err
 		}
+		maxOrder = row.ExecutionOrder
 		applied = append(applied, row)
 	}
 	return applied, nil
 }
+
+// ParseIsolation parses a transaction isolation level: read-committed,
+// repeatable-read or serializable. Empty means sql.LevelDefault.
+func ParseIsolation(s string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return sql.LevelDefault, nil
+	case "read-committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable-read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+	return sql.LevelDefault, fmt.Errorf("invalid transaction isolation %q: want read-committed, repeatable-read or serializable", s)
+}
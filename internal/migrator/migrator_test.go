@@ -0,0 +1,761 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+)
+
+func TestApplyUp_SetsSessionLockWaitTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("SET SESSION lock_wait_timeout = 5").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET SESSION innodb_lock_wait_timeout = 5").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE t1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	// the pooled connection goes back with the server defaults
+	mock.ExpectExec("SET SESSION lock_wait_timeout = DEFAULT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET SESSION innodb_lock_wait_timeout = DEFAULT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.LockWaitTimeout = 5 * time.Second
+	files := []FilePair{{Version: "20250101000000", Name: "init", UpBytes: []byte("CREATE TABLE t1(id INT);"), Checksum: "x"}}
+	if _, err := r.ApplyUp(context.Background(), files, false, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUp_StatementTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE big SET x = 1").WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+	// database/sql rolls the transaction back itself once the context expires
+	// the failure is still recorded: the timeout only bounds the migration
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.StatementTimeout = 20 * time.Millisecond
+	files := []FilePair{{Version: "20250101000000", Name: "slow", UpBytes: []byte("UPDATE big SET x = 1;"), Checksum: "x"}}
+	var failed *Row
+	_, err = r.ApplyUp(context.Background(), files, false, func(stage string, fp FilePair, row *Row, err error) {
+		if stage == "error" {
+			failed = row
+		}
+	})
+	if !errors.Is(err, ErrMigrationTimeout) {
+		t.Fatalf("expected ErrMigrationTimeout, got %v", err)
+	}
+	if failed == nil || failed.Status != "failed" || failed.DurationMS < 20 {
+		t.Fatalf("failure must be recorded with the partial duration, got %+v", failed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestMigrationTimeoutClassification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	r := NewRunner(db, "schema_migrations", "tester")
+	fp := FilePair{Version: "1", Name: "a", DownBytes: []byte("DROP TABLE a")}
+	lookup := map[string]FilePair{Key("1", "a"): fp}
+	rows := []Row{{Version: "1", Name: "a", Status: "success"}}
+
+	// a SQL error is not a timeout
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE a").WillReturnError(errors.New("Unknown table 'a'"))
+	mock.ExpectRollback()
+	if err := r.ApplyDown(context.Background(), rows, lookup, false, nil); err == nil || errors.Is(err, ErrMigrationTimeout) {
+		t.Fatalf("expected a plain SQL error, got %v", err)
+	}
+
+	// a deadline on the caller's context, e.g. an overall run timeout, is
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE a").WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := r.ApplyDown(ctx, rows, lookup, false, nil); !errors.Is(err, ErrMigrationTimeout) {
+		t.Fatalf("expected ErrMigrationTimeout, got %v", err)
+	}
+}
+
+func TestApplyUp_StopsWhenLockLost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE t1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	lost := errors.New("lost advisory lock")
+	checks := 0
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.LockCheck = func(context.Context) error {
+		if checks++; checks > 1 {
+			return lost
+		}
+		return nil
+	}
+	files := []FilePair{
+		{Version: "20250101000000", Name: "one", UpBytes: []byte("CREATE TABLE t1(id INT);"), Checksum: "x"},
+		{Version: "20250102000000", Name: "two", UpBytes: []byte("CREATE TABLE t2(id INT);"), Checksum: "y"},
+	}
+	applied, err := r.ApplyUp(context.Background(), files, false, nil)
+	if !errors.Is(err, lost) || len(applied) != 1 {
+		t.Fatalf("expected to stop after the first migration: applied=%d err=%v", len(applied), err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUp_BatchCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	// batch 1: statements 1-2
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO t VALUES \\(1\\)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO t VALUES \\(2\\)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// batch 2: statement 3 fails; batch 1 stays committed
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO t VALUES \\(3\\)").WillReturnError(errors.New("boom"))
+	mock.ExpectRollback()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").
+		WithArgs("20250101000000", "load", "x", sqlmock.AnyArg(), "tester", sqlmock.AnyArg(), "failed", "dev", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	files := []FilePair{{
+		Version: "20250101000000", Name: "load", Checksum: "x", BatchCommit: 2,
+		UpBytes: []byte("INSERT INTO t VALUES (1);\nINSERT INTO t VALUES (2);\nINSERT INTO t VALUES (3);\n"),
+	}}
+	if _, err := r.ApplyUp(context.Background(), files, false, nil); err == nil {
+		t.Fatal("expected failure in second batch")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUp_PausesBetweenMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	var slept []time.Duration
+	orig := sleepCtx
+	sleepCtx = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	defer func() { sleepCtx = orig }()
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	for i := 1; i <= 3; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT execution_order").
+			WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(i)))
+	}
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.PauseBetween = 2 * time.Second
+	files := []FilePair{
+		{Version: "1", Name: "a", UpBytes: []byte("SELECT 1")},
+		{Version: "2", Name: "b", UpBytes: []byte("SELECT 1"), PauseAfter: 30 * time.Second},
+		{Version: "3", Name: "c", UpBytes: []byte("SELECT 1")},
+	}
+	if _, err := r.ApplyUp(context.Background(), files, false, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(slept) != 2 || slept[0] != 2*time.Second || slept[1] != 30*time.Second {
+		t.Fatalf("unexpected pauses: %v", slept)
+	}
+}
+
+func TestSleepCtxHonorsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepCtx(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestApplyUpTx_FollowsCallerTransaction(t *testing.T) {
+	for _, commit := range []bool{true, false} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO settings").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO `schema_migrations` .* SELECT").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT execution_order").
+			WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+		if commit {
+			mock.ExpectCommit()
+		} else {
+			mock.ExpectRollback()
+		}
+
+		r := NewRunner(db, "schema_migrations", "tester")
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		files := []FilePair{{Version: "1", Name: "seed", UpBytes: []byte("INSERT INTO settings VALUES (1)")}}
+		applied, err := r.ApplyUpTx(context.Background(), tx, files, nil)
+		if err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		if len(applied) != 1 || applied[0].ExecutionOrder != 1 {
+			t.Fatalf("unexpected applied rows: %+v", applied)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatalf("finish tx: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("expectations (commit=%v): %v", commit, err)
+		}
+		db.Close()
+	}
+}
+
+func TestApplyUp_AppliedByFromContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	}
+
+	r := NewRunner(db, "schema_migrations", "deployer")
+	files := []FilePair{{Version: "20250101000000", Name: "init"}}
+	ctx := WithAppliedBy(context.Background(), "admin@example.com")
+	applied, err := r.ApplyUp(ctx, files, true, nil)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if applied[0].AppliedBy != "admin@example.com" {
+		t.Fatalf("context value should win, got %q", applied[0].AppliedBy)
+	}
+	applied, err = r.ApplyUp(context.Background(), files, true, nil)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if applied[0].AppliedBy != "deployer" || r.AppliedBy != "deployer" {
+		t.Fatalf("expected runner fallback, got %q", applied[0].AppliedBy)
+	}
+}
+
+func TestApplyUpDown_SplitsStatements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	up := "CREATE TABLE audit (msg VARCHAR(64));\nINSERT INTO audit VALUES ('a;b');\nDELIMITER $$\nCREATE TRIGGER t BEFORE INSERT ON audit FOR EACH ROW BEGIN SET NEW.msg = TRIM(NEW.msg); END$$\nDELIMITER ;\n"
+	down := "DROP TRIGGER t;\nDROP TABLE audit;"
+	fp := FilePair{Version: "20250101000000", Name: "audit", UpBytes: []byte(up), DownBytes: []byte(down), Checksum: "x"}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(execution_order), 0) FROM `schema_migrations`")).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE audit (msg VARCHAR(64))")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit VALUES ('a;b')")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TRIGGER t BEFORE INSERT ON audit FOR EACH ROW BEGIN SET NEW.msg = TRIM(NEW.msg); END")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT execution_order FROM `schema_migrations` WHERE version=? AND name=?")).
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DROP TRIGGER t")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE audit")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `schema_migrations` WHERE version=? AND name=?")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	applied, err := r.ApplyUp(context.Background(), []FilePair{fp}, false, nil)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := r.ApplyDown(context.Background(), applied, map[string]FilePair{Key(fp.Version, fp.Name): fp}, false, nil); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUpDown_NoTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	up := "-- gomigratex:no-transaction\nCREATE INDEX CONCURRENTLY i ON t(id);"
+	down := "-- gomigratex:no-transaction\nDROP INDEX CONCURRENTLY i;"
+	writePair(t, dir, "20250101000000", "index", up, down)
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	fp := d.Files[0]
+	if !fp.NoTx || !fp.DownNoTx {
+		t.Fatalf("directive not parsed: %+v", fp)
+	}
+	if fp.Checksum != checksum.SHA256([]byte(up)) {
+		t.Fatal("checksum must include the directive line")
+	}
+
+	// No ExpectBegin: sqlmock fails the run if a transaction is opened.
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectExec("CREATE INDEX CONCURRENTLY i ON t\\(id\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	mock.ExpectExec("DROP INDEX CONCURRENTLY i").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM `schema_migrations`").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	applied, err := r.ApplyUp(context.Background(), []FilePair{fp}, false, nil)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := r.ApplyDown(context.Background(), applied, map[string]FilePair{Key(fp.Version, fp.Name): fp}, false, nil); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUp_FKChecksOffBracketsOnlyDirectedMigration(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "parents", "CREATE TABLE parents (id INT);", "")
+	writePair(t, dir, "20250101000001", "load", "-- gomigratex:fk-checks: off\nINSERT INTO children VALUES (1, 1);", "")
+	writePair(t, dir, "20250101000002", "more", "CREATE TABLE more (id INT);", "")
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if d.Files[0].FKChecksOff || !d.Files[1].FKChecksOff || d.Files[2].FKChecksOff {
+		t.Fatalf("directive parsed on the wrong files: %+v", d.Files)
+	}
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE parents").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	mock.ExpectBegin()
+	mock.ExpectExec("SET FOREIGN_KEY_CHECKS=0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO children").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SET FOREIGN_KEY_CHECKS=1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(2)))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE more").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(3)))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	if _, err := r.ApplyUp(context.Background(), d.Files, false, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestInTx_FKChecksRestoredAfterCancel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	mock.ExpectBegin()
+	mock.ExpectExec("SET FOREIGN_KEY_CHECKS=0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM parents").WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+	mock.ExpectExec("SET FOREIGN_KEY_CHECKS=1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = r.execInTx(ctx, plain("DELETE FROM parents"), txSettings{fkOff: true})
+	if err == nil {
+		t.Fatal("expected the cancelled migration to fail")
+	}
+	// The connection is either back in the pool with checks restored or gone.
+	if err := mock.ExpectationsWereMet(); err != nil && db.Stats().OpenConnections != 0 {
+		t.Fatalf("connection kept without restoring fk checks: %v", err)
+	}
+}
+
+func TestInTx_DiscardsConnectionWhenRestoreFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("SET FOREIGN_KEY_CHECKS=0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO children").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SET FOREIGN_KEY_CHECKS=1").WillReturnError(errors.New("connection reset"))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	if err := r.execInTx(context.Background(), plain("INSERT INTO children VALUES (1, 1)"), txSettings{fkOff: true}); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	if n := db.Stats().OpenConnections; n != 0 {
+		t.Fatalf("open connections = %d, want the unrestored one discarded", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestDiscover_RejectsInvalidFKChecks(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "load", "-- gomigratex:fk-checks: maybe\nSELECT 1;", "")
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err == nil || !strings.Contains(err.Error(), "want on or off") {
+		t.Fatalf("expected an on/off error, got %v", err)
+	}
+}
+
+func TestApplyUp_FailureThenRetryOrder(t *testing.T) {
+	files := []FilePair{
+		{Version: "1", Name: "a", UpBytes: []byte("CREATE TABLE a (id INT)")},
+		{Version: "2", Name: "b", UpBytes: []byte("CREATE TABLE b (id INT)")},
+	}
+	for _, tc := range []struct {
+		name       string
+		contiguous bool
+		retryOrder int64 // what the order column holds after the retry
+	}{
+		// the failed attempt took 2, so the retry takes 3
+		{name: "default", contiguous: false, retryOrder: 3},
+		// the failed attempt is stored as 0, so the retry takes 2
+		{name: "contiguous", contiguous: true, retryOrder: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer db.Close()
+			r := NewRunner(db, "schema_migrations", "tester")
+			r.ContiguousOrder = tc.contiguous
+
+			mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+			mock.ExpectBegin()
+			mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+			mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+				WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+			mock.ExpectBegin()
+			mock.ExpectExec("CREATE TABLE b").WillReturnError(errors.New("boom"))
+			mock.ExpectRollback()
+			if tc.contiguous {
+				// plain upsert with execution_order 0, no MAX computed
+				mock.ExpectExec("INSERT INTO `schema_migrations` .*VALUES").
+					WithArgs("2", "b", sqlmock.AnyArg(), sqlmock.AnyArg(), "tester", sqlmock.AnyArg(), "failed", int64(0), "dev", nil).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			} else {
+				mock.ExpectExec("INSERT INTO `schema_migrations` .*COALESCE\\(MAX\\(execution_order\\), 0\\) \\+ 1").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+					WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(2)))
+			}
+			if _, err := r.ApplyUp(context.Background(), files, false, nil); err == nil {
+				t.Fatal("expected the first run to fail")
+			}
+
+			// retry: MAX now covers the success and, by default, the failed row
+			mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(tc.retryOrder - 1))
+			mock.ExpectBegin()
+			mock.ExpectExec("CREATE TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+			mock.ExpectExec("INSERT INTO `schema_migrations` .*COALESCE\\(MAX\\(execution_order\\), 0\\) \\+ 1").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+				WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(tc.retryOrder))
+			applied, err := r.ApplyUp(context.Background(), files[1:], false, nil)
+			if err != nil {
+				t.Fatalf("retry: %v", err)
+			}
+			if applied[0].ExecutionOrder != tc.retryOrder {
+				t.Fatalf("retry order = %d, want %d", applied[0].ExecutionOrder, tc.retryOrder)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyDown_DetectsDownFileDrift(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	fp := FilePair{Version: "1", Name: "a", DownBytes: []byte("DROP TABLE a CASCADE;"), DownChecksum: checksum.SHA256([]byte("DROP TABLE a CASCADE;"))}
+	row := Row{Version: "1", Name: "a", Status: "success", DownChecksum: checksum.SHA256([]byte("DROP TABLE a;"))}
+	r := NewRunner(db, "schema_migrations", "tester")
+	err = r.ApplyDown(context.Background(), []Row{row}, map[string]FilePair{"1:a": fp}, false, nil)
+	if !errors.Is(err, ErrDownDrift) {
+		t.Fatalf("expected ErrDownDrift, got %v", err)
+	}
+	// nothing may run before the check
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+
+	// rows from before down checksums were recorded are not checked
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE a CASCADE").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM `schema_migrations`").WillReturnResult(sqlmock.NewResult(0, 1))
+	row.DownChecksum = ""
+	if err := r.ApplyDown(context.Background(), []Row{row}, map[string]FilePair{"1:a": fp}, false, nil); err != nil {
+		t.Fatalf("legacy row: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+// txRecorder is a database/sql driver connection that records the isolation
+// level of each transaction and the statements executed, which sqlmock
+// can't assert.
+type txRecorder struct {
+	levels []driver.IsolationLevel
+	execs  []string
+}
+
+func (r *txRecorder) Connect(context.Context) (driver.Conn, error) { return txRecorderConn{r}, nil }
+func (r *txRecorder) Driver() driver.Driver                        { return nil }
+
+type txRecorderConn struct{ r *txRecorder }
+
+func (c txRecorderConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("unsupported") }
+func (c txRecorderConn) Close() error                        { return nil }
+func (c txRecorderConn) Begin() (driver.Tx, error)           { return nil, errors.New("unsupported") }
+func (c txRecorderConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.r.levels = append(c.r.levels, opts.Isolation)
+	return txRecorderTx{}, nil
+}
+func (c txRecorderConn) ExecContext(_ context.Context, q string, _ []driver.NamedValue) (driver.Result, error) {
+	c.r.execs = append(c.r.execs, q)
+	return driver.RowsAffected(0), nil
+}
+
+type txRecorderTx struct{}
+
+func (txRecorderTx) Commit() error   { return nil }
+func (txRecorderTx) Rollback() error { return nil }
+
+func TestExecUp_Isolation(t *testing.T) {
+	rec := &txRecorder{}
+	db := sql.OpenDB(rec)
+	defer db.Close()
+
+	level, err := ParseIsolation("read-committed")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.Isolation = level
+	files := []FilePair{
+		{Version: "1", Name: "default", UpBytes: []byte("UPDATE a SET x = 1")},
+		{Version: "2", Name: "directed", UpBytes: []byte("UPDATE b SET x = 1"), Isolation: sql.LevelSerializable},
+	}
+	for _, fp := range files {
+		if err := r.execUp(context.Background(), fp); err != nil {
+			t.Fatalf("exec %s: %v", fp.Name, err)
+		}
+	}
+	want := []driver.IsolationLevel{driver.IsolationLevel(sql.LevelReadCommitted), driver.IsolationLevel(sql.LevelSerializable)}
+	if len(rec.levels) != 2 || rec.levels[0] != want[0] || rec.levels[1] != want[1] {
+		t.Fatalf("isolation levels = %v, want %v", rec.levels, want)
+	}
+	if len(rec.execs) != 2 {
+		t.Fatalf("execs = %v", rec.execs)
+	}
+
+	if _, err := ParseIsolation("snapshot"); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+}
+
+func TestDiscover_IsolationDirective(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "load", "-- gomigratex:isolation: repeatable-read\nUPDATE a SET x = 1;", "")
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if d.Files[0].Isolation != sql.LevelRepeatableRead {
+		t.Fatalf("isolation = %v", d.Files[0].Isolation)
+	}
+}
+
+func TestApplyUp_TotalBudgetDefersRest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE a").WillDelayFor(30 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	// nothing for 2 or 3: the first used up the budget
+
+	files := []FilePair{
+		{Version: "1", Name: "a", UpBytes: []byte("UPDATE a SET x = 1")},
+		{Version: "2", Name: "b", UpBytes: []byte("UPDATE b SET x = 1")},
+		{Version: "3", Name: "c", UpBytes: []byte("UPDATE c SET x = 1")},
+	}
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.TotalBudget = 20 * time.Millisecond
+	var warnings, deferred []string
+	r.OnWarn = func(msg string) { warnings = append(warnings, msg) }
+	applied, err := r.ApplyUp(context.Background(), files, false, func(stage string, fp FilePair, row *Row, err error) {
+		if stage == "deferred" {
+			deferred = append(deferred, fp.Version)
+		}
+	})
+	if err != nil {
+		t.Fatalf("running out of budget must not be an error: %v", err)
+	}
+	if len(applied) != 1 || strings.Join(deferred, ",") != "2,3" {
+		t.Fatalf("applied = %+v, deferred = %v", applied, deferred)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "1 applied, 2 deferred") {
+		t.Fatalf("warnings = %v", warnings)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+
+	// an est-duration that would overrun the budget defers even the first
+	r.TotalBudget = time.Hour
+	deferred = nil
+	long := FilePair{Version: "4", Name: "d", UpBytes: []byte("SELECT 1"), EstDuration: 2 * time.Hour}
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(1)))
+	applied, err = r.ApplyUp(context.Background(), []FilePair{long}, false, func(stage string, fp FilePair, row *Row, err error) {
+		if stage == "deferred" {
+			deferred = append(deferred, fp.Version)
+		}
+	})
+	if err != nil || len(applied) != 0 || len(deferred) != 1 {
+		t.Fatalf("applied = %+v, deferred = %v, err = %v", applied, deferred, err)
+	}
+}
@@ -11,6 +11,8 @@ type Row struct {
 	DurationMS     int64
 	Status         string // success | failed
 	ExecutionOrder int64
+	ToolVersion    string // gomigratex version that recorded the row
+	DownChecksum   string // checksum of the down file when applied; empty if unknown
 }
 
 // Key builds the canonical compound key for a migration identity.
@@ -0,0 +1,73 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mirajehossain/gomigratex/internal/sqlsplit"
+)
+
+// ErrMissingParam is returned when a migration references a :name parameter
+// that Runner.Params doesn't provide.
+var ErrMissingParam = errors.New("missing migration parameter")
+
+// stmt is one statement to execute with its bind arguments.
+type stmt struct {
+	query string
+	args  []any
+}
+
+func plain(queries ...string) []stmt {
+	out := make([]stmt, len(queries))
+	for i, q := range queries {
+		out[i] = stmt{query: q}
+	}
+	return out
+}
+
+// dialect is the lexical dialect of the runner's driver, used to split and
+// bind files.
+func (r *Runner) dialect() sqlsplit.Dialect {
+	return sqlsplit.Dialect(r.Storage.driver().Name())
+}
+
+// statements prepares body for execution: the file is split into statements
+// in the driver's dialect so the DSN doesn't need multiStatements=true, and
+// with named parameters every statement is bound separately. Under
+// ExecWholeFile a file without parameters is sent as a single Exec unless
+// split is set.
+func (r *Runner) statements(body []byte, split bool) ([]stmt, error) {
+	params := sqlsplit.NamedParamsDialect(string(body), r.dialect())
+	if len(params) == 0 && !split && r.ExecWholeFile {
+		return plain(string(body)), nil
+	}
+	parts := sqlsplit.SplitDialect(string(body), r.dialect())
+	if len(params) == 0 {
+		return plain(parts...), nil
+	}
+	out := make([]stmt, 0, len(parts))
+	for _, part := range parts {
+		q, names := sqlsplit.BindNamedDialect(part, r.dialect(), r.Storage.driver().Placeholder)
+		s := stmt{query: q}
+		for _, n := range names {
+			v, ok := r.Params[n]
+			if !ok {
+				return nil, fmt.Errorf("%w: :%s", ErrMissingParam, n)
+			}
+			s.args = append(s.args, v)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// checkParams fails before anything runs if body, one of fp's files, needs
+// a parameter that isn't set.
+func (r *Runner) checkParams(fp FilePair, body []byte) error {
+	for _, n := range sqlsplit.NamedParamsDialect(string(body), r.dialect()) {
+		if _, ok := r.Params[n]; !ok {
+			return fmt.Errorf("migration %s:%s: %w: :%s", fp.Version, fp.Name, ErrMissingParam, n)
+		}
+	}
+	return nil
+}
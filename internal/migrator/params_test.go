@@ -0,0 +1,91 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestApplyUp_BindsNamedParams(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	up := "INSERT INTO tenants (id, region) VALUES (:tenant_id, :region);\nUPDATE settings SET owner = :tenant_id;"
+	writePair(t, dir, "20250101000000", "seed", up, "DELETE FROM tenants WHERE id = :tenant_id;")
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	fp := d.Files[0]
+	if fp.Checksum != checksum.SHA256([]byte(up)) {
+		t.Fatal("checksum must be over the raw SQL")
+	}
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tenants \\(id, region\\) VALUES \\(\\?, \\?\\)").
+		WithArgs("t-42", "eu").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE settings SET owner = \\?").
+		WithArgs("t-42").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.Params = map[string]any{"tenant_id": "t-42", "region": "eu"}
+	if _, err := r.ApplyUp(context.Background(), []FilePair{fp}, false, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUp_MissingParam(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	r.Params = map[string]any{"region": "eu"}
+	files := []FilePair{{Version: "1", Name: "seed", UpBytes: []byte("INSERT INTO t VALUES (:tenant_id)"), UpParams: []string{"tenant_id"}}}
+	if _, err := r.ApplyUp(context.Background(), files, false, nil); !errors.Is(err, ErrMissingParam) {
+		t.Fatalf("expected ErrMissingParam, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("nothing should run: %v", err)
+	}
+}
+
+func TestStatements_PostgresKeepsFunctionBodies(t *testing.T) {
+	r := NewRunner(nil, "schema_migrations", "tester")
+	r.Storage.Driver = db.Postgres
+	r.Params = map[string]any{"tenant": "acme"}
+	body := "CREATE FUNCTION f() RETURNS int AS $$ BEGIN x := :inner; RETURN 1; END $$ LANGUAGE plpgsql;\nSELECT doc #>> '{owner}' FROM t WHERE id = :tenant;"
+	if err := r.checkParams(FilePair{Version: "1", Name: "f"}, []byte(body)); err != nil {
+		t.Fatalf("a :name inside a function body is not a parameter: %v", err)
+	}
+	stmts, err := r.statements([]byte(body), false)
+	if err != nil {
+		t.Fatalf("statements: %v", err)
+	}
+	if len(stmts) != 2 || stmts[0].query != "CREATE FUNCTION f() RETURNS int AS $$ BEGIN x := :inner; RETURN 1; END $$ LANGUAGE plpgsql" ||
+		stmts[1].query != "SELECT doc #>> '{owner}' FROM t WHERE id = $1" || len(stmts[1].args) != 1 || stmts[1].args[0] != "acme" {
+		t.Fatalf("stmts = %+v", stmts)
+	}
+}
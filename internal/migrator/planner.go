@@ -2,20 +2,74 @@ package migrator
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io/fs"
-	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/mirajehossain/gomigratex/internal/checksum"
-	"github.com/mirajehossain/gomigratex/internal/fsutil"
 )
 
 type FileSource struct {
-	FS       fs.FS // nil means local disk
-	RootDir  string
+	FS      fs.FS // read from FS whenever non-nil; nil means local disk
+	RootDir string
+
+	// UpRootDir and DownRootDir, set together instead of RootDir, hold up
+	// and down files in separate trees (on FS when it is set). Files are
+	// paired by version and name across them.
+	UpRootDir   string
+	DownRootDir string
+
+	// Ext and Dialect select which files are migrations; see
+	// fsutil.ScanOptions. Ext defaults to ".sql".
+	Ext     string
+	Dialect string
+	// Recursive scans subdirectories of each source too.
+	Recursive bool
+
+	// Checksum computes a migration's checksum from its up file. nil means
+	// checksum.SHA256. Set it to match checksums stored by another tool
+	// while transitioning, then repair the rows and return to the default.
+	Checksum ChecksumFunc
+
+	// Deprecated: FS is used whenever it is non-nil, embedded or not.
+	// Embedded is kept for compatibility and has no effect.
 	Embedded bool
+
+	// Layers are further sources scanned after FS/RootDir, in order. A later
+	// layer's migration replaces an earlier one with the same version and
+	// name (reported in Plan.Overrides); other migrations are added.
+	Layers []Layer
+
+	// Inline migrations are merged with discovered files; with no RootDir
+	// and no FS they are the only source. A version present both inline and
+	// on disk is an error.
+	Inline []InlineMigration
+
+	// Go migrations are merged like Inline ones. DiscoverAndPlan adds those
+	// registered with RegisterGoMigration.
+	Go []GoMigration
+}
+
+// ChecksumFunc computes the stored checksum of a migration's up file.
+type ChecksumFunc func(up []byte) string
+
+// Layer is one migration source in FileSource.Layers: FS when non-nil,
+// otherwise RootDir on local disk.
+type Layer struct {
+	FS      fs.FS
+	RootDir string
+}
+
+// InlineMigration is a migration given as literal SQL instead of files.
+type InlineMigration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string
 }
 
 type FilePair struct {
@@ -26,76 +80,349 @@ type FilePair struct {
 	UpBytes   []byte
 	DownBytes []byte
 	Checksum  string
+	// DownChecksum is the down file's checksum, recorded so ApplyDown can
+	// detect an edited down file.
+	DownChecksum string
+
+	// BatchCommit, from `-- gomigratex:batch-commit: N`, commits the up file
+	// every N statements instead of in one transaction.
+	BatchCommit int
+	// PauseAfter, from `-- gomigratex:pause-after: 30s`, waits after this
+	// migration before starting the next one.
+	PauseAfter time.Duration
+	// EstDuration, from `-- gomigratex:est-duration: 5m`, is the expected
+	// run time used by EstimatePlan.
+	EstDuration time.Duration
+	// NoTx and DownNoTx, from `-- gomigratex:no-transaction` in the up or
+	// down file, run that file's statements outside a transaction, for DDL
+	// such as CREATE INDEX CONCURRENTLY.
+	NoTx     bool
+	DownNoTx bool
+	// Isolation, from `-- gomigratex:isolation: read-committed`, overrides
+	// Runner.Isolation for the up file's transactions.
+	Isolation sql.IsolationLevel
+	// FKChecksOff, from `-- gomigratex:fk-checks: off`, disables MySQL
+	// foreign key checks for the session running this migration only.
+	FKChecksOff bool
+	// UpParams and DownParams are the :name parameters each file binds from
+	// Runner.Params, found with MySQL's lexical rules. The runner finds them
+	// again in its driver's dialect when it runs the file.
+	UpParams   []string
+	DownParams []string
+
+	inline bool // contents came from FileSource.Inline, nothing to read
+	layer  int  // source the files come from: 0 is FS/RootDir, n is Layers[n-1]
+
+	isGo   bool // a GoMigration: UpBytes and DownBytes only describe it
+	goUp   GoMigrationFunc
+	goDown GoMigrationFunc
 }
 
 type Plan struct {
 	Pending []FilePair // to apply in order
 	Applied map[string]Row
 	All     []FilePair // all discovered
+	Skipped []FilePair // pending but excluded via WithSkip
+	Future  []FilePair // pending but dated after now, excluded via WithIgnoreFuture
+	// CoolingDown are failed migrations held back by WithFailedRetryAfter
+	// because they failed too recently.
+	CoolingDown []FilePair
+
+	// DriftRepaired lists version:name keys whose stored checksum was
+	// rewritten by a DriftRepair policy during planning. Callers should warn
+	// about these so auto-repair doesn't hide unexpected file edits.
+	DriftRepaired []string
+	// DriftIgnored lists version:name keys whose drift was tolerated because
+	// they are listed in WithIgnoreDrift. Callers should warn about them.
+	DriftIgnored []string
+	// Overrides lists migrations replaced by a later FileSource layer.
+	// Callers should warn about them, especially when Changed.
+	Overrides []LayerOverride
+	// OutOfOrder lists pending migrations whose version is lower than the
+	// highest successfully applied version, typically merged from a
+	// long-lived branch. They are still applied; callers should warn so the
+	// team can decide whether to renumber them.
+	OutOfOrder []FilePair
 }
 
 var (
 	ErrDrift = errors.New("checksum drift detected")
+	// ErrDownDrift is returned by ApplyDown when a down file no longer
+	// matches the checksum recorded when its migration was applied.
+	ErrDownDrift = errors.New("down file checksum drift detected")
+	// ErrChecksumAlgo is returned instead of ErrDrift when the stored
+	// checksum was computed with a different algorithm than the files.
+	ErrChecksumAlgo = errors.New("checksum algorithm changed")
+	// ErrOrphaned is returned under WithFailOnOrphan when applied rows have
+	// no matching file.
+	ErrOrphaned = errors.New("orphaned migrations")
+)
+
+// DriftError is returned by DiscoverAndPlan when an applied migration's file
+// no longer matches its stored checksum. It matches ErrDrift with errors.Is.
+type DriftError struct {
+	Key     string // version:name
+	Stored  string // checksum in the tracking table
+	Current string // checksum of the file
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%v: %s (db=%s file=%s)", ErrDrift, e.Key, e.Stored, e.Current)
+}
+
+func (e *DriftError) Unwrap() error { return ErrDrift }
+
+// Fingerprint is a stable hash over every discovered migration's version,
+// name and checksum, independent of the order of All. Equal fingerprints mean
+// the migration set hasn't changed, so callers can skip work.
+func (p *Plan) Fingerprint() string {
+	lines := make([]string, len(p.All))
+	for i, fp := range p.All {
+		lines[i] = fp.Version + ":" + fp.Name + ":" + strings.ToLower(fp.Checksum)
+	}
+	sort.Strings(lines)
+	return checksum.SHA256([]byte(strings.Join(lines, "\n")))
+}
+
+// outOfOrder returns the pending migrations versioned below the highest
+// successfully applied version.
+func outOfOrder(pending []FilePair, applied map[string]Row) []FilePair {
+	var highest string
+	for _, row := range applied {
+		if row.Status == "success" && row.Version > highest {
+			highest = row.Version
+		}
+	}
+	var out []FilePair
+	for _, fp := range pending {
+		if fp.Version < highest {
+			out = append(out, fp)
+		}
+	}
+	return out
+}
+
+// Orphans returns applied rows with no matching migration file, ordered by
+// execution order. An orphan usually means a file was deleted or the
+// migration set comes from the wrong branch.
+func (p *Plan) Orphans() []Row {
+	files := make(map[string]bool, len(p.All))
+	for _, fp := range p.All {
+		files[Key(fp.Version, fp.Name)] = true
+	}
+	var out []Row
+	for k, row := range p.Applied {
+		if !files[k] {
+			out = append(out, row)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ExecutionOrder < out[j].ExecutionOrder })
+	return out
+}
+
+// DriftAction tells the planner how to handle a checksum mismatch.
+type DriftAction int
+
+const (
+	// DriftFail aborts planning with ErrDrift (default).
+	DriftFail DriftAction = iota
+	// DriftIgnore keeps the migration as applied and leaves the stored checksum untouched.
+	DriftIgnore
+	// DriftRepair overwrites the stored checksum with the file's current checksum.
+	DriftRepair
 )
 
+// DriftPolicy decides what to do when an applied migration's stored checksum
+// differs from the file on disk. Returning an error aborts planning.
+type DriftPolicy interface {
+	OnDrift(key, stored, current string) (DriftAction, error)
+}
+
+// DriftPolicyFunc adapts a plain function to DriftPolicy.
+type DriftPolicyFunc func(key, stored, current string) (DriftAction, error)
+
+func (f DriftPolicyFunc) OnDrift(key, stored, current string) (DriftAction, error) {
+	return f(key, stored, current)
+}
+
+// PlanOption customizes how DiscoverAndPlan builds a plan.
+type PlanOption func(*planOptions)
+
+type planOptions struct {
+	asOf        time.Time
+	driftPolicy DriftPolicy
+	ignoreDrift map[string]bool
+	skip        map[string]bool
+	futureAfter time.Time
+	failOrphan  bool
+	retryAfter  time.Duration
+	onWarn      func(msg string)
+}
+
+func (o *planOptions) warn(format string, args ...any) {
+	if o.onWarn != nil {
+		o.onWarn(fmt.Sprintf(format, args...))
+	}
+}
+
+// WithAsOf plans against the applied state at t instead of now: only rows
+// with applied_at <= t count as applied. Intended for read-only inspection:
+// a drift policy returning DriftRepair fails planning instead of rewriting
+// checksums.
+func WithAsOf(t time.Time) PlanOption {
+	return func(o *planOptions) { o.asOf = t }
+}
+
+// WithDriftPolicy installs a custom drift policy. Without it, drift fails planning.
+func WithDriftPolicy(p DriftPolicy) PlanOption {
+	return func(o *planOptions) { o.driftPolicy = p }
+}
+
+// WithIgnoreDrift tolerates drift on the given version:name keys, for
+// migrations that are legitimately regenerated (formatters, generated DDL).
+// They stay applied and are reported in Plan.DriftIgnored; their stored
+// checksum is not updated, so they keep drifting until repaired. Drift on
+// any other migration still goes to the drift policy.
+func WithIgnoreDrift(keys ...string) PlanOption {
+	return func(o *planOptions) {
+		if o.ignoreDrift == nil {
+			o.ignoreDrift = map[string]bool{}
+		}
+		for _, k := range keys {
+			o.ignoreDrift[k] = true
+		}
+	}
+}
+
+// WithSkip excludes the given versions from Pending and reports them in
+// Plan.Skipped instead. Their tracking rows are left as-is, so they stay
+// pending/failed for later attention. Later migrations that depend on a
+// skipped one will fail.
+func WithSkip(versions ...string) PlanOption {
+	return func(o *planOptions) {
+		if o.skip == nil {
+			o.skip = map[string]bool{}
+		}
+		for _, v := range versions {
+			o.skip[v] = true
+		}
+	}
+}
+
+// WithOnWarn sends planning warnings to fn, one per skipped migration and
+// per migration whose drift was repaired, so they reach the same log as
+// Runner.OnWarn. Pass runner.OnWarn to share it.
+func WithOnWarn(fn func(msg string)) PlanOption {
+	return func(o *planOptions) { o.onWarn = fn }
+}
+
+// WithFailOnOrphan makes planning fail with ErrOrphaned if any applied row
+// has no matching file, instead of leaving callers to warn about
+// Plan.Orphans.
+func WithFailOnOrphan() PlanOption {
+	return func(o *planOptions) { o.failOrphan = true }
+}
+
+// WithFailedRetryAfter delays retrying a failed migration until d after its
+// recorded applied_at, so crash-looping deploys don't retry it instantly.
+// Until then it is reported in Plan.CoolingDown instead of Pending.
+func WithFailedRetryAfter(d time.Duration) PlanOption {
+	return func(o *planOptions) { o.retryAfter = d }
+}
+
+// WithIgnoreFuture excludes pending migrations whose timestamp version
+// (YYYYMMDDHHMMSS, UTC) is after now, reporting them in Plan.Future. They
+// become eligible once their time passes. Versions that don't parse as a
+// timestamp are never treated as future.
+func WithIgnoreFuture(now time.Time) PlanOption {
+	return func(o *planOptions) { o.futureAfter = now }
+}
+
+// versionTime parses a timestamp version.
+func versionTime(version string) (time.Time, bool) {
+	if len(version) != len(versionLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(versionLayout, version)
+	return t, err == nil
+}
+
+const versionLayout = "20060102150405"
+
 // DiscoverAndPlan loads migration pairs and decides which to run.
 // Out-of-order applies are supported: anything not (status=success) is considered pending.
-func DiscoverAndPlan(ctx context.Context, src FileSource, st *Storage) (*Plan, error) {
-	var pairs map[string]*fsutil.Pair
-	var err error
-	if src.Embedded && src.FS != nil {
-		pairs, err = fsutil.ScanEmbedded(src.FS, src.RootDir)
-	} else {
-		pairs, err = fsutil.ScanDir(src.RootDir)
+// Pending migrations older than the newest applied one are listed in Plan.OutOfOrder.
+func DiscoverAndPlan(ctx context.Context, src FileSource, st *Storage, opts ...PlanOption) (*Plan, error) {
+	var o planOptions
+	for _, opt := range opts {
+		opt(&o)
 	}
+	src.Go = append(RegisteredGoMigrations(), src.Go...)
+	d, err := Discover(src)
 	if err != nil {
 		return nil, err
 	}
-	// Read file contents & checksum
-	all := make([]FilePair, 0, len(pairs))
-	keys := fsutil.SortKeys(pairs)
-	for _, k := range keys {
-		p := pairs[k]
-		var upb, downb []byte
-		if src.Embedded && src.FS != nil {
-			upb, err = fs.ReadFile(src.FS, p.UpPath)
-			if err != nil {
-				return nil, err
-			}
-			downb, err = fs.ReadFile(src.FS, p.DownPath)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			upb, err = os.ReadFile(p.UpPath)
-			if err != nil {
-				return nil, err
-			}
-			downb, err = os.ReadFile(p.DownPath)
-			if err != nil {
-				return nil, err
-			}
-		}
-		chk := checksum.SHA256(upb) // checksum on up file
-		all = append(all, FilePair{
-			Version: p.Version, Name: p.Name, UpPath: p.UpPath, DownPath: p.DownPath,
-			UpBytes: upb, DownBytes: downb, Checksum: chk,
-		})
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	if err := d.Load(); err != nil {
+		return nil, err
+	}
+	all := d.Files
+	var applied map[string]Row
+	if o.asOf.IsZero() {
+		applied, err = st.GetAll(ctx)
+	} else {
+		applied, err = st.GetAllAsOf(ctx, o.asOf)
 	}
-	applied, err := st.GetAll(ctx)
 	if err != nil {
 		return nil, err
 	}
 	pending := make([]FilePair, 0, len(all))
+	var repaired, ignored []string
+	var cooling []FilePair
+	now := time.Now()
 	for _, fp := range all {
 		k := Key(fp.Version, fp.Name)
 		if row, ok := applied[k]; ok {
 			// If recorded success but checksum differs => drift
 			if row.Status == "success" && !strings.EqualFold(row.Checksum, fp.Checksum) {
-				return nil, fmt.Errorf("%w: %s (db=%s file=%s)", ErrDrift, k, row.Checksum, fp.Checksum)
+				action := DriftFail
+				if o.ignoreDrift[k] {
+					action = DriftIgnore
+					ignored = append(ignored, k)
+				} else if o.driftPolicy != nil {
+					action, err = o.driftPolicy.OnDrift(k, row.Checksum, fp.Checksum)
+					if err != nil {
+						return nil, err
+					}
+				}
+				switch action {
+				case DriftIgnore:
+				case DriftRepair:
+					if !o.asOf.IsZero() {
+						return nil, fmt.Errorf("%w: %s can't be repaired in a plan as of %s", ErrDrift, k, o.asOf.Format(time.RFC3339))
+					}
+					if err := st.UpdateChecksum(ctx, fp.Version, fp.Name, fp.Checksum); err != nil {
+						return nil, err
+					}
+					o.warn("repaired checksum drift on %s (db=%s file=%s); the applied file was edited", k, row.Checksum, fp.Checksum)
+					row.Checksum = fp.Checksum
+					applied[k] = row
+					repaired = append(repaired, k)
+				default:
+					if stored, current := checksum.Algo(row.Checksum), checksum.Algo(fp.Checksum); stored != current {
+						return nil, fmt.Errorf("%w: %s was recorded with %s, files are hashed with %s; run repair to re-hash", ErrChecksumAlgo, k, stored, current)
+					}
+					return nil, &DriftError{Key: k, Stored: row.Checksum, Current: fp.Checksum}
+				}
 			}
 			// If failed previously, retry
 			if row.Status == "failed" {
+				if o.retryAfter > 0 && now.Sub(row.AppliedAt) < o.retryAfter {
+					cooling = append(cooling, fp)
+					continue
+				}
 				pending = append(pending, fp)
 			}
 			continue // already applied
@@ -103,5 +430,40 @@ func DiscoverAndPlan(ctx context.Context, src FileSource, st *Storage) (*Plan, e
 		// Not present -> pending
 		pending = append(pending, fp)
 	}
-	return &Plan{Pending: pending, Applied: applied, All: all}, nil
+	var skipped []FilePair
+	if len(o.skip) > 0 {
+		kept := pending[:0]
+		for _, fp := range pending {
+			if o.skip[fp.Version] {
+				skipped = append(skipped, fp)
+				o.warn("skipping migration %s; later migrations that depend on it will fail", Key(fp.Version, fp.Name))
+				continue
+			}
+			kept = append(kept, fp)
+		}
+		pending = kept
+	}
+	var future []FilePair
+	if !o.futureAfter.IsZero() {
+		kept := pending[:0]
+		for _, fp := range pending {
+			if t, ok := versionTime(fp.Version); ok && t.After(o.futureAfter) {
+				future = append(future, fp)
+				continue
+			}
+			kept = append(kept, fp)
+		}
+		pending = kept
+	}
+	plan := &Plan{Pending: pending, Applied: applied, All: all, Skipped: skipped, Future: future, CoolingDown: cooling, DriftRepaired: repaired, DriftIgnored: ignored, Overrides: d.Overrides, OutOfOrder: outOfOrder(pending, applied)}
+	if o.failOrphan {
+		if orphans := plan.Orphans(); len(orphans) > 0 {
+			keys := make([]string, len(orphans))
+			for i, row := range orphans {
+				keys[i] = Key(row.Version, row.Name)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrOrphaned, strings.Join(keys, ", "))
+		}
+	}
+	return plan, nil
 }
@@ -1,9 +1,14 @@
 package migrator
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,7 +40,7 @@ func TestDiscoverAndPlan_PendingAndApplied(t *testing.T) {
 		t.Fatalf("sqlmock: %v", err)
 	}
 	defer db.Close()
-	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order"}
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
 	// compute real checksum of the up file to avoid drift error
 	upb, err := os.ReadFile(filepath.Join(dir, "20250101000000_init.up.sql"))
 	if err != nil {
@@ -43,7 +48,7 @@ func TestDiscoverAndPlan_PendingAndApplied(t *testing.T) {
 	}
 	chk := checksum.SHA256(upb)
 	rows := sqlmock.NewRows(columns).
-		AddRow("20250101000000", "init", chk, time.Now(), "tester", int64(5), "success", int64(1))
+		AddRow("20250101000000", "init", chk, time.Now(), "tester", int64(5), "success", int64(1), "dev", nil)
 	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(rows)
 
 	st := &Storage{DB: db, Table: "schema_migrations"}
@@ -67,3 +72,479 @@ func TestKey(t *testing.T) {
 		t.Fatal("key mismatch")
 	}
 }
+
+func TestPlanFingerprint(t *testing.T) {
+	a := FilePair{Version: "20250101000000", Name: "init", Checksum: "aa"}
+	b := FilePair{Version: "20250102000000", Name: "more", Checksum: "bb"}
+	fp1 := (&Plan{All: []FilePair{a, b}}).Fingerprint()
+	if fp2 := (&Plan{All: []FilePair{b, a}}).Fingerprint(); fp1 != fp2 {
+		t.Fatal("fingerprint must not depend on order")
+	}
+	b.Checksum = "cc"
+	if fp3 := (&Plan{All: []FilePair{a, b}}).Fingerprint(); fp1 == fp3 {
+		t.Fatal("fingerprint must change when a file changes")
+	}
+}
+
+func TestDiscoverAndPlan_AsOf(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	writePair(t, dir, "20250102000000", "add_col", "ALTER TABLE t1 ADD COLUMN c INT;", "ALTER TABLE t1 DROP COLUMN c;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	chk1 := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+	chk2 := checksum.SHA256([]byte("ALTER TABLE t1 ADD COLUMN c INT;"))
+	release := time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", chk1, release.Add(-48*time.Hour), "tester", int64(5), "success", int64(1), "dev", nil).
+		AddRow("20250102000000", "add_col", chk2, release.Add(24*time.Hour), "tester", int64(5), "success", int64(2), "dev", nil)
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(rows)
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st, WithAsOf(release))
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(plan.Applied) != 1 {
+		t.Fatalf("expected 1 applied as of cutoff, got %d", len(plan.Applied))
+	}
+	if len(plan.Pending) != 1 || plan.Pending[0].Name != "add_col" {
+		t.Fatalf("expected add_col pending as of cutoff, got %+v", plan.Pending)
+	}
+}
+
+func TestDiscoverAndPlan_AsOfRefusesDriftRepair(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	release := time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", "stale", release.Add(-time.Hour), "tester", int64(5), "success", int64(1), "dev", nil))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	repair := DriftPolicyFunc(func(k, s, c string) (DriftAction, error) { return DriftRepair, nil })
+	if _, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st, WithAsOf(release), WithDriftPolicy(repair)); !errors.Is(err, ErrDrift) {
+		t.Fatalf("expected ErrDrift, got %v", err)
+	}
+	// no UPDATE was expected: the checksum must be left alone
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestDiscoverAndPlan_DriftPolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  DriftPolicy
+		repair  bool
+		wantErr bool
+	}{
+		{name: "default", policy: nil, wantErr: true},
+		{name: "fail", policy: DriftPolicyFunc(func(k, s, c string) (DriftAction, error) { return DriftFail, nil }), wantErr: true},
+		{name: "ignore", policy: DriftPolicyFunc(func(k, s, c string) (DriftAction, error) { return DriftIgnore, nil })},
+		{name: "repair", policy: DriftPolicyFunc(func(k, s, c string) (DriftAction, error) { return DriftRepair, nil }), repair: true},
+		{name: "error", policy: DriftPolicyFunc(func(k, s, c string) (DriftAction, error) { return DriftIgnore, errors.New("denied") }), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer db.Close()
+			columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+			mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("20250101000000", "init", "stale", time.Now(), "tester", int64(5), "success", int64(1), "dev", nil))
+			current := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+			if tc.repair {
+				mock.ExpectExec("UPDATE `schema_migrations` SET checksum").
+					WithArgs(current, "20250101000000", "init").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			var warnings []string
+			opts := []PlanOption{WithOnWarn(func(msg string) { warnings = append(warnings, msg) })}
+			if tc.policy != nil {
+				opts = append(opts, WithDriftPolicy(tc.policy))
+			}
+			st := &Storage{DB: db, Table: "schema_migrations"}
+			plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st, opts...)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if len(plan.Pending) != 0 {
+				t.Fatalf("expected nothing pending, got %d", len(plan.Pending))
+			}
+			if tc.repair && plan.Applied["20250101000000:init"].Checksum != current {
+				t.Fatal("expected repaired checksum in plan")
+			}
+			if tc.repair != (len(plan.DriftRepaired) == 1 && plan.DriftRepaired[0] == "20250101000000:init") {
+				t.Fatalf("unexpected DriftRepaired: %v", plan.DriftRepaired)
+			}
+			if tc.repair != (len(warnings) == 1 && strings.Contains(warnings[0], "20250101000000:init") && strings.Contains(warnings[0], "db=stale")) {
+				t.Fatalf("unexpected warnings: %q", warnings)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestDiscoverAndPlan_DriftError(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", "stale", time.Now(), "tester", int64(5), "success", int64(1), "dev", nil))
+
+	_, err = DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, &Storage{DB: db, Table: "schema_migrations"})
+	if !errors.Is(err, ErrDrift) {
+		t.Fatalf("expected ErrDrift, got %v", err)
+	}
+	var de *DriftError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DriftError, got %T", err)
+	}
+	current := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+	if de.Key != "20250101000000:init" || de.Stored != "stale" || de.Current != current {
+		t.Fatalf("unexpected fields: %+v", de)
+	}
+}
+
+func TestDiscoverAndPlan_IgnoreDrift(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "generated", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	writePair(t, dir, "20250102000000", "hand_written", "CREATE TABLE t2(id INT);", "DROP TABLE t2;")
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	plan := func(driftOn string) (*Plan, error) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		defer db.Close()
+		sums := map[string]string{
+			"generated":    checksum.SHA256([]byte("CREATE TABLE t1(id INT);")),
+			"hand_written": checksum.SHA256([]byte("CREATE TABLE t2(id INT);")),
+		}
+		sums[driftOn] = "stale"
+		mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("20250101000000", "generated", sums["generated"], time.Now(), "tester", int64(5), "success", int64(1), "dev", nil).
+			AddRow("20250102000000", "hand_written", sums["hand_written"], time.Now(), "tester", int64(5), "success", int64(2), "dev", nil))
+		st := &Storage{DB: db, Table: "schema_migrations"}
+		p, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st, WithIgnoreDrift("20250101000000:generated"))
+		// no UPDATE is expected: ignored drift leaves the stored checksum alone
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("expectations: %v", err)
+		}
+		return p, err
+	}
+
+	p, err := plan("generated")
+	if err != nil {
+		t.Fatalf("ignored drift must not fail: %v", err)
+	}
+	if len(p.Pending) != 0 || len(p.DriftIgnored) != 1 || p.DriftIgnored[0] != "20250101000000:generated" {
+		t.Fatalf("unexpected plan: pending=%d ignored=%v", len(p.Pending), p.DriftIgnored)
+	}
+	if p.Applied["20250101000000:generated"].Checksum != "stale" {
+		t.Fatal("stored checksum must not be updated")
+	}
+
+	if _, err := plan("hand_written"); !errors.Is(err, ErrDrift) {
+		t.Fatalf("drift on an unlisted migration must fail, got %v", err)
+	}
+}
+
+func TestDiscoverAndPlan_Skip(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	writePair(t, dir, "20250102000000", "broken", "ALTER TABLE nope ADD COLUMN c INT;", "SELECT 1;")
+	writePair(t, dir, "20250103000000", "later", "CREATE TABLE t3(id INT);", "DROP TABLE t3;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	chk1 := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+	chk2 := checksum.SHA256([]byte("ALTER TABLE nope ADD COLUMN c INT;"))
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", chk1, time.Now(), "tester", int64(5), "success", int64(1), "dev", nil).
+		AddRow("20250102000000", "broken", chk2, time.Now(), "tester", int64(5), "failed", int64(2), "dev", nil))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	var warnings []string
+	plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st, WithSkip("20250102000000"),
+		WithOnWarn(func(msg string) { warnings = append(warnings, msg) }))
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "20250102000000:broken") {
+		t.Fatalf("expected one warning naming the skipped migration, got %q", warnings)
+	}
+	if len(plan.Pending) != 1 || plan.Pending[0].Name != "later" {
+		t.Fatalf("expected only later pending, got %+v", plan.Pending)
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0].Name != "broken" {
+		t.Fatalf("expected broken skipped, got %+v", plan.Skipped)
+	}
+}
+
+func TestDiscoverAndPlan_IgnoreFuture(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	writePair(t, dir, "20990101000000", "scheduled", "CREATE TABLE t2(id INT);", "DROP TABLE t2;")
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name    string
+		opts    []PlanOption
+		pending int
+		future  int
+	}{
+		{"off", nil, 2, 0},
+		{"on", []PlanOption{WithIgnoreFuture(now)}, 1, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer db.Close()
+			columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+			mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns))
+
+			st := &Storage{DB: db, Table: "schema_migrations"}
+			plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st, tc.opts...)
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if len(plan.Pending) != tc.pending || len(plan.Future) != tc.future {
+				t.Fatalf("pending=%d future=%d", len(plan.Pending), len(plan.Future))
+			}
+			if tc.future == 1 && plan.Future[0].Name != "scheduled" {
+				t.Fatalf("unexpected future: %+v", plan.Future)
+			}
+		})
+	}
+}
+
+func TestDiscoverAndPlan_Orphans(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	chk := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+
+	for _, fail := range []bool{false, true} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+		mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("20250101000000", "init", chk, time.Now(), "tester", int64(1), "success", int64(1), "dev", nil).
+			AddRow("20250102000000", "deleted", "abc", time.Now(), "tester", int64(1), "success", int64(2), "dev", nil))
+
+		var opts []PlanOption
+		if fail {
+			opts = append(opts, WithFailOnOrphan())
+		}
+		st := &Storage{DB: db, Table: "schema_migrations"}
+		plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st, opts...)
+		if fail {
+			if !errors.Is(err, ErrOrphaned) {
+				t.Fatalf("expected ErrOrphaned, got %v", err)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			orphans := plan.Orphans()
+			if len(orphans) != 1 || orphans[0].Name != "deleted" {
+				t.Fatalf("unexpected orphans: %+v", orphans)
+			}
+		}
+		db.Close()
+	}
+}
+
+func TestDiscoverAndPlan_OutOfOrder(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	writePair(t, dir, "20250105000000", "from_branch", "CREATE TABLE t2(id INT);", "DROP TABLE t2;")
+	writePair(t, dir, "20250110000000", "main", "CREATE TABLE t3(id INT);", "DROP TABLE t3;")
+	writePair(t, dir, "20250115000000", "next", "CREATE TABLE t4(id INT);", "DROP TABLE t4;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", checksum.SHA256([]byte("CREATE TABLE t1(id INT);")), time.Now(), "tester", int64(1), "success", int64(1), "dev", nil).
+		AddRow("20250110000000", "main", checksum.SHA256([]byte("CREATE TABLE t3(id INT);")), time.Now(), "tester", int64(1), "success", int64(2), "dev", nil))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(plan.Pending) != 2 {
+		t.Fatalf("pending = %+v", plan.Pending)
+	}
+	if len(plan.OutOfOrder) != 1 || plan.OutOfOrder[0].Name != "from_branch" {
+		t.Fatalf("out of order = %+v", plan.OutOfOrder)
+	}
+}
+
+func TestDiscoverAndPlan_FailedRetryAfter(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "flaky", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	chk := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+
+	for _, tc := range []struct {
+		name     string
+		failedAt time.Time
+		pending  int
+		cooling  int
+	}{
+		{"within window", time.Now().Add(-time.Minute), 0, 1},
+		{"after window", time.Now().Add(-time.Hour), 1, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer db.Close()
+			columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+			mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("20250101000000", "flaky", chk, tc.failedAt, "tester", int64(1), "failed", int64(1), "dev", nil))
+
+			st := &Storage{DB: db, Table: "schema_migrations"}
+			plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st, WithFailedRetryAfter(10*time.Minute))
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if len(plan.Pending) != tc.pending || len(plan.CoolingDown) != tc.cooling {
+				t.Fatalf("pending=%d cooling=%d", len(plan.Pending), len(plan.CoolingDown))
+			}
+		})
+	}
+}
+
+func TestDiscoverAndPlan_CustomChecksum(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);\n", "DROP TABLE t1;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	// legacy tool: CRC32 of the trimmed up file
+	legacy := func(up []byte) string {
+		return fmt.Sprintf("%08x", crc32.ChecksumIEEE(bytes.TrimSpace(up)))
+	}
+	stored := legacy([]byte("CREATE TABLE t1(id INT);"))
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", stored, time.Now(), "tester", int64(5), "success", int64(1), "dev", nil))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	plan, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir, Checksum: legacy}, st)
+	if err != nil {
+		t.Fatalf("plan with legacy checksum: %v", err)
+	}
+	if len(plan.Pending) != 0 || plan.All[0].Checksum != stored {
+		t.Fatalf("unexpected plan: pending=%d checksum=%s", len(plan.Pending), plan.All[0].Checksum)
+	}
+
+	// the default SHA-256 sees the legacy value as drift
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", stored, time.Now(), "tester", int64(5), "success", int64(1), "dev", nil))
+	if _, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir}, st); !errors.Is(err, ErrDrift) {
+		t.Fatalf("expected drift with default checksum, got %v", err)
+	}
+}
+
+func TestDiscoverAndPlan_NormalizedChecksum(t *testing.T) {
+	dir := t.TempDir()
+	// applied from an LF checkout, now checked out with CRLF
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(\r\n  id INT\r\n);\r\n", "DROP TABLE t1;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	stored := checksum.Normalized([]byte("CREATE TABLE t1(\n  id INT\n);\n"))
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", stored, time.Now(), "tester", int64(5), "success", int64(1), "dev", nil))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	if _, err := DiscoverAndPlan(context.Background(), FileSource{RootDir: dir, Checksum: checksum.Normalized}, st); err != nil {
+		t.Fatalf("CRLF checkout must not drift under normalized mode: %v", err)
+	}
+}
+
+func TestDiscoverAndPlan_ChecksumAlgoChanged(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	sha512, err := checksum.For("", "sha512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	for _, tc := range []struct {
+		name   string
+		stored string
+		want   error
+	}{
+		{"same algorithm", sha512([]byte("CREATE TABLE t1(id INT);")), nil},
+		{"edited file", sha512([]byte("CREATE TABLE t1(id BIGINT);")), ErrDrift},
+		{"recorded with sha256", checksum.SHA256([]byte("CREATE TABLE t1(id INT);")), ErrChecksumAlgo},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("20250101000000", "init", tc.stored, time.Now(), "tester", int64(5), "success", int64(1), "dev", nil))
+			st := &Storage{DB: db, Table: "schema_migrations"}
+			_, err = DiscoverAndPlan(context.Background(), FileSource{RootDir: dir, Checksum: sha512}, st)
+			if !errors.Is(err, tc.want) || (tc.want == ErrDrift && errors.Is(err, ErrChecksumAlgo)) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package migrator
+
+import (
+	"context"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/sqlsplit"
+)
+
+// CheckMultiStatements verifies that multi-statement execution actually works
+// before running files that depend on it. A proxy can silently strip
+// multiStatements=true, in which case only the first statement of each file
+// would run. It does nothing unless ExecWholeFile is set and some file sends
+// more than one statement in a single Exec (split files don't count).
+func (r *Runner) CheckMultiStatements(ctx context.Context, files []FilePair) error {
+	if !r.ExecWholeFile || !needsMultiStatements(files) {
+		return nil
+	}
+	return db.ProbeMultiStatements(ctx, r.DB)
+}
+
+func needsMultiStatements(files []FilePair) bool {
+	for _, fp := range files {
+		if fp.BatchCommit == 0 && len(fp.UpParams) == 0 && len(sqlsplit.Split(string(fp.UpBytes))) > 1 {
+			return true
+		}
+		if len(fp.DownParams) == 0 && len(sqlsplit.Split(string(fp.DownBytes))) > 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestCheckMultiStatements(t *testing.T) {
+	multi := []FilePair{{Version: "1", Name: "a", UpBytes: []byte("CREATE TABLE a(id INT); CREATE TABLE b(id INT);")}}
+
+	t.Run("honored", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		defer sqlDB.Close()
+		mock.ExpectQuery("SELECT 1; SELECT 2").WillReturnRows(
+			sqlmock.NewRows([]string{"1"}).AddRow(1),
+			sqlmock.NewRows([]string{"2"}).AddRow(2),
+		)
+		r := NewRunner(sqlDB, "schema_migrations", "t")
+		r.ExecWholeFile = true
+		if err := r.CheckMultiStatements(context.Background(), multi); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("stripped", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		defer sqlDB.Close()
+		mock.ExpectQuery("SELECT 1; SELECT 2").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+		r := NewRunner(sqlDB, "schema_migrations", "t")
+		r.ExecWholeFile = true
+		if err := r.CheckMultiStatements(context.Background(), multi); !errors.Is(err, db.ErrMultiStatementsIgnored) {
+			t.Fatalf("expected ErrMultiStatementsIgnored, got %v", err)
+		}
+	})
+
+	t.Run("not needed", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		defer sqlDB.Close()
+		single := []FilePair{
+			{Version: "1", Name: "a", UpBytes: []byte("CREATE TABLE a(id INT);"), DownBytes: []byte("DROP TABLE a;")},
+			{Version: "2", Name: "b", UpBytes: []byte("INSERT INTO a VALUES (1); INSERT INTO a VALUES (2);"), BatchCommit: 1},
+		}
+		r := NewRunner(sqlDB, "schema_migrations", "t")
+		r.ExecWholeFile = true
+		if err := r.CheckMultiStatements(context.Background(), single); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("probe must not run: %v", err)
+		}
+	})
+
+	t.Run("split by default", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		defer sqlDB.Close()
+		r := NewRunner(sqlDB, "schema_migrations", "t")
+		if err := r.CheckMultiStatements(context.Background(), multi); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("probe must not run: %v", err)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrPruneSuccess is returned when asked to prune successful rows, which the
+// planner needs to know what has been applied.
+var ErrPruneSuccess = errors.New("refusing to prune successful migrations")
+
+// Prune deletes tracking rows with one of statuses whose applied_at is
+// before cutoff, returning the rows it matched. Successful rows are never
+// pruned. In dry-run nothing is deleted.
+func Prune(ctx context.Context, st *Storage, before time.Time, statuses []string, dryRun bool) ([]Row, error) {
+	if err := checkPruneStatuses(statuses); err != nil {
+		return nil, err
+	}
+	all, err := st.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	want := map[string]bool{}
+	for _, s := range statuses {
+		want[s] = true
+	}
+	var matched []Row
+	for _, row := range all {
+		if want[row.Status] && row.AppliedAt.Before(before) {
+			matched = append(matched, row)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].AppliedAt.Before(matched[j].AppliedAt) })
+	if dryRun || len(matched) == 0 {
+		return matched, nil
+	}
+	if _, err := st.Prune(ctx, before, statuses); err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+// Prune deletes rows with one of statuses and applied_at before cutoff and
+// reports how many were removed. The status filter always excludes success.
+func (s *Storage) Prune(ctx context.Context, before time.Time, statuses []string) (int64, error) {
+	if err := checkPruneStatuses(statuses); err != nil {
+		return 0, err
+	}
+	args := make([]any, 0, len(statuses)+1)
+	args = append(args, before)
+	for _, st := range statuses {
+		args = append(args, st)
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	res, err := s.DB.ExecContext(ctx, s.q(fmt.Sprintf(`DELETE FROM %s WHERE applied_at < ? AND status IN (%s) AND status <> 'success'`, s.table(), in)), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func checkPruneStatuses(statuses []string) error {
+	if len(statuses) == 0 {
+		return errors.New("prune: no statuses given")
+	}
+	for _, s := range statuses {
+		if s == "success" {
+			return ErrPruneSuccess
+		}
+	}
+	return nil
+}
+
+// ParseBefore resolves a prune cutoff given either as a duration ago ("720h")
+// or as a date ("2025-01-31" or RFC 3339).
+func ParseBefore(v string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid cutoff %q: want a duration or a date", v)
+}
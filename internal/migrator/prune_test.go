@@ -0,0 +1,82 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPrune_DeletesOnlyMatchingRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	cutoff := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := cutoff.Add(-48 * time.Hour)
+	recent := cutoff.Add(time.Hour)
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("1", "kept_success", "c1", old, "tester", int64(5), "success", int64(1), "dev", nil).
+		AddRow("2", "old_failed", "c2", old, "tester", int64(5), "failed", int64(2), "dev", nil).
+		AddRow("3", "recent_failed", "c3", recent, "tester", int64(5), "failed", int64(3), "dev", nil).
+		AddRow("4", "old_reverted", "c4", old, "tester", int64(5), "reverted", int64(4), "dev", nil))
+	mock.ExpectExec("DELETE FROM `schema_migrations` WHERE applied_at < \\? AND status IN \\(\\?\\) AND status <> 'success'").
+		WithArgs(cutoff, "failed").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	rows, err := Prune(context.Background(), st, cutoff, []string{"failed"}, false)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Name != "old_failed" {
+		t.Fatalf("unexpected matches: %+v", rows)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestPrune_DryRunAndSuccessGuard(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	if _, err := Prune(context.Background(), st, time.Now(), []string{"failed", "success"}, false); !errors.Is(err, ErrPruneSuccess) {
+		t.Fatalf("expected ErrPruneSuccess, got %v", err)
+	}
+
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("2", "old_failed", "c2", time.Now().Add(-time.Hour), "tester", int64(5), "failed", int64(2), "dev", nil))
+	rows, err := Prune(context.Background(), st, time.Now(), []string{"failed", "reverted"}, true)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("dry-run: rows=%v err=%v", rows, err)
+	}
+	// dry-run must not issue a DELETE
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestParseBefore(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Time{
+		"24h":                  now.Add(-24 * time.Hour),
+		"2025-01-31":           time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+		"2025-01-31T10:00:00Z": time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC),
+	} {
+		got, err := ParseBefore(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseBefore(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseBefore("yesterday", now); err == nil {
+		t.Error("expected error for invalid cutoff")
+	}
+}
@@ -0,0 +1,103 @@
+package migrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// openMetricsContentType is the media type of the pushed payload.
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Pushgateway pushes a run's summary to a Prometheus Pushgateway, for
+// short-lived Jobs that can't be scraped. Database and Table become part of
+// the grouping key, so runs against different targets don't overwrite each
+// other.
+type Pushgateway struct {
+	URL      string // e.g. http://pushgateway:9091
+	Job      string
+	Database string
+	Table    string
+	Client   *http.Client // nil uses a client with a 10s timeout
+}
+
+// Push sends the summary collected by m for a run that applied rows and ended
+// with runErr, replacing metrics of the same names in its group. Callers should
+// treat failures as best-effort and only log them.
+func (p *Pushgateway) Push(ctx context.Context, m *MetricsFile, applied []Row, runErr error) error {
+	if p.Job == "" {
+		return fmt.Errorf("pushgateway: job name is required")
+	}
+	body := openMetrics(m.summary(applied, runErr))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint(), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", openMetricsContentType)
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushgateway: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// endpoint builds /metrics/job/<job>[/database/<db>][/table/<table>].
+// Values that are empty or contain '/' use the base64 form the Pushgateway
+// defines for grouping keys.
+func (p *Pushgateway) endpoint() string {
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(p.URL, "/"))
+	b.WriteString("/metrics")
+	for _, kv := range [][2]string{{"job", p.Job}, {"database", p.Database}, {"table", p.Table}} {
+		if kv[1] == "" && kv[0] != "job" {
+			continue
+		}
+		if strings.Contains(kv[1], "/") {
+			b.WriteString("/" + kv[0] + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(kv[1])))
+			continue
+		}
+		b.WriteString("/" + kv[0] + "/" + url.PathEscape(kv[1]))
+	}
+	return b.String()
+}
+
+// openMetrics renders sum as OpenMetrics text.
+func openMetrics(sum metricsSummary) string {
+	var b strings.Builder
+	gauge := func(name, help string, labels string, v float64) {
+		fmt.Fprintf(&b, "# TYPE %s gauge\n# HELP %s %s\n%s%s %g\n", name, name, help, name, labels, v)
+	}
+	success := 0.0
+	if sum.Success {
+		success = 1
+	}
+	gauge("migratex_migrations_applied", "Migrations applied by the last run.", "", float64(sum.Applied))
+	gauge("migratex_run_duration_seconds", "Duration of the last run.", "", float64(sum.TotalDurationMS)/1000)
+	gauge("migratex_lock_wait_seconds", "Time the last run waited for the migration lock.", "", float64(sum.LockWaitMS)/1000)
+	gauge("migratex_run_success", "Whether the last run succeeded (1) or failed (0).", "", success)
+	gauge("migratex_last_run_timestamp_seconds", "When the last run finished.", "", float64(sum.FinishedAt.Unix()))
+	if sum.CurrentVersion != "" {
+		gauge("migratex_current_version", "Highest applied migration version, as a label.", `{version="`+escapeLabel(sum.CurrentVersion)+`"}`, 1)
+	}
+	b.WriteString("# EOF\n")
+	return b.String()
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
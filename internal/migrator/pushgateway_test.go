@@ -0,0 +1,70 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPushgatewayPush(t *testing.T) {
+	var gotPath, gotMethod, gotType, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotMethod, gotType = r.URL.EscapedPath(), r.Method, r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	m := &MetricsFile{}
+	fp := FilePair{Version: "20250102000000", Name: "b"}
+	row := Row{Version: fp.Version, Name: fp.Name, DurationMS: 5}
+	m.Progress("start", fp, &row, nil)
+	m.Progress("success", fp, &row, nil)
+
+	p := &Pushgateway{URL: srv.URL + "/", Job: "migrate", Database: "app", Table: "ops/schema_migrations"}
+	if err := p.Push(context.Background(), m, []Row{row}, nil); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if gotMethod != http.MethodPost || !strings.HasPrefix(gotType, "application/openmetrics-text") {
+		t.Fatalf("unexpected request: %s %s", gotMethod, gotType)
+	}
+	if gotPath != "/metrics/job/migrate/database/app/table@base64/b3BzL3NjaGVtYV9taWdyYXRpb25z" {
+		t.Fatalf("unexpected path: %s", gotPath)
+	}
+	for _, want := range []string{
+		"# TYPE migratex_migrations_applied gauge\n",
+		"\nmigratex_migrations_applied 1\n",
+		"\nmigratex_run_success 1\n",
+		"\nmigratex_current_version{version=\"20250102000000\"} 1\n",
+	} {
+		if !strings.Contains(gotBody, want) {
+			t.Fatalf("payload missing %q:\n%s", want, gotBody)
+		}
+	}
+	if !strings.HasSuffix(gotBody, "# EOF\n") {
+		t.Fatalf("payload must end with # EOF:\n%s", gotBody)
+	}
+
+	if err := p.Push(context.Background(), &MetricsFile{}, nil, errors.New("boom")); err != nil {
+		t.Fatalf("push failure run: %v", err)
+	}
+	if !strings.Contains(gotBody, "\nmigratex_run_success 0\n") {
+		t.Fatalf("failed run not reported:\n%s", gotBody)
+	}
+}
+
+func TestPushgatewayPushError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	p := &Pushgateway{URL: srv.URL, Job: "migrate"}
+	if err := p.Push(context.Background(), &MetricsFile{}, nil, nil); err == nil || !strings.Contains(err.Error(), "bad payload") {
+		t.Fatalf("expected error with body, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package migrator
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Reconciliation states of a migration after a run.
+const (
+	ReconcileApplied        = "applied"         // applied by this run
+	ReconcileAlreadyApplied = "already-applied" // applied before this run
+	ReconcilePending        = "pending"         // still to apply; see Reason
+)
+
+// Reasons a migration is still pending after a run.
+const (
+	PendingSkipped     = "skipped"      // excluded via WithSkip
+	PendingFuture      = "future"       // excluded via WithIgnoreFuture
+	PendingCoolingDown = "cooling-down" // held back by WithFailedRetryAfter
+	PendingFailed      = "failed"       // this run tried it and it failed
+	PendingLimited     = "limited"      // pending but left out of the run, e.g. by a limit
+	PendingNotReached  = "not-reached"  // in the run, which stopped before it
+)
+
+// ReconcileEntry is one migration of the set in a Reconciliation.
+type ReconcileEntry struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Reason  string `json:"reason,omitempty"`
+	// PreviouslyFailed marks a pending migration whose tracking row
+	// records an earlier failure.
+	PreviouslyFailed bool   `json:"previously_failed,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// Reconciliation is the state of the whole migration set after a run and
+// what the run did, assembled by Reconcile.
+type Reconciliation struct {
+	Applied        int              `json:"applied"`
+	AlreadyApplied int              `json:"already_applied"`
+	Pending        int              `json:"pending"`
+	Migrations     []ReconcileEntry `json:"migrations"`
+	// Orphans are applied rows with no migration file, as version:name.
+	Orphans       []string `json:"orphans,omitempty"`
+	DriftRepaired []string `json:"drift_repaired,omitempty"`
+	DriftIgnored  []string `json:"drift_ignored,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// Reconcile classifies every migration in plan after a run: attempted are
+// the files passed to ApplyUp (plan.Pending, or fewer under a limit),
+// applied and runErr are what it returned. Only the file named by a
+// MigrationError in runErr is marked failed; after any other error, such as
+// a lost lock, the rest are not-reached. Pass nil attempted and applied
+// when nothing was run.
+func Reconcile(plan *Plan, attempted []FilePair, applied []Row, runErr error) Reconciliation {
+	reasons := map[string]string{}
+	for _, set := range []struct {
+		files  []FilePair
+		reason string
+	}{{plan.Pending, PendingLimited}, {plan.Skipped, PendingSkipped}, {plan.Future, PendingFuture}, {plan.CoolingDown, PendingCoolingDown}} {
+		for _, fp := range set.files {
+			reasons[Key(fp.Version, fp.Name)] = set.reason
+		}
+	}
+	for _, fp := range attempted {
+		reasons[Key(fp.Version, fp.Name)] = PendingNotReached
+	}
+	failedKey := ""
+	var me *MigrationError
+	if errors.As(runErr, &me) {
+		failedKey = Key(me.File.Version, me.File.Name)
+	}
+	appliedNow := make(map[string]bool, len(applied))
+	for _, row := range applied {
+		appliedNow[Key(row.Version, row.Name)] = true
+	}
+
+	rec := Reconciliation{DriftRepaired: plan.DriftRepaired, DriftIgnored: plan.DriftIgnored}
+	if runErr != nil {
+		rec.Error = runErr.Error()
+	}
+	for _, fp := range plan.All {
+		k := Key(fp.Version, fp.Name)
+		e := ReconcileEntry{Version: fp.Version, Name: fp.Name}
+		row, recorded := plan.Applied[k]
+		switch {
+		case appliedNow[k]:
+			e.State = ReconcileApplied
+			rec.Applied++
+		case recorded && row.Status == "success":
+			e.State = ReconcileAlreadyApplied
+			rec.AlreadyApplied++
+		default:
+			e.State = ReconcilePending
+			e.Reason = reasons[k]
+			e.PreviouslyFailed = recorded && row.Status == "failed"
+			if k == failedKey {
+				e.Reason = PendingFailed
+				e.Error = rec.Error
+			}
+			rec.Pending++
+		}
+		rec.Migrations = append(rec.Migrations, e)
+	}
+	for _, row := range plan.Orphans() {
+		rec.Orphans = append(rec.Orphans, Key(row.Version, row.Name))
+	}
+	return rec
+}
+
+// WriteReconciliation writes rec as indented JSON, for archiving as a CI
+// artifact.
+func WriteReconciliation(w io.Writer, rec Reconciliation) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rec)
+}
@@ -0,0 +1,128 @@
+package migrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReconcile(t *testing.T) {
+	fp := func(v, n string) FilePair { return FilePair{Version: v, Name: n} }
+	all := []FilePair{
+		fp("1", "done"), fp("2", "now"), fp("3", "broken"), fp("4", "after"),
+		fp("5", "limit"), fp("6", "skip"), fp("7", "future"), fp("8", "cool"),
+	}
+	plan := &Plan{
+		All: all,
+		Applied: map[string]Row{
+			"1:done":   {Version: "1", Name: "done", Status: "success"},
+			"3:broken": {Version: "3", Name: "broken", Status: "failed"},
+			"8:cool":   {Version: "8", Name: "cool", Status: "failed"},
+			"0:gone":   {Version: "0", Name: "gone", Status: "success"},
+		},
+		Pending:      []FilePair{all[1], all[2], all[3], all[4]},
+		Skipped:      []FilePair{all[5]},
+		Future:       []FilePair{all[6]},
+		CoolingDown:  []FilePair{all[7]},
+		DriftIgnored: []string{"1:done"},
+	}
+	attempted := plan.Pending[:3] // a limit of 3 leaves 5 out
+	applied := []Row{{Version: "2", Name: "now", Status: "success"}}
+	runErr := &MigrationError{File: all[2], Err: errors.New("syntax error")}
+
+	rec := Reconcile(plan, attempted, applied, runErr)
+	want := map[string]string{
+		"1:done":   "already-applied",
+		"2:now":    "applied",
+		"3:broken": "pending/failed",
+		"4:after":  "pending/not-reached",
+		"5:limit":  "pending/limited",
+		"6:skip":   "pending/skipped",
+		"7:future": "pending/future",
+		"8:cool":   "pending/cooling-down",
+	}
+	for _, e := range rec.Migrations {
+		got := e.State
+		if e.Reason != "" {
+			got += "/" + e.Reason
+		}
+		if k := Key(e.Version, e.Name); got != want[k] {
+			t.Errorf("%s: got %s, want %s", k, got, want[k])
+		}
+	}
+	if len(rec.Migrations) != len(all) || rec.Applied != 1 || rec.AlreadyApplied != 1 || rec.Pending != 6 {
+		t.Fatalf("counts: %+v", rec)
+	}
+	if e := rec.Migrations[2]; !e.PreviouslyFailed || !strings.Contains(e.Error, "syntax error") {
+		t.Fatalf("failed entry: %+v", e)
+	}
+	if len(rec.Orphans) != 1 || rec.Orphans[0] != "0:gone" || len(rec.DriftIgnored) != 1 {
+		t.Fatalf("orphans/drift: %+v", rec)
+	}
+
+	var b strings.Builder
+	if err := WriteReconciliation(&b, rec); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(b.String()), &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded["pending"] != float64(6) || decoded["error"] == nil {
+		t.Fatalf("json = %s", b.String())
+	}
+}
+
+func TestReconcile_LockLost(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "1", "first", "CREATE TABLE a(id INT);", "DROP TABLE a;")
+	writePair(t, dir, "2", "second", "CREATE TABLE b(id INT);", "DROP TABLE b;")
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	sqldb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqldb.Close()
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\)").WillReturnRows(sqlmock.NewRows([]string{"m"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order").WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	r := NewRunner(sqldb, "schema_migrations", "tester")
+	lost := errors.New("lost advisory lock")
+	checks := 0
+	r.LockCheck = func(context.Context) error {
+		if checks++; checks > 1 {
+			return lost
+		}
+		return nil
+	}
+	applied, runErr := r.ApplyUp(context.Background(), d.Files, false, nil)
+	if !errors.Is(runErr, lost) || len(applied) != 1 {
+		t.Fatalf("apply: %+v, %v", applied, runErr)
+	}
+
+	plan := &Plan{All: d.Files, Applied: map[string]Row{}, Pending: d.Files}
+	rec := Reconcile(plan, d.Files, applied, runErr)
+	if e := rec.Migrations[1]; e.Reason != PendingNotReached || e.Error != "" {
+		t.Fatalf("a lost lock must leave the next migration not-reached, got %+v", e)
+	}
+	if rec.Applied != 1 || rec.Error != lost.Error() {
+		t.Fatalf("reconciliation: %+v", rec)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
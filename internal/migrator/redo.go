@@ -0,0 +1,36 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+)
+
+// Redo rolls back the last n applied migrations and re-applies them from
+// their current files, oldest first, so an edited migration can be re-run
+// during development. lookup must hold the current files; build it from
+// Discover and Load rather than DiscoverAndPlan, which would report the edit
+// as drift. Callers should hold the advisory lock. In dry-run nothing runs
+// and progress reports the rollback and re-apply steps. It returns the rows
+// re-applied.
+func (r *Runner) Redo(ctx context.Context, lookup map[string]FilePair, n int, dryRun bool, progress func(stage string, fp FilePair, row *Row, err error)) ([]Row, error) {
+	if n < 1 {
+		n = 1
+	}
+	last, err := r.LastApplied(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]FilePair, len(last))
+	for i, row := range last {
+		fp, ok := lookup[Key(row.Version, row.Name)]
+		if !ok {
+			return nil, fmt.Errorf("missing migration files for %s:%s", row.Version, row.Name)
+		}
+		// last is newest first; re-apply oldest first
+		files[len(last)-1-i] = fp
+	}
+	if err := r.ApplyDown(ctx, last, lookup, dryRun, progress); err != nil {
+		return nil, err
+	}
+	return r.ApplyUp(ctx, files, dryRun, progress)
+}
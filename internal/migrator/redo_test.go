@@ -0,0 +1,83 @@
+package migrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRedo_RevertsAndReappliesOldestFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	lookup := map[string]FilePair{
+		"1:a": {Version: "1", Name: "a", UpBytes: []byte("CREATE TABLE a(id INT);"), DownBytes: []byte("DROP TABLE a;"), Checksum: "new-a"},
+		"2:b": {Version: "2", Name: "b", UpBytes: []byte("CREATE TABLE b(id INT);"), DownBytes: []byte("DROP TABLE b;"), Checksum: "new-b"},
+	}
+
+	mock.ExpectQuery("SELECT version, name, checksum.*ORDER BY execution_order DESC LIMIT").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("2", "b", "old-b", time.Now(), "tester", int64(1), "success", int64(2), "dev", nil).
+			AddRow("1", "a", "old-a", time.Now(), "tester", int64(1), "success", int64(1), "dev", nil))
+	for _, table := range []string{"b", "a"} {
+		mock.ExpectBegin()
+		mock.ExpectExec("DROP TABLE " + table).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectExec("DELETE FROM `schema_migrations`").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	for i, table := range []string{"a", "b"} {
+		mock.ExpectBegin()
+		mock.ExpectExec("CREATE TABLE " + table).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectExec("INSERT INTO `schema_migrations`").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+			WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(i + 1)))
+	}
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	applied, err := r.Redo(context.Background(), lookup, 2, false, nil)
+	if err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	if len(applied) != 2 || applied[0].Name != "a" || applied[1].Checksum != "new-b" {
+		t.Fatalf("unexpected re-applied rows: %+v", applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestRedo_DryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	lookup := map[string]FilePair{"1:a": {Version: "1", Name: "a", UpBytes: []byte("CREATE TABLE a(id INT);"), DownBytes: []byte("DROP TABLE a;")}}
+	mock.ExpectQuery("SELECT version, name, checksum.*ORDER BY execution_order DESC LIMIT").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("1", "a", "x", time.Now(), "tester", int64(1), "success", int64(1), "dev", nil))
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(1)))
+
+	var stages []string
+	r := NewRunner(db, "schema_migrations", "tester")
+	if _, err := r.Redo(context.Background(), lookup, 1, true, func(stage string, fp FilePair, _ *Row, _ error) {
+		stages = append(stages, stage)
+	}); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	if len(stages) != 4 {
+		t.Fatalf("expected start/success for down and up, got %v", stages)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
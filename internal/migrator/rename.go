@@ -0,0 +1,108 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	versionRe = regexp.MustCompile(`^\d+$`)
+	nameRe    = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+)
+
+// Renamed describes a migration moved by Rename.
+type Renamed struct {
+	OldKey   string
+	NewKey   string
+	Paths    [][2]string // old and new path of each renamed file
+	RowMoved bool        // a tracking row existed and was re-keyed
+}
+
+// Rename changes a migration's version or name: to is taken as a new version
+// when it is all digits, otherwise as a new name. Both files are renamed on
+// disk and the tracking row, if any, is re-keyed with a recomputed checksum,
+// so the migration stays applied. If updating the row fails the files are
+// renamed back. The target is resolved like other targets; the new key must
+// not collide with another file or tracking row. Only local directories are
+// supported. Callers should hold the advisory lock.
+func Rename(ctx context.Context, src FileSource, st *Storage, from, to string) (Renamed, error) {
+	if src.FS != nil || len(src.Layers) > 0 {
+		return Renamed{}, errors.New("rename only supports a single local migrations directory")
+	}
+	d, err := Discover(FileSource{RootDir: src.RootDir, UpRootDir: src.UpRootDir, DownRootDir: src.DownRootDir, Ext: src.Ext, Dialect: src.Dialect, Recursive: src.Recursive, Checksum: src.Checksum})
+	if err != nil {
+		return Renamed{}, err
+	}
+	if err := d.Load(); err != nil {
+		return Renamed{}, err
+	}
+	fp, err := ResolveTarget(d.Files, from)
+	if err != nil {
+		return Renamed{}, err
+	}
+	version, name := fp.Version, fp.Name
+	switch {
+	case versionRe.MatchString(to):
+		version = to
+	case nameRe.MatchString(to):
+		name = to
+	default:
+		return Renamed{}, fmt.Errorf("invalid new version or name %q", to)
+	}
+	res := Renamed{OldKey: Key(fp.Version, fp.Name), NewKey: Key(version, name)}
+	if res.NewKey == res.OldKey {
+		return res, fmt.Errorf("%s already has that version and name", res.OldKey)
+	}
+	for _, other := range d.Files {
+		if other.Version == version && Key(other.Version, other.Name) != res.OldKey {
+			return res, fmt.Errorf("version %s is already used by %s", version, other.UpPath)
+		}
+	}
+	rows, err := st.GetAll(ctx)
+	if err != nil {
+		return res, err
+	}
+	if _, taken := rows[res.NewKey]; taken {
+		return res, fmt.Errorf("tracking table already has a row for %s", res.NewKey)
+	}
+	_, res.RowMoved = rows[res.OldKey]
+
+	oldBase, newBase := fp.Version+"_"+fp.Name, version+"_"+name
+	for _, p := range []string{fp.UpPath, fp.DownPath} {
+		np := filepath.Join(filepath.Dir(p), newBase+strings.TrimPrefix(filepath.Base(p), oldBase))
+		if _, err := os.Lstat(np); err == nil {
+			return res, fmt.Errorf("%s already exists", np)
+		}
+		res.Paths = append(res.Paths, [2]string{p, np})
+	}
+	undo := func(n int) {
+		for i := n - 1; i >= 0; i-- {
+			_ = os.Rename(res.Paths[i][1], res.Paths[i][0])
+		}
+	}
+	for i, p := range res.Paths {
+		if err := os.Rename(p[0], p[1]); err != nil {
+			undo(i)
+			return res, err
+		}
+	}
+	if res.RowMoved {
+		if err := st.Rekey(ctx, fp.Version, fp.Name, version, name, fp.Checksum); err != nil {
+			undo(len(res.Paths))
+			return res, fmt.Errorf("update tracking row: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// Rekey moves a tracking row to a new version and name and sets its checksum.
+func (s *Storage) Rekey(ctx context.Context, version, name, newVersion, newName, checksum string) error {
+	_, err := s.DB.ExecContext(ctx, s.q(fmt.Sprintf(`UPDATE %s SET version=?, name=?, checksum=? WHERE version=? AND name=?`, s.table())),
+		newVersion, newName, checksum, version, name)
+	return err
+}
@@ -0,0 +1,82 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+)
+
+func TestRename_AppliedMigration(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t(id INT);", "DROP TABLE t;")
+	writePair(t, dir, "20250102000000", "users", "CREATE TABLE u(id INT);", "DROP TABLE u;")
+	chk := checksum.SHA256([]byte("CREATE TABLE u(id INT);"))
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250102000000", "users", chk, time.Now(), "tester", int64(1), "success", int64(2), "dev", nil))
+	mock.ExpectExec("UPDATE `schema_migrations` SET version=\\?, name=\\?, checksum=\\? WHERE version=\\? AND name=\\?").
+		WithArgs("20250102000000", "create_users", chk, "20250102000000", "users").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	res, err := Rename(context.Background(), FileSource{RootDir: dir}, st, "20250102000000", "create_users")
+	if err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if !res.RowMoved || res.NewKey != "20250102000000:create_users" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	for _, name := range []string{"20250102000000_create_users.up.sql", "20250102000000_create_users.down.sql"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("renamed file missing: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20250102000000_users.up.sql")); !os.IsNotExist(err) {
+		t.Fatalf("old file still present: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestRename_RejectsCollisionAndRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t(id INT);", "DROP TABLE t;")
+	writePair(t, dir, "20250102000000", "users", "CREATE TABLE u(id INT);", "DROP TABLE u;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	if _, err := Rename(context.Background(), FileSource{RootDir: dir}, st, "users", "20250101000000"); err == nil {
+		t.Fatal("expected version collision error")
+	}
+
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250102000000", "users", "x", time.Now(), "tester", int64(1), "success", int64(2), "dev", nil))
+	mock.ExpectExec("UPDATE `schema_migrations`").WillReturnError(errors.New("connection lost"))
+	if _, err := Rename(context.Background(), FileSource{RootDir: dir}, st, "users", "20250103000000"); err == nil {
+		t.Fatal("expected update error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20250102000000_users.up.sql")); err != nil {
+		t.Fatalf("files must be renamed back: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
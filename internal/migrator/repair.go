@@ -0,0 +1,133 @@
+package migrator
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RepairChange describes one stored checksum rewritten by RepairChecksums.
+type RepairChange struct {
+	Version     string `json:"version"`
+	Name        string `json:"name"`
+	OldChecksum string `json:"old_checksum"`
+	NewChecksum string `json:"new_checksum"`
+	// Down marks a down-file checksum change; OldChecksum is empty when the
+	// row predates down checksums and is being backfilled.
+	Down bool `json:"down,omitempty"`
+}
+
+// ErrRepairNotConfirmed is returned by RepairChecksums when its confirm
+// function declines the changes.
+var ErrRepairNotConfirmed = errors.New("checksum repair not confirmed")
+
+// RepairChecksums updates stored checksums of applied migrations to match the
+// files on disk and returns every change, so callers can log exactly what was
+// rewritten. Down-file checksums are repaired the same way, which also
+// backfills rows written before they were recorded. In dry-run nothing is
+// written.
+//
+// Rewriting checksums hides drift for good, so before writing anything the
+// changes are passed to confirm, if set; returning false writes nothing and
+// fails with ErrRepairNotConfirmed (pass nil for --yes). progress, if set,
+// gets one event per repaired row: "success" (also in dry-run) or "error",
+// or "denied" for each row when confirmation is declined. row holds the
+// stored (old) checksums and fp the file's (new) ones.
+func RepairChecksums(ctx context.Context, src FileSource, st *Storage, dryRun bool, confirm func([]RepairChange) (bool, error), progress func(stage string, fp FilePair, row *Row, err error)) ([]RepairChange, error) {
+	plan, err := DiscoverAndPlan(ctx, src, st, WithDriftPolicy(DriftPolicyFunc(func(string, string, string) (DriftAction, error) {
+		return DriftIgnore, nil
+	})))
+	if err != nil {
+		return nil, err
+	}
+	type repair struct {
+		fp      FilePair
+		row     Row
+		changes []RepairChange
+	}
+	var repairs []repair
+	var all []RepairChange
+	for _, fp := range plan.All {
+		row, ok := plan.Applied[Key(fp.Version, fp.Name)]
+		if !ok || row.Status != "success" {
+			continue
+		}
+		rp := repair{fp: fp, row: row}
+		if !strings.EqualFold(row.Checksum, fp.Checksum) {
+			rp.changes = append(rp.changes, RepairChange{Version: fp.Version, Name: fp.Name, OldChecksum: row.Checksum, NewChecksum: fp.Checksum})
+		}
+		if !strings.EqualFold(row.DownChecksum, fp.DownChecksum) {
+			rp.changes = append(rp.changes, RepairChange{Version: fp.Version, Name: fp.Name, OldChecksum: row.DownChecksum, NewChecksum: fp.DownChecksum, Down: true})
+		}
+		if len(rp.changes) > 0 {
+			repairs = append(repairs, rp)
+			all = append(all, rp.changes...)
+		}
+	}
+	if !dryRun && confirm != nil && len(all) > 0 {
+		ok, err := confirm(all)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if progress != nil {
+				for _, rp := range repairs {
+					progress("denied", rp.fp, &rp.row, ErrRepairNotConfirmed)
+				}
+			}
+			return nil, ErrRepairNotConfirmed
+		}
+	}
+	var changes []RepairChange
+	for _, rp := range repairs {
+		for _, c := range rp.changes {
+			if !dryRun {
+				var err error
+				if c.Down {
+					err = st.UpdateDownChecksum(ctx, c.Version, c.Name, c.NewChecksum)
+				} else {
+					err = st.UpdateChecksum(ctx, c.Version, c.Name, c.NewChecksum)
+				}
+				if err != nil {
+					if progress != nil {
+						progress("error", rp.fp, &rp.row, err)
+					}
+					return changes, err
+				}
+			}
+			changes = append(changes, c)
+		}
+		if progress != nil {
+			progress("success", rp.fp, &rp.row, nil)
+		}
+	}
+	return changes, nil
+}
+
+// PromptRepair returns a confirm function for RepairChecksums that lists
+// the changes on out and asks for "y" or "yes" on in, for interactive use
+// without --yes. Anything else, including end of input, declines.
+func PromptRepair(in io.Reader, out io.Writer) func([]RepairChange) (bool, error) {
+	return func(changes []RepairChange) (bool, error) {
+		for _, c := range changes {
+			file := "up"
+			if c.Down {
+				file = "down"
+			}
+			fmt.Fprintf(out, "  %s %s checksum: %s -> %s\n", Key(c.Version, c.Name), file, c.OldChecksum, c.NewChecksum)
+		}
+		fmt.Fprintf(out, "Rewrite %d stored checksum(s)? [y/N] ", len(changes))
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true, nil
+		}
+		return false, nil
+	}
+}
@@ -0,0 +1,130 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+)
+
+func TestRepairChecksums_DryRunReportsChanges(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+	writePair(t, dir, "20250102000000", "edited", "CREATE TABLE t2(id BIGINT);", "DROP TABLE t2;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	chk1 := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", chk1, time.Now(), "tester", int64(5), "success", int64(1), "dev", checksum.SHA256([]byte("DROP TABLE t1;"))).
+		AddRow("20250102000000", "edited", "oldsum", time.Now(), "tester", int64(5), "success", int64(2), "dev", checksum.SHA256([]byte("DROP TABLE t2;"))))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	changes, err := RepairChecksums(context.Background(), FileSource{RootDir: dir}, st, true, nil, nil)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d", len(changes))
+	}
+	c := changes[0]
+	if c.Version != "20250102000000" || c.Name != "edited" || c.OldChecksum != "oldsum" ||
+		c.NewChecksum != checksum.SHA256([]byte("CREATE TABLE t2(id BIGINT);")) {
+		t.Fatalf("unexpected change: %+v", c)
+	}
+	// dry-run must not issue any UPDATE
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestRepairChecksums_BackfillsDownChecksums(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t1(id INT);", "DROP TABLE t1;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	chk := checksum.SHA256([]byte("CREATE TABLE t1(id INT);"))
+	down := checksum.SHA256([]byte("DROP TABLE t1;"))
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("20250101000000", "init", chk, time.Now(), "tester", int64(5), "success", int64(1), "dev", nil))
+	mock.ExpectExec("UPDATE `schema_migrations` SET down_checksum=\\? WHERE version=\\? AND name=\\?").
+		WithArgs(down, "20250101000000", "init").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	changes, err := RepairChecksums(context.Background(), FileSource{RootDir: dir}, st, false, nil, nil)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if len(changes) != 1 || !changes[0].Down || changes[0].OldChecksum != "" || changes[0].NewChecksum != down {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestRepairChecksums_ConfirmAndProgress(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250102000000", "edited", "CREATE TABLE t2(id BIGINT);", "DROP TABLE t2;")
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	newSum := checksum.SHA256([]byte("CREATE TABLE t2(id BIGINT);"))
+	down := checksum.SHA256([]byte("DROP TABLE t2;"))
+
+	for _, answer := range []string{"n\n", "yes\n"} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock: %v", err)
+		}
+		mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("20250102000000", "edited", "oldsum", time.Now(), "tester", int64(5), "success", int64(2), "dev", down))
+		confirmed := answer == "yes\n"
+		if confirmed {
+			mock.ExpectExec("UPDATE `schema_migrations` SET checksum=\\?").
+				WithArgs(newSum, "20250102000000", "edited").WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+
+		var prompt strings.Builder
+		var events []string
+		st := &Storage{DB: db, Table: "schema_migrations"}
+		changes, err := RepairChecksums(context.Background(), FileSource{RootDir: dir}, st, false,
+			PromptRepair(strings.NewReader(answer), &prompt),
+			func(stage string, fp FilePair, row *Row, err error) {
+				events = append(events, stage+":"+row.Checksum+"->"+fp.Checksum)
+			})
+		if !strings.Contains(prompt.String(), "20250102000000:edited up checksum: oldsum -> "+newSum) {
+			t.Fatalf("prompt does not list the change:\n%s", prompt.String())
+		}
+		if confirmed {
+			if err != nil || len(changes) != 1 {
+				t.Fatalf("confirmed repair: %v, %+v", err, changes)
+			}
+			if len(events) != 1 || events[0] != "success:oldsum->"+newSum {
+				t.Fatalf("events = %v", events)
+			}
+		} else {
+			if !errors.Is(err, ErrRepairNotConfirmed) || len(changes) != 0 {
+				t.Fatalf("declined repair must write nothing: %v, %+v", err, changes)
+			}
+			if len(events) != 1 || !strings.HasPrefix(events[0], "denied:") {
+				t.Fatalf("events = %v", events)
+			}
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("expectations: %v", err)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,118 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultReplicaLagQuery is used when ReplicaLagWait.Query is empty.
+const DefaultReplicaLagQuery = "SHOW SLAVE STATUS"
+
+var ErrReplicaLagTimeout = errors.New("timed out waiting for replica lag")
+
+// defaultReplicaWaitTimeout bounds ReplicaLagWait when Timeout is unset, so
+// a replica that never catches up can't hold a run forever; tests shorten it.
+var defaultReplicaWaitTimeout = 10 * time.Minute
+
+// ReplicaLagWait holds ApplyUp between migrations until every replica is
+// within MaxLag, polling every Interval (1s when unset) and giving up after
+// Timeout (10 minutes when unset).
+type ReplicaLagWait struct {
+	Replicas []*sql.DB
+	// Query returns the lag in seconds, either as a Seconds_Behind_Master /
+	// Seconds_Behind_Source column or as the first column of a custom query.
+	Query    string
+	MaxLag   time.Duration
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// Wait blocks until all replicas are within MaxLag.
+func (w *ReplicaLagWait) Wait(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = defaultReplicaWaitTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for _, replica := range w.Replicas {
+		for {
+			lag, err := ReplicaLag(ctx, replica, w.Query)
+			if err != nil {
+				return err
+			}
+			if lag <= w.MaxLag {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%w: lag %s exceeds %s", ErrReplicaLagTimeout, lag, w.MaxLag)
+			}
+			if err := sleepCtx(ctx, interval); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the replica pools.
+func (w *ReplicaLagWait) Close() error {
+	var errs []error
+	for _, replica := range w.Replicas {
+		errs = append(errs, replica.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ReplicaLag runs query (DefaultReplicaLagQuery when empty) against a replica
+// and returns its reported lag. A NULL lag means replication is not running.
+func ReplicaLag(ctx context.Context, replica *sql.DB, query string) (time.Duration, error) {
+	if query == "" {
+		query = DefaultReplicaLagQuery
+	}
+	rows, err := replica.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	idx := 0
+	for i, c := range cols {
+		if c == "Seconds_Behind_Master" || c == "Seconds_Behind_Source" {
+			idx = i
+			break
+		}
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("replica lag query returned no rows (not a replica?)")
+	}
+	vals := make([]sql.RawBytes, len(cols))
+	dest := make([]any, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	if vals[idx] == nil {
+		return 0, errors.New("replica lag is NULL (replication not running)")
+	}
+	secs, err := strconv.ParseFloat(string(vals[idx]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse replica lag %q: %w", vals[idx], err)
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
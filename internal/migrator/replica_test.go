@@ -0,0 +1,76 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicaLagWait_WaitsUntilCaughtUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	var polls int
+	orig := sleepCtx
+	sleepCtx = func(ctx context.Context, d time.Duration) error { polls++; return nil }
+	defer func() { sleepCtx = orig }()
+
+	cols := []string{"Slave_IO_Running", "Seconds_Behind_Master"}
+	mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows(cols).AddRow("Yes", "12"))
+	mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows(cols).AddRow("Yes", "1"))
+
+	w := &ReplicaLagWait{Replicas: []*sql.DB{db}, MaxLag: 5 * time.Second, Timeout: time.Minute}
+	if err := w.Wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if polls != 1 {
+		t.Fatalf("expected one poll interval, got %d", polls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestReplicaLagWait_TimesOut(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+	for i := 0; i < 100; i++ {
+		mock.ExpectQuery("SELECT lag").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow("60"))
+	}
+
+	w := &ReplicaLagWait{Replicas: []*sql.DB{db}, Query: "SELECT lag FROM heartbeat", MaxLag: time.Second, Interval: time.Millisecond, Timeout: 5 * time.Millisecond}
+	if err := w.Wait(context.Background()); !errors.Is(err, ErrReplicaLagTimeout) {
+		t.Fatalf("expected ErrReplicaLagTimeout, got %v", err)
+	}
+}
+
+func TestReplicaLagWait_DefaultTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+	for i := 0; i < 100; i++ {
+		mock.ExpectQuery("SELECT lag").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow("60"))
+	}
+	prev := defaultReplicaWaitTimeout
+	defaultReplicaWaitTimeout = 5 * time.Millisecond
+	defer func() { defaultReplicaWaitTimeout = prev }()
+
+	w := &ReplicaLagWait{Replicas: []*sql.DB{db}, Query: "SELECT lag FROM heartbeat", MaxLag: time.Second, Interval: time.Millisecond}
+	if err := w.Wait(context.Background()); !errors.Is(err, ErrReplicaLagTimeout) {
+		t.Fatalf("expected ErrReplicaLagTimeout without a Timeout, got %v", err)
+	}
+}
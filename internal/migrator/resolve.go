@@ -0,0 +1,53 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrTargetNotFound  = errors.New("migration target not found")
+	ErrAmbiguousTarget = errors.New("migration target is ambiguous")
+)
+
+// ResolveTarget finds the migration in all that q refers to, for commands
+// such as goto, down --to and force. q may be a full version, a
+// version_name file stem, a migration name, or a unique version prefix.
+// Exact matches win over prefix matches.
+func ResolveTarget(all []FilePair, q string) (FilePair, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return FilePair{}, fmt.Errorf("%w: empty target", ErrTargetNotFound)
+	}
+	exact := func(match func(fp FilePair) bool) []FilePair {
+		var out []FilePair
+		for _, fp := range all {
+			if match(fp) {
+				out = append(out, fp)
+			}
+		}
+		return out
+	}
+	candidates := [][]FilePair{
+		exact(func(fp FilePair) bool { return fp.Version == q }),
+		exact(func(fp FilePair) bool { return fp.Version+"_"+fp.Name == q }),
+		exact(func(fp FilePair) bool { return fp.Name == q }),
+		exact(func(fp FilePair) bool { return strings.HasPrefix(fp.Version, q) }),
+	}
+	for _, c := range candidates {
+		switch len(c) {
+		case 0:
+			continue
+		case 1:
+			return c[0], nil
+		default:
+			keys := make([]string, len(c))
+			for i, fp := range c {
+				keys[i] = Key(fp.Version, fp.Name)
+			}
+			return FilePair{}, fmt.Errorf("%w: %q matches %s", ErrAmbiguousTarget, q, strings.Join(keys, ", "))
+		}
+	}
+	return FilePair{}, fmt.Errorf("%w: %q", ErrTargetNotFound, q)
+}
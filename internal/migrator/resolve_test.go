@@ -0,0 +1,43 @@
+package migrator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveTarget(t *testing.T) {
+	all := []FilePair{
+		{Version: "20250824120000", Name: "init"},
+		{Version: "20250825010101", Name: "add_users"},
+		{Version: "20250826090000", Name: "add_orders"},
+		{Version: "20250826100000", Name: "add_index"},
+	}
+	cases := []struct {
+		q       string
+		want    string
+		wantErr error
+	}{
+		{q: "20250825", want: "add_users"},
+		{q: "20250825010101", want: "add_users"},
+		{q: "add_orders", want: "add_orders"},
+		{q: "20250826100000_add_index", want: "add_index"},
+		{q: "20250826", wantErr: ErrAmbiguousTarget},
+		{q: "2024", wantErr: ErrTargetNotFound},
+		{q: "nope", wantErr: ErrTargetNotFound},
+	}
+	for _, tc := range cases {
+		fp, err := ResolveTarget(all, tc.q)
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("%q: expected %v, got %v", tc.q, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tc.q, err)
+		}
+		if fp.Name != tc.want {
+			t.Fatalf("%q: resolved %s, want %s", tc.q, fp.Name, tc.want)
+		}
+	}
+}
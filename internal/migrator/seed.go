@@ -0,0 +1,225 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+// Seed is an idempotent reference-data file, kept apart from migrations:
+// it has no version, may be run again at any time and is never reverted.
+type Seed struct {
+	Name     string // file name, e.g. 010_countries.sql
+	Body     []byte
+	Checksum string
+}
+
+// LoadSeeds reads the *.sql files directly in dir in file name order, so
+// prefix names with numbers to order them. Checksums are SHA-256, the
+// FileSource default; use LoadSeedsWith to match a FileSource.Checksum.
+func LoadSeeds(dir string) ([]Seed, error) {
+	return LoadSeedsWith(dir, nil)
+}
+
+// LoadSeedsWith is LoadSeeds with checksums computed by sum, as
+// FileSource.Checksum does for migrations; nil means checksum.SHA256.
+func LoadSeedsWith(dir string, sum ChecksumFunc) ([]Seed, error) {
+	if sum == nil {
+		sum = checksum.SHA256
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []Seed
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Seed{Name: e.Name(), Body: b, Checksum: sum(b)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// SeedRow is a seeds table row: the last successful run of a seed.
+type SeedRow struct {
+	Name       string
+	Checksum   string
+	AppliedAt  time.Time
+	AppliedBy  string
+	DurationMS int64
+}
+
+// SeedStorage reads and writes the seeds table (schema_seeds by default),
+// which records seed runs apart from the migration tracking table.
+type SeedStorage struct {
+	DB    Execer
+	Table string
+	// Driver supplies dialect-specific SQL; nil means MySQL.
+	Driver db.Driver
+}
+
+func (s *SeedStorage) driver() db.Driver {
+	if s.Driver == nil {
+		return db.MySQL
+	}
+	return s.Driver
+}
+
+func (s *SeedStorage) table() string {
+	return db.QuoteTable(s.driver(), s.Table)
+}
+
+// Ensure creates the seeds table if it doesn't exist. The DDL is the same
+// on every driver.
+func (s *SeedStorage) Ensure(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  name VARCHAR(255) NOT NULL PRIMARY KEY,
+  checksum VARCHAR(255) NOT NULL,
+  applied_at TIMESTAMP NOT NULL,
+  applied_by VARCHAR(255) NOT NULL,
+  duration_ms BIGINT NOT NULL
+)`, s.table()))
+	return err
+}
+
+// GetAll returns the seeds table rows keyed by name.
+func (s *SeedStorage) GetAll(ctx context.Context) (map[string]SeedRow, error) {
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`SELECT name, checksum, applied_at, applied_by, duration_ms FROM %s`, s.table()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]SeedRow{}
+	for rows.Next() {
+		var r SeedRow
+		if err := rows.Scan(&r.Name, &r.Checksum, &r.AppliedAt, &r.AppliedBy, &r.DurationMS); err != nil {
+			return nil, err
+		}
+		out[r.Name] = r
+	}
+	return out, rows.Err()
+}
+
+// Record stores r, replacing the seed's previous row.
+func (s *SeedStorage) Record(ctx context.Context, r SeedRow) error {
+	res, err := s.DB.ExecContext(ctx, s.driver().Rebind(fmt.Sprintf(`UPDATE %s SET checksum=?, applied_at=?, applied_by=?, duration_ms=? WHERE name=?`, s.table())),
+		r.Checksum, r.AppliedAt, r.AppliedBy, r.DurationMS, r.Name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+	_, err = s.DB.ExecContext(ctx, s.driver().Rebind(fmt.Sprintf(`INSERT INTO %s (name, checksum, applied_at, applied_by, duration_ms) VALUES (?, ?, ?, ?, ?)`, s.table())),
+		r.Name, r.Checksum, r.AppliedAt, r.AppliedBy, r.DurationMS)
+	return err
+}
+
+// Seed run outcomes in SeedResult.Status.
+const (
+	SeedApplied   = "applied"
+	SeedUnchanged = "unchanged" // skipped: same checksum as the last run
+	SeedFailed    = "failed"
+)
+
+// SeedResult is what ApplySeeds did with one seed.
+type SeedResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ApplySeeds runs seeds in order, each in its own transaction, and records
+// each success in st within that transaction, creating its table first. With onlyChanged, seeds
+// whose checksum matches their last recorded run are skipped; otherwise
+// every seed runs every time, so they must be idempotent (INSERT ... ON
+// DUPLICATE KEY UPDATE, ON CONFLICT DO NOTHING and the like). It stops at
+// the first failure, which is not recorded so the seed runs again next
+// time, and returns the results so far. Hold the advisory lock for
+// st.Table around it when several instances may seed at once.
+func (r *Runner) ApplySeeds(ctx context.Context, seeds []Seed, st *SeedStorage, onlyChanged bool) ([]SeedResult, error) {
+	if err := st.Ensure(ctx); err != nil {
+		return nil, fmt.Errorf("cannot create seeds table %s: %w", st.Table, err)
+	}
+	var previous map[string]SeedRow
+	if onlyChanged {
+		var err error
+		if previous, err = st.GetAll(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var results []SeedResult
+	for _, s := range seeds {
+		if prev, ok := previous[s.Name]; ok && strings.EqualFold(prev.Checksum, s.Checksum) {
+			results = append(results, SeedResult{Name: s.Name, Status: SeedUnchanged})
+			continue
+		}
+		res := SeedResult{Name: s.Name, Status: SeedApplied}
+		err := r.execSeed(ctx, s, st, &res)
+		if err != nil {
+			res.Status, res.Error = SeedFailed, err.Error()
+			return append(results, res), fmt.Errorf("seed %s failed: %w", s.Name, err)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// execSeed runs a seed's statements in one transaction, with Runner.Params
+// bound like a migration's, and records it in st in the same transaction, so
+// a seed is never applied without its row. The run's duration goes in res.
+func (r *Runner) execSeed(ctx context.Context, s Seed, st *SeedStorage, res *SeedResult) error {
+	stmts, err := r.statements(s.Body, false)
+	if err != nil {
+		return err
+	}
+	return r.inTx(ctx, txSettings{isolation: r.Isolation}, func(tx *sql.Tx) error {
+		start := time.Now()
+		err := execBound(ctx, tx, stmts)
+		res.DurationMS = time.Since(start).Milliseconds()
+		if err != nil {
+			return err
+		}
+		txSt := &SeedStorage{DB: tx, Table: st.Table, Driver: st.Driver}
+		return txSt.Record(ctx, SeedRow{Name: s.Name, Checksum: s.Checksum, AppliedAt: start.UTC(), AppliedBy: r.appliedBy(ctx), DurationMS: res.DurationMS})
+	})
+}
+
+// WriteSeedResults writes the results of ApplySeeds as a table, or as a
+// JSON array when asJSON is set.
+func WriteSeedResults(w io.Writer, results []SeedResult, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEED\tSTATUS\tDURATION\tERROR")
+	for _, res := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Name, res.Status, time.Duration(res.DurationMS)*time.Millisecond, res.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,116 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/checksum"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestApplySeeds(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"020_countries.sql": "INSERT IGNORE INTO countries VALUES ('NL');",
+		"010_roles.sql":     "INSERT IGNORE INTO roles VALUES ('admin');",
+		"030_broken.sql":    "INSERT INTO nowhere VALUES (1);",
+		"notes.txt":         "not a seed",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seeds, err := LoadSeeds(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(seeds) != 3 || seeds[0].Name != "010_roles.sql" || seeds[2].Name != "030_broken.sql" {
+		t.Fatalf("seeds = %+v", seeds)
+	}
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `schema_seeds`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT name, checksum, applied_at, applied_by, duration_ms FROM `schema_seeds`").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "checksum", "applied_at", "applied_by", "duration_ms"}).
+			AddRow("010_roles.sql", checksum.SHA256(seeds[0].Body), time.Now(), "ci", int64(3)).
+			AddRow("020_countries.sql", "an-older-checksum", time.Now(), "ci", int64(3)))
+	// 010 is unchanged; 020 changed and runs, updating its row in the same
+	// transaction
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO countries").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `schema_seeds` SET checksum=\\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// 030 is new and fails, so it isn't recorded
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO nowhere").WillReturnError(errors.New("table nowhere doesn't exist"))
+	mock.ExpectRollback()
+
+	r := NewRunner(sqlDB, "schema_migrations", "tester")
+	st := &SeedStorage{DB: sqlDB, Table: "schema_seeds"}
+	results, err := r.ApplySeeds(context.Background(), seeds, st, true)
+	if err == nil || !strings.Contains(err.Error(), "030_broken.sql") {
+		t.Fatalf("expected the broken seed to fail, got %v", err)
+	}
+	var got []string
+	for _, res := range results {
+		got = append(got, res.Name+":"+res.Status)
+	}
+	if strings.Join(got, ",") != "010_roles.sql:unchanged,020_countries.sql:applied,030_broken.sql:failed" {
+		t.Fatalf("results = %v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+
+	var text strings.Builder
+	if err := WriteSeedResults(&text, results, false); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if !strings.Contains(text.String(), "table nowhere doesn't exist") {
+		t.Fatalf("text output missing the error:\n%s", text.String())
+	}
+}
+
+func TestSeedStorageRecordInsertsNewSeeds(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	mock.ExpectExec("UPDATE \"schema_seeds\" SET checksum=\\$1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO \"schema_seeds\" \\(name, checksum, applied_at, applied_by, duration_ms\\) VALUES \\(\\$1").
+		WithArgs("010_roles.sql", "c1", sqlmock.AnyArg(), "ci", int64(2)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	st := &SeedStorage{DB: sqlDB, Table: "schema_seeds", Driver: db.Postgres}
+	if err := st.Record(context.Background(), SeedRow{Name: "010_roles.sql", Checksum: "c1", AppliedAt: time.Now(), AppliedBy: "ci", DurationMS: 2}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestLoadSeedsWithChecksumFunc(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "010_roles.sql"), []byte("INSERT INTO roles VALUES ('admin');\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	seeds, err := LoadSeedsWith(dir, checksum.Normalized)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(seeds) != 1 || seeds[0].Checksum != checksum.Normalized(seeds[0].Body) || seeds[0].Checksum == checksum.SHA256(seeds[0].Body) {
+		t.Fatalf("seed checksum not computed by the given func: %+v", seeds)
+	}
+}
@@ -0,0 +1,91 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+// ErrShadowNotEmpty is returned by ShadowRun when the shadow database already
+// has applied migrations, so the run wouldn't start from zero.
+var ErrShadowNotEmpty = errors.New("shadow database is not empty")
+
+var shadowNameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ShadowName returns a unique name for a throwaway shadow database.
+func ShadowName(now time.Time) string {
+	return fmt.Sprintf("gomigratex_shadow_%d", now.UnixNano())
+}
+
+// ShadowRun applies every migration in files, in order, from zero on shadow,
+// a disposable database, to prove the whole sequence builds a valid schema
+// regardless of production's state. This catches ordering problems and
+// edited files that an incremental plan never re-runs. The tracking table
+// must be empty.
+func ShadowRun(ctx context.Context, shadow *sql.DB, d db.Driver, table string, files []FilePair) ([]Row, error) {
+	r := NewRunner(shadow, table, "gomigratex-shadow")
+	r.Storage.Driver = d
+	if err := r.Ensure(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := r.Storage.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) > 0 {
+		return nil, fmt.Errorf("%w: %d migrations recorded in %s", ErrShadowNotEmpty, len(applied), table)
+	}
+	return r.ApplyUp(ctx, files, false, nil)
+}
+
+// CreateShadow creates database name through admin and returns a function
+// that drops it again. The drop runs even if ctx was cancelled.
+func CreateShadow(ctx context.Context, admin *sql.DB, name string) (drop func(context.Context) error, err error) {
+	if !shadowNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid shadow database name %q", name)
+	}
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+name); err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) error {
+		_, err := admin.ExecContext(context.WithoutCancel(ctx), "DROP DATABASE IF EXISTS "+name)
+		return err
+	}, nil
+}
+
+// Shadow validates files against a shadow database reached through dsn.
+// With create, a fresh database is created on dsn's server, migrated and
+// dropped afterwards, so dsn's user needs CREATE and DROP privileges.
+// Otherwise dsn must name an empty database, which is left for the caller
+// to discard.
+func Shadow(ctx context.Context, dsn string, create bool, table string, files []FilePair) ([]Row, error) {
+	conn, d, err := db.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if !create {
+		return ShadowRun(ctx, conn, d, table, files)
+	}
+	name := ShadowName(time.Now())
+	drop, err := CreateShadow(ctx, conn, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = drop(ctx) }()
+	shadowDSN, err := db.DSNWithDatabase(dsn, name)
+	if err != nil {
+		return nil, err
+	}
+	shadow, d, err := db.Open(shadowDSN)
+	if err != nil {
+		return nil, err
+	}
+	defer shadow.Close()
+	return ShadowRun(ctx, shadow, d, table, files)
+}
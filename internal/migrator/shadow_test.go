@@ -0,0 +1,100 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestShadowRunAppliesFromZero(t *testing.T) {
+	shadow, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer shadow.Close()
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS \"schema_migrations\"").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE \"schema_migrations\" ADD COLUMN IF NOT EXISTS tool_version").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE \"schema_migrations\" ADD COLUMN IF NOT EXISTS down_checksum").WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, col := range []string{"checksum", "down_checksum"} {
+		mock.ExpectQuery("character_maximum_length").WithArgs("", "schema_migrations", col).
+			WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(255))
+	}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(execution_order), 0)")).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	for i, q := range []string{"CREATE TABLE users", "ALTER TABLE users"} {
+		mock.ExpectBegin()
+		mock.ExpectExec(q).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectExec("INSERT INTO \"schema_migrations\"").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT execution_order FROM \"schema_migrations\"").
+			WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(i + 1)))
+	}
+
+	files := []FilePair{
+		{Version: "20250101000000", Name: "users", UpBytes: []byte("CREATE TABLE users(id INT);"), Checksum: "a"},
+		{Version: "20250102000000", Name: "email", UpBytes: []byte("ALTER TABLE users ADD COLUMN email TEXT;"), Checksum: "b"},
+	}
+	applied, err := ShadowRun(context.Background(), shadow, db.Postgres, "schema_migrations", files)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("shadow run: applied=%d err=%v", len(applied), err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestShadowRunRequiresEmptyDatabase(t *testing.T) {
+	shadow, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer shadow.Close()
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ADD COLUMN IF NOT EXISTS tool_version").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ADD COLUMN IF NOT EXISTS down_checksum").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("character_maximum_length").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(64))
+	mock.ExpectExec("ALTER TABLE \"schema_migrations\" ALTER COLUMN checksum TYPE VARCHAR\\(255\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("character_maximum_length").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(255))
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(
+		[]string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}).
+		AddRow("20250101000000", "users", "a", time.Now(), "prod", int64(1), "success", int64(1), "dev", nil))
+
+	if _, err := ShadowRun(context.Background(), shadow, db.Postgres, "schema_migrations", nil); !errors.Is(err, ErrShadowNotEmpty) {
+		t.Fatalf("expected ErrShadowNotEmpty, got %v", err)
+	}
+}
+
+func TestCreateShadow(t *testing.T) {
+	admin, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer admin.Close()
+	name := ShadowName(time.Unix(0, 42))
+	mock.ExpectExec("CREATE DATABASE gomigratex_shadow_42").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DROP DATABASE IF EXISTS gomigratex_shadow_42").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	drop, err := CreateShadow(ctx, admin, name)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	cancel()
+	if err := drop(ctx); err != nil {
+		t.Fatalf("drop after cancellation: %v", err)
+	}
+	if _, err := CreateShadow(context.Background(), admin, "x; DROP DATABASE prod"); err == nil {
+		t.Fatal("expected invalid name error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// WriteApplied exports applied rows as a JSON array ordered by execution
+// order, for ReadApplied in another environment.
+func WriteApplied(w io.Writer, applied map[string]Row) error {
+	rows := make([]Row, 0, len(applied))
+	for _, r := range applied {
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ExecutionOrder < rows[j].ExecutionOrder })
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// ReadApplied imports rows written by WriteApplied, keyed like Storage.GetAll.
+func ReadApplied(r io.Reader) (map[string]Row, error) {
+	var rows []Row
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("read applied rows: %w", err)
+	}
+	out := make(map[string]Row, len(rows))
+	for _, row := range rows {
+		out[Key(row.Version, row.Name)] = row
+	}
+	return out, nil
+}
+
+// Since returns the migrations to re-apply after restoring a backup taken at
+// cutoff: those recorded as successfully applied after cutoff in source (the
+// environment the backup came from, usually imported with ReadApplied) that
+// restored doesn't have as applied. They are returned in source's execution
+// order, so the restored database replays them as originally applied. Every
+// one must be in files.
+func Since(files []FilePair, source, restored map[string]Row, cutoff time.Time) ([]FilePair, error) {
+	byKey := make(map[string]FilePair, len(files))
+	for _, fp := range files {
+		byKey[Key(fp.Version, fp.Name)] = fp
+	}
+	var rows []Row
+	for k, row := range source {
+		if row.Status != "success" || !row.AppliedAt.After(cutoff) {
+			continue
+		}
+		if r, ok := restored[k]; ok && r.Status == "success" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ExecutionOrder < rows[j].ExecutionOrder })
+	out := make([]FilePair, 0, len(rows))
+	for _, row := range rows {
+		fp, ok := byKey[Key(row.Version, row.Name)]
+		if !ok {
+			return nil, fmt.Errorf("migration %s:%s applied after the cutoff has no file", row.Version, row.Name)
+		}
+		out = append(out, fp)
+	}
+	return out, nil
+}
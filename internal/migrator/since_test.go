@@ -0,0 +1,48 @@
+package migrator
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSince(t *testing.T) {
+	cutoff := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return cutoff.Add(time.Duration(h) * time.Hour) }
+	files := []FilePair{
+		{Version: "20250101000000", Name: "base"},
+		{Version: "20250201000000", Name: "out_of_order"},
+		{Version: "20250301000000", Name: "after"},
+		{Version: "20250302000000", Name: "failed"},
+	}
+	source := map[string]Row{
+		"20250101000000:base":         {Version: "20250101000000", Name: "base", AppliedAt: at(-48), Status: "success", ExecutionOrder: 1},
+		"20250301000000:after":        {Version: "20250301000000", Name: "after", AppliedAt: at(1), Status: "success", ExecutionOrder: 2},
+		"20250201000000:out_of_order": {Version: "20250201000000", Name: "out_of_order", AppliedAt: at(2), Status: "success", ExecutionOrder: 3},
+		"20250302000000:failed":       {Version: "20250302000000", Name: "failed", AppliedAt: at(3), Status: "failed", ExecutionOrder: 4},
+	}
+	// round-trip the source through an export, as when importing it from production
+	var buf bytes.Buffer
+	if err := WriteApplied(&buf, source); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	imported, err := ReadApplied(&buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	restored := map[string]Row{
+		"20250101000000:base": source["20250101000000:base"],
+	}
+
+	got, err := Since(files, imported, restored, cutoff)
+	if err != nil {
+		t.Fatalf("since: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "after" || got[1].Name != "out_of_order" {
+		t.Fatalf("expected after, out_of_order in source execution order, got %+v", got)
+	}
+
+	if _, err := Since(files[:1], imported, restored, cutoff); err == nil {
+		t.Fatal("expected an error for a migration with no file")
+	}
+}
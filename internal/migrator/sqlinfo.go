@@ -0,0 +1,52 @@
+package migrator
+
+import (
+	"regexp"
+	"strings"
+)
+
+var createTableRe = regexp.MustCompile("(?i)\\bCREATE\\s+(?:TEMPORARY\\s+)?TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?((?:`[^`]+`|[A-Za-z0-9_$]+)(?:\\.(?:`[^`]+`|[A-Za-z0-9_$]+))?)")
+
+// createdTables returns the tables a script creates, unquoted, in order of
+// first appearance. It is a heuristic over the raw SQL, not a parser.
+func createdTables(script string) []string {
+	return matchTables(createTableRe, script)
+}
+
+func matchTables(re *regexp.Regexp, script string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, m := range re.FindAllStringSubmatch(script, -1) {
+		name := strings.ReplaceAll(m[1], "`", "")
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		out = append(out, name)
+	}
+	return out
+}
+
+var alterTableRe = regexp.MustCompile("(?i)\\bALTER\\s+(?:ONLINE\\s+|IGNORE\\s+)?TABLE\\s+((?:`[^`]+`|[A-Za-z0-9_$]+)(?:\\.(?:`[^`]+`|[A-Za-z0-9_$]+))?)")
+
+// ChangedTables returns the tables created or altered by the up SQL of files,
+// deduplicated in order of first appearance. Temporary tables are included;
+// callers analyzing them should expect harmless "doesn't exist" results.
+func ChangedTables(files []FilePair) []string {
+	var b strings.Builder
+	for _, fp := range files {
+		b.Write(fp.UpBytes)
+		b.WriteByte('\n')
+	}
+	script := b.String()
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range append(createdTables(script), matchTables(alterTableRe, script)...) {
+		if seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		out = append(out, t)
+	}
+	return out
+}
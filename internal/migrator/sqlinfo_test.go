@@ -0,0 +1,27 @@
+package migrator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCreatedTables(t *testing.T) {
+	script := "CREATE TABLE users (id INT);\ncreate table if not exists `orders` (id INT);\nCREATE TEMPORARY TABLE ops.tmp_x (id INT);\nCREATE TABLE users (id INT);"
+	got := createdTables(script)
+	want := []string{"users", "orders", "ops.tmp_x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestChangedTables(t *testing.T) {
+	files := []FilePair{
+		{UpBytes: []byte("CREATE TABLE users (id INT);")},
+		{UpBytes: []byte("ALTER TABLE users ADD COLUMN email TEXT;\nALTER ONLINE TABLE `ops`.`audit` ADD INDEX i (a);")},
+	}
+	got := ChangedTables(files)
+	want := []string{"users", "ops.audit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
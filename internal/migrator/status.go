@@ -0,0 +1,144 @@
+package migrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// StatusItem is one migration in a StatusReport. State is "applied",
+// "pending" or "failed".
+type StatusItem struct {
+	Version     string     `json:"version"`
+	Name        string     `json:"name"`
+	State       string     `json:"state"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	AppliedBy   string     `json:"applied_by,omitempty"`
+	ToolVersion string     `json:"tool_version,omitempty"`
+	Drifted     bool       `json:"drifted,omitempty"`
+}
+
+// DriftedItem is an applied migration whose file no longer matches the
+// checksum stored for it.
+type DriftedItem struct {
+	Key     string `json:"key"`
+	Stored  string `json:"stored"`
+	Current string `json:"current"`
+}
+
+// StatusReport summarizes the migration set against the tracking table for
+// monitoring: counts by state, drift, orphaned rows and every migration.
+type StatusReport struct {
+	Applied int           `json:"applied"`
+	Pending int           `json:"pending"`
+	Failed  int           `json:"failed"`
+	Drifted []DriftedItem `json:"drifted"`
+	// Orphans are applied rows with no migration file, as version:name.
+	Orphans []string `json:"orphans,omitempty"`
+	// OutOfOrder are pending migrations versioned below the highest applied
+	// one (Plan.OutOfOrder), as version:name.
+	OutOfOrder []string     `json:"out_of_order,omitempty"`
+	Items      []StatusItem `json:"items"`
+}
+
+// Status plans src against st and reports the state of every migration.
+// Unlike planning for up, drift doesn't fail it: drifted migrations are
+// listed in Drifted and flagged in Items, and nothing is repaired, so it
+// overrides any WithDriftPolicy in opts.
+func Status(ctx context.Context, src FileSource, st *Storage, opts ...PlanOption) (StatusReport, error) {
+	report := DriftPolicyFunc(func(string, string, string) (DriftAction, error) { return DriftIgnore, nil })
+	plan, err := DiscoverAndPlan(ctx, src, st, append(opts, WithDriftPolicy(report))...)
+	if err != nil {
+		return StatusReport{}, err
+	}
+	return statusOf(plan), nil
+}
+
+func statusOf(plan *Plan) StatusReport {
+	rep := StatusReport{Drifted: []DriftedItem{}, Items: []StatusItem{}}
+	for _, fp := range plan.OutOfOrder {
+		rep.OutOfOrder = append(rep.OutOfOrder, Key(fp.Version, fp.Name))
+	}
+	for _, fp := range plan.All {
+		k := Key(fp.Version, fp.Name)
+		item := StatusItem{Version: fp.Version, Name: fp.Name, State: "pending"}
+		if row, ok := plan.Applied[k]; ok {
+			at := row.AppliedAt
+			item.AppliedAt, item.AppliedBy, item.ToolVersion = &at, row.AppliedBy, row.ToolVersion
+			switch row.Status {
+			case "success":
+				item.State = "applied"
+				if !strings.EqualFold(row.Checksum, fp.Checksum) {
+					item.Drifted = true
+					rep.Drifted = append(rep.Drifted, DriftedItem{Key: k, Stored: row.Checksum, Current: fp.Checksum})
+				}
+			case "failed":
+				item.State = "failed"
+			}
+		}
+		switch item.State {
+		case "applied":
+			rep.Applied++
+		case "failed":
+			rep.Failed++
+		default:
+			rep.Pending++
+		}
+		rep.Items = append(rep.Items, item)
+	}
+	for _, row := range plan.Orphans() {
+		rep.Orphans = append(rep.Orphans, Key(row.Version, row.Name))
+	}
+	return rep
+}
+
+// WriteStatus writes rep as a table followed by a summary line and a warning
+// naming any out-of-order migrations, or as one JSON object when asJSON is
+// set.
+func WriteStatus(w io.Writer, rep StatusReport, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	late := make(map[string]bool, len(rep.OutOfOrder))
+	for _, k := range rep.OutOfOrder {
+		late[k] = true
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT\tAPPLIED BY\tTOOL VERSION")
+	for _, it := range rep.Items {
+		state, at := it.State, ""
+		if it.Drifted {
+			state += " (drifted)"
+		}
+		if late[Key(it.Version, it.Name)] {
+			state += " (out of order)"
+		}
+		if it.AppliedAt != nil {
+			at = it.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", it.Version, it.Name, state, at, it.AppliedBy, it.ToolVersion)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(&b, "%d applied, %d pending, %d failed, %d drifted", rep.Applied, rep.Pending, rep.Failed, len(rep.Drifted))
+	if len(rep.Orphans) > 0 {
+		fmt.Fprintf(&b, ", %d orphaned", len(rep.Orphans))
+	}
+	if len(rep.OutOfOrder) > 0 {
+		fmt.Fprintf(&b, ", %d out of order", len(rep.OutOfOrder))
+	}
+	b.WriteString("\n")
+	if len(rep.OutOfOrder) > 0 {
+		fmt.Fprintf(&b, "warning: pending migrations older than the latest applied one: %s\n", strings.Join(rep.OutOfOrder, ", "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
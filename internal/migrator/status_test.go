@@ -0,0 +1,124 @@
+package migrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStatus_ReportsDriftWithoutFailing(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "1", "init", "CREATE TABLE a(id INT);", "DROP TABLE a;")
+	writePair(t, dir, "2", "broken", "CREATE TABLE b(id INT);", "DROP TABLE b;")
+	writePair(t, dir, "3", "next", "CREATE TABLE c(id INT);", "DROP TABLE c;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("1", "init", "edited-since", at, "alice", int64(5), "success", int64(1), "v1.4.0", nil).
+		AddRow("2", "broken", "c2", at, "bob", int64(5), "failed", int64(2), "dev", nil).
+		AddRow("0", "gone", "c0", at, "carol", int64(5), "success", int64(0), "dev", nil))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	rep, err := Status(context.Background(), FileSource{RootDir: dir}, st)
+	if err != nil {
+		t.Fatalf("status must not fail on drift: %v", err)
+	}
+	if rep.Applied != 1 || rep.Pending != 1 || rep.Failed != 1 {
+		t.Fatalf("counts = %d applied, %d pending, %d failed", rep.Applied, rep.Pending, rep.Failed)
+	}
+	if len(rep.Drifted) != 1 || rep.Drifted[0].Key != "1:init" || rep.Drifted[0].Stored != "edited-since" || !rep.Items[0].Drifted {
+		t.Fatalf("drifted = %+v, items = %+v", rep.Drifted, rep.Items)
+	}
+	if rep.Items[0].ToolVersion != "v1.4.0" {
+		t.Fatalf("tool version = %q", rep.Items[0].ToolVersion)
+	}
+	if len(rep.Orphans) != 1 || rep.Orphans[0] != "0:gone" {
+		t.Fatalf("orphans = %v", rep.Orphans)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+
+	var js strings.Builder
+	if err := WriteStatus(&js, rep, true); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(js.String()), &decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, key := range []string{"applied", "pending", "failed", "drifted", "items"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("json missing %q:\n%s", key, js.String())
+		}
+	}
+	var text strings.Builder
+	if err := WriteStatus(&text, rep, false); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	for _, want := range []string{"applied (drifted)", "TOOL VERSION", "v1.4.0", "3        next", "1 applied, 1 pending, 1 failed, 1 drifted, 1 orphaned"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+}
+
+func TestStatus_ReportsOutOfOrder(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "1", "init", "CREATE TABLE a(id INT);", "DROP TABLE a;")
+	writePair(t, dir, "2", "from_branch", "CREATE TABLE b(id INT);", "DROP TABLE b;")
+	writePair(t, dir, "3", "latest", "CREATE TABLE c(id INT);", "DROP TABLE c;")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	d, err := Discover(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	columns := []string{"version", "name", "checksum", "applied_at", "applied_by", "duration_ms", "status", "execution_order", "tool_version", "down_checksum"}
+	mock.ExpectQuery("SELECT version, name, checksum").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("1", "init", d.Files[0].Checksum, at, "alice", int64(5), "success", int64(1), "dev", nil).
+		AddRow("3", "latest", d.Files[2].Checksum, at, "alice", int64(5), "success", int64(2), "dev", nil))
+
+	rep, err := Status(context.Background(), FileSource{RootDir: dir}, &Storage{DB: db, Table: "schema_migrations"})
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(rep.OutOfOrder) != 1 || rep.OutOfOrder[0] != "2:from_branch" {
+		t.Fatalf("out of order = %v", rep.OutOfOrder)
+	}
+
+	var js strings.Builder
+	if err := WriteStatus(&js, rep, true); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if !strings.Contains(js.String(), `"out_of_order": [`) {
+		t.Fatalf("json missing out_of_order:\n%s", js.String())
+	}
+	var text strings.Builder
+	if err := WriteStatus(&text, rep, false); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	for _, want := range []string{"pending (out of order)", "2 applied, 1 pending, 0 failed, 0 drifted, 1 out of order", "warning: pending migrations older than the latest applied one: 2:from_branch"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package migrator
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/fsutil"
+)
+
+// StatusFile mirrors run progress into a small JSON file for external
+// monitors. Pass its Progress method as the ApplyUp/ApplyDown callback;
+// every update rewrites the file atomically.
+type StatusFile struct {
+	Path          string
+	Total         int
+	LockHeldSince time.Time
+
+	mu   sync.Mutex
+	done int
+	err  error
+}
+
+type statusSnapshot struct {
+	Stage         string     `json:"stage"`
+	Version       string     `json:"version,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Done          int        `json:"done"`
+	Total         int        `json:"total"`
+	LockHeldSince *time.Time `json:"lock_held_since,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Progress records a progress callback. Write failures never interrupt the
+// run; check Err afterwards.
+func (s *StatusFile) Progress(stage string, fp FilePair, row *Row, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stage == "success" {
+		s.done++
+	}
+	snap := statusSnapshot{Stage: stage, Version: fp.Version, Name: fp.Name, Done: s.done, Total: s.Total, UpdatedAt: time.Now().UTC()}
+	if !s.LockHeldSince.IsZero() {
+		t := s.LockHeldSince.UTC()
+		snap.LockHeldSince = &t
+	}
+	if err != nil {
+		snap.Error = err.Error()
+	}
+	if werr := s.write(snap); werr != nil {
+		s.err = werr
+	}
+}
+
+// Finish writes a terminal "done" (or "error") snapshot.
+func (s *StatusFile) Finish(runErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := statusSnapshot{Stage: "done", Done: s.done, Total: s.Total, UpdatedAt: time.Now().UTC()}
+	if runErr != nil {
+		snap.Stage = "error"
+		snap.Error = runErr.Error()
+	}
+	return s.write(snap)
+}
+
+// Err returns the last write failure, if any.
+func (s *StatusFile) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *StatusFile) write(snap statusSnapshot) error {
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(s.Path, b, 0o644)
+}
@@ -0,0 +1,47 @@
+package migrator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatusFileReflectsProgress(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "migratex.json")
+	since := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sf := &StatusFile{Path: p, Total: 2, LockHeldSince: since}
+
+	read := func() statusSnapshot {
+		t.Helper()
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var snap statusSnapshot
+		if err := json.Unmarshal(b, &snap); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return snap
+	}
+
+	a := FilePair{Version: "1", Name: "a"}
+	sf.Progress("start", a, nil, nil)
+	if s := read(); s.Stage != "start" || s.Name != "a" || s.Done != 0 || s.Total != 2 || !s.LockHeldSince.Equal(since) {
+		t.Fatalf("unexpected snapshot after start: %+v", s)
+	}
+	sf.Progress("success", a, nil, nil)
+	if s := read(); s.Stage != "success" || s.Done != 1 {
+		t.Fatalf("unexpected snapshot after success: %+v", s)
+	}
+	if err := sf.Finish(nil); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if s := read(); s.Stage != "done" || s.Done != 1 {
+		t.Fatalf("unexpected final snapshot: %+v", s)
+	}
+	if sf.Err() != nil {
+		t.Fatalf("unexpected write error: %v", sf.Err())
+	}
+}
@@ -4,23 +4,72 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
 )
 
+// upsertNextRetries bounds retries of UpsertNext when concurrent writers deadlock.
+const upsertNextRetries = 5
+
+// Execer is the query surface shared by *sql.DB, *sql.Conn and *sql.Tx, so
+// Storage and the migration executor can run inside a caller's transaction.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type Storage struct {
-	DB    *sql.DB
+	DB    Execer
 	Table string
+	// Driver supplies dialect-specific SQL; nil means MySQL.
+	Driver db.Driver
+}
+
+func (s *Storage) driver() db.Driver {
+	if s.Driver == nil {
+		return db.MySQL
+	}
+	return s.Driver
+}
+
+// table is Table quoted for the storage's driver, for use in SQL.
+func (s *Storage) table() string {
+	return db.QuoteTable(s.driver(), s.Table)
+}
+
+// q rebinds a '?'-placeholder query for the storage's driver.
+func (s *Storage) q(query string) string {
+	return s.driver().Rebind(query)
+}
+
+// rowColumns are the tracking table columns read into a Row, in scanRow order.
+const rowColumns = "version, name, checksum, applied_at, applied_by, duration_ms, status, execution_order, tool_version, down_checksum"
+
+func scanRow(rows *sql.Rows) (Row, error) {
+	var r Row
+	var down sql.NullString
+	err := rows.Scan(&r.Version, &r.Name, &r.Checksum, &r.AppliedAt, &r.AppliedBy, &r.DurationMS, &r.Status, &r.ExecutionOrder, &r.ToolVersion, &down)
+	r.DownChecksum = down.String
+	return r, err
+}
+
+// nullable stores an empty string as NULL.
+func nullable(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
 }
 
 func (s *Storage) GetAll(ctx context.Context) (map[string]Row, error) {
-	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`SELECT version, name, checksum, applied_at, applied_by, duration_ms, status, execution_order FROM %s`, s.Table))
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM %s`, rowColumns, s.table()))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	out := map[string]Row{}
 	for rows.Next() {
-		var r Row
-		if err := rows.Scan(&r.Version, &r.Name, &r.Checksum, &r.AppliedAt, &r.AppliedBy, &r.DurationMS, &r.Status, &r.ExecutionOrder); err != nil {
+		r, err := scanRow(rows)
+		if err != nil {
 			return nil, err
 		}
 		out[Key(r.Version, r.Name)] = r
@@ -28,8 +77,25 @@ func (s *Storage) GetAll(ctx context.Context) (map[string]Row, error) {
 	return out, rows.Err()
 }
 
+// GetAllAsOf returns the tracking rows as they stood at asOf: rows whose
+// applied_at is after the cutoff are treated as not yet applied.
+func (s *Storage) GetAllAsOf(ctx context.Context, asOf time.Time) (map[string]Row, error) {
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]Row, len(all))
+	for k, r := range all {
+		if r.AppliedAt.After(asOf) {
+			continue
+		}
+		out[k] = r
+	}
+	return out, nil
+}
+
 func (s *Storage) MaxExecutionOrder(ctx context.Context) (int64, error) {
-	row := s.DB.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(execution_order), 0) FROM %s`, s.Table))
+	row := s.DB.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(execution_order), 0) FROM %s`, s.table()))
 	var maxOrder int64
 	if err := row.Scan(&maxOrder); err != nil {
 		return 0, err
@@ -38,17 +104,51 @@ func (s *Storage) MaxExecutionOrder(ctx context.Context) (int64, error) {
 }
 
 func (s *Storage) Upsert(ctx context.Context, r Row) error {
-	_, err := s.DB.ExecContext(ctx, fmt.Sprintf(`
-INSERT INTO %s (version, name, checksum, applied_at, applied_by, duration_ms, status, execution_order)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-ON DUPLICATE KEY UPDATE checksum=VALUES(checksum), applied_at=VALUES(applied_at), applied_by=VALUES(applied_by), duration_ms=VALUES(duration_ms), status=VALUES(status), execution_order=VALUES(execution_order)
-`, s.Table),
-		r.Version, r.Name, r.Checksum, r.AppliedAt, r.AppliedBy, r.DurationMS, r.Status, r.ExecutionOrder,
+	_, err := s.DB.ExecContext(ctx, s.driver().UpsertSQL(s.table()),
+		r.Version, r.Name, r.Checksum, r.AppliedAt, r.AppliedBy, r.DurationMS, r.Status, r.ExecutionOrder, r.ToolVersion, nullable(r.DownChecksum),
 	)
 	return err
 }
 
+// UpdateChecksum overwrites the stored checksum for a migration.
+func (s *Storage) UpdateChecksum(ctx context.Context, version, name, checksum string) error {
+	_, err := s.DB.ExecContext(ctx, s.q(fmt.Sprintf(`UPDATE %s SET checksum=? WHERE version=? AND name=?`, s.table())), checksum, version, name)
+	return err
+}
+
+// UpdateDownChecksum overwrites the stored down-file checksum for a migration.
+func (s *Storage) UpdateDownChecksum(ctx context.Context, version, name, checksum string) error {
+	_, err := s.DB.ExecContext(ctx, s.q(fmt.Sprintf(`UPDATE %s SET down_checksum=? WHERE version=? AND name=?`, s.table())), nullable(checksum), version, name)
+	return err
+}
+
+// UpsertNext records r with the next execution_order computed in the same
+// statement as the insert, so writers that don't hold the advisory lock can't
+// assign duplicate orders from a stale MAX. Deadlocks between concurrent
+// writers are retried, unless DB is a transaction: the deadlock has already
+// rolled it back, so the error is returned for its owner to handle. The
+// assigned order is stored back into r.
+func (s *Storage) UpsertNext(ctx context.Context, r *Row) error {
+	q := s.driver().UpsertNextSQL(s.table())
+	attempts := upsertNextRetries
+	if _, inTx := s.DB.(*sql.Tx); inTx {
+		attempts = 1
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		_, err = s.DB.ExecContext(ctx, q, r.Version, r.Name, r.Checksum, r.AppliedAt, r.AppliedBy, r.DurationMS, r.Status, r.ToolVersion, nullable(r.DownChecksum))
+		if err == nil || !s.driver().IsDeadlock(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	row := s.DB.QueryRowContext(ctx, s.q(fmt.Sprintf(`SELECT execution_order FROM %s WHERE version=? AND name=?`, s.table())), r.Version, r.Name)
+	return row.Scan(&r.ExecutionOrder)
+}
+
 func (s *Storage) Delete(ctx context.Context, version, name string) error {
-	_, err := s.DB.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE version=? AND name=?`, s.Table), version, name)
+	_, err := s.DB.ExecContext(ctx, s.q(fmt.Sprintf(`DELETE FROM %s WHERE version=? AND name=?`, s.table())), version, name)
 	return err
 }
@@ -0,0 +1,78 @@
+//go:build integration
+
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+// TestUpsertNext_ConcurrentWritersGetUniqueOrders runs against the database
+// in DB_DSN (see make test-integration): writers that don't hold the
+// advisory lock insert at the same time, and every row must still get its
+// own execution_order.
+func TestUpsertNext_ConcurrentWritersGetUniqueOrders(t *testing.T) {
+	dsn := os.Getenv("DB_DSN")
+	if dsn == "" {
+		t.Skip("DB_DSN is not set")
+	}
+	pool, driver, err := db.Open(dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer pool.Close()
+	ctx := context.Background()
+	table := fmt.Sprintf("migratex_concurrent_%d", time.Now().UnixNano())
+	st := &Storage{DB: pool, Table: table, Driver: driver}
+	if err := driver.EnsureTable(ctx, pool, st.table()); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	defer pool.ExecContext(ctx, "DROP TABLE "+st.table())
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			row := Row{Version: fmt.Sprintf("202501010000%02d", i), Name: "concurrent", Checksum: "c", AppliedAt: time.Now(), AppliedBy: "test", Status: "success"}
+			errs[i] = st.UpsertNext(ctx, &row)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("writer %d: %v", i, err)
+		}
+	}
+
+	rows, err := pool.QueryContext(ctx, "SELECT execution_order FROM "+st.table())
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	defer rows.Close()
+	seen := map[int64]bool{}
+	for rows.Next() {
+		var order int64
+		if err := rows.Scan(&order); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		if seen[order] {
+			t.Fatalf("execution_order %d assigned twice", order)
+		}
+		seen[order] = true
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+	if len(seen) != writers {
+		t.Fatalf("expected %d rows, got %d", writers, len(seen))
+	}
+}
@@ -0,0 +1,148 @@
+package migrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestUpsertNext_RetriesDeadlockAndReadsAssignedOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// A concurrent writer wins the race: our first insert deadlocks and is
+	// retried, after which the order computed in-statement is read back.
+	mock.ExpectExec("INSERT INTO `schema_migrations` .* SELECT .*COALESCE\\(MAX\\(execution_order\\), 0\\) \\+ 1").
+		WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+	mock.ExpectExec("INSERT INTO `schema_migrations` .* SELECT").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations` WHERE version=\\? AND name=\\?").
+		WithArgs("20250102000000", "add").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(2)))
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	row := Row{Version: "20250102000000", Name: "add", Checksum: "c", AppliedAt: time.Now(), AppliedBy: "t", Status: "success"}
+	if err := st.UpsertNext(context.Background(), &row); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if row.ExecutionOrder != 2 {
+		t.Fatalf("expected assigned order 2, got %d", row.ExecutionOrder)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestUpsertNext_DoesNotRetryOtherErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").
+		WillReturnError(&mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"})
+
+	st := &Storage{DB: db, Table: "schema_migrations"}
+	row := Row{Version: "1", Name: "a", Status: "success"}
+	if err := st.UpsertNext(context.Background(), &row); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestUpsertNext_DoesNotRetryDeadlockInTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `schema_migrations`").
+		WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+	mock.ExpectRollback()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	st := &Storage{DB: tx, Table: "schema_migrations"}
+	row := Row{Version: "1", Name: "a", Status: "success"}
+	if err := st.UpsertNext(context.Background(), &row); err == nil {
+		t.Fatal("expected the deadlock to be returned")
+	}
+	_ = tx.Rollback()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUp_PersistsToolVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	prev := ToolVersion
+	ToolVersion = "v1.4.0"
+	defer func() { ToolVersion = prev }()
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE t1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO `schema_migrations` \\(.*tool_version").
+		WithArgs("1", "init", "x", sqlmock.AnyArg(), "tester", sqlmock.AnyArg(), "success", "v1.4.0", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM `schema_migrations`").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	files := []FilePair{{Version: "1", Name: "init", UpBytes: []byte("CREATE TABLE t1(id INT);"), Checksum: "x"}}
+	applied, err := r.ApplyUp(context.Background(), files, false, nil)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if applied[0].ToolVersion != "v1.4.0" {
+		t.Fatalf("unexpected tool version %q", applied[0].ToolVersion)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestStorage_PostgresDriver(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	mock.ExpectExec("INSERT INTO \"schema_migrations\" .* SELECT \\$1, .*ON CONFLICT \\(version, name\\) DO UPDATE").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT execution_order FROM \"schema_migrations\" WHERE version=\\$1 AND name=\\$2").
+		WithArgs("1", "a").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+	mock.ExpectExec("DELETE FROM \"schema_migrations\" WHERE version=\\$1 AND name=\\$2").
+		WithArgs("1", "a").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	st := &Storage{DB: sqlDB, Table: "schema_migrations", Driver: db.Postgres}
+	row := Row{Version: "1", Name: "a", Status: "success"}
+	if err := st.UpsertNext(context.Background(), &row); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if err := st.Delete(context.Background(), "1", "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
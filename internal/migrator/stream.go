@@ -0,0 +1,39 @@
+package migrator
+
+import "context"
+
+// Event is a progress notification from ApplyUpStream. Stage is one of
+// "start", "success", "error" for a single migration, or "done" for the
+// final summary, which carries the applied rows and the run's error.
+type Event struct {
+	Stage   string
+	File    FilePair
+	Row     Row
+	Err     error
+	Applied []Row // set on "done"
+}
+
+// ApplyUpStream runs ApplyUp in the background and reports progress as
+// events. The channel is closed after the "done" event. Consumers must drain
+// it; cancel ctx to stop early.
+func (r *Runner) ApplyUpStream(ctx context.Context, files []FilePair, dryRun bool) <-chan Event {
+	ch := make(chan Event)
+	send := func(ev Event) {
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(ch)
+		applied, err := r.ApplyUp(ctx, files, dryRun, func(stage string, fp FilePair, row *Row, err error) {
+			ev := Event{Stage: stage, File: fp, Err: err}
+			if row != nil {
+				ev.Row = *row
+			}
+			send(ev)
+		})
+		send(Event{Stage: "done", Applied: applied, Err: err})
+	}()
+	return ch
+}
@@ -0,0 +1,39 @@
+package migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestApplyUpStream_DryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(3)))
+
+	r := NewRunner(db, "schema_migrations", "tester")
+	files := []FilePair{{Version: "1", Name: "a"}, {Version: "2", Name: "b"}}
+	var stages []string
+	var last Event
+	for ev := range r.ApplyUpStream(context.Background(), files, true) {
+		stages = append(stages, ev.Stage+":"+ev.File.Name)
+		last = ev
+	}
+	want := []string{"start:a", "success:a", "start:b", "success:b", "done:"}
+	if len(stages) != len(want) {
+		t.Fatalf("stages = %v, want %v", stages, want)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Fatalf("stages = %v, want %v", stages, want)
+		}
+	}
+	if last.Err != nil || len(last.Applied) != 2 || last.Applied[1].ExecutionOrder != 5 {
+		t.Fatalf("unexpected summary: %+v", last)
+	}
+}
@@ -0,0 +1,71 @@
+package migrator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SelectForRevert picks exactly the named applied migrations for ApplyDown,
+// ordered by descending execution_order. Names may be file stems
+// ("20250101000000_init") or keys ("20250101000000:init"). Each must be
+// applied successfully and have a down file in plan.All. The returned
+// warnings list named migrations that have later successful migrations
+// applied after them, which a targeted rollback may break.
+func SelectForRevert(plan *Plan, names []string) ([]Row, []string, error) {
+	files := map[string]FilePair{}
+	for _, fp := range plan.All {
+		files[Key(fp.Version, fp.Name)] = fp
+	}
+	var maxOrder int64
+	for _, row := range plan.Applied {
+		if row.Status == "success" && row.ExecutionOrder > maxOrder {
+			maxOrder = row.ExecutionOrder
+		}
+	}
+	selected := map[string]Row{}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		key := n
+		if !strings.Contains(n, ":") {
+			version, name, ok := strings.Cut(n, "_")
+			if !ok {
+				return nil, nil, fmt.Errorf("invalid migration name %q: want <version>_<name>", n)
+			}
+			key = Key(version, name)
+		}
+		row, ok := plan.Applied[key]
+		if !ok || row.Status != "success" {
+			return nil, nil, fmt.Errorf("migration %s is not applied", key)
+		}
+		if fp, ok := files[key]; !ok || (fp.DownPath == "" && len(fp.DownBytes) == 0) {
+			return nil, nil, fmt.Errorf("missing down file for %s", key)
+		}
+		selected[key] = row
+	}
+	out := make([]Row, 0, len(selected))
+	for _, row := range selected {
+		out = append(out, row)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ExecutionOrder > out[j].ExecutionOrder })
+
+	var warnings []string
+	for _, row := range out {
+		later := 0
+		for k, other := range plan.Applied {
+			if _, picked := selected[k]; picked || other.Status != "success" {
+				continue
+			}
+			if other.ExecutionOrder > row.ExecutionOrder {
+				later++
+			}
+		}
+		if later > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s has %d later migration(s) applied after it; rolling it back may break them", Key(row.Version, row.Name), later))
+		}
+	}
+	return out, warnings, nil
+}
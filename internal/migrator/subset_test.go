@@ -0,0 +1,35 @@
+package migrator
+
+import "testing"
+
+func TestSelectForRevert(t *testing.T) {
+	plan := &Plan{
+		All: []FilePair{
+			{Version: "20250101000000", Name: "init", DownPath: "a.down.sql"},
+			{Version: "20250102000000", Name: "add", DownPath: "b.down.sql"},
+			{Version: "20250103000000", Name: "more", DownPath: "c.down.sql"},
+		},
+		Applied: map[string]Row{
+			"20250101000000:init": {Version: "20250101000000", Name: "init", Status: "success", ExecutionOrder: 1},
+			"20250102000000:add":  {Version: "20250102000000", Name: "add", Status: "success", ExecutionOrder: 2},
+			"20250103000000:more": {Version: "20250103000000", Name: "more", Status: "success", ExecutionOrder: 3},
+		},
+	}
+	rows, warnings, err := SelectForRevert(plan, []string{"20250101000000_init", "20250102000000:add"})
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Name != "add" || rows[1].Name != "init" {
+		t.Fatalf("expected reverse execution order [add init], got %+v", rows)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected warnings about the later 'more' migration, got %v", warnings)
+	}
+
+	if _, _, err := SelectForRevert(plan, []string{"20250109000000_missing"}); err == nil {
+		t.Fatal("expected error for unapplied migration")
+	}
+	if _, warnings, _ := SelectForRevert(plan, []string{"20250103000000_more"}); len(warnings) != 0 {
+		t.Fatalf("latest migration should not warn, got %v", warnings)
+	}
+}
@@ -0,0 +1,46 @@
+package migrator
+
+import (
+	"context"
+	"strings"
+)
+
+// NormalizeTableName trims surrounding whitespace and identifier quotes from
+// a configured tracking table name. Schema-qualified names, as returned by
+// db.QualifiedTable, keep their quotes.
+func NormalizeTableName(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return strings.Trim(name, "`")
+}
+
+// checkTableCase warns when the configured table name's case may not match
+// how the server resolves names. With lower_case_table_names=0 (typical on
+// Linux) names are case-sensitive, so an existing table differing only in case
+// is a different table; with 1 or 2 the server folds names to lowercase, so a
+// mixed-case name will not round-trip to case-sensitive servers. Best-effort:
+// probe failures are ignored.
+func (r *Runner) checkTableCase(ctx context.Context) {
+	table := r.Storage.Table
+	if strings.Contains(table, ".") {
+		return
+	}
+	var lctn int
+	if err := r.DB.QueryRowContext(ctx, "SELECT @@lower_case_table_names").Scan(&lctn); err != nil {
+		return
+	}
+	if lctn != 0 {
+		if table != strings.ToLower(table) {
+			r.warn("server has lower_case_table_names=%d; table %q will be stored as %q, use the lowercase name for portability", lctn, table, strings.ToLower(table))
+		}
+		return
+	}
+	var existing string
+	err := r.DB.QueryRowContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND LOWER(table_name) = LOWER(?) AND table_name <> ? LIMIT 1", table, table).Scan(&existing)
+	if err != nil {
+		return // sql.ErrNoRows: no case-variant clash
+	}
+	r.warn("table %q exists but configured name is %q; with lower_case_table_names=0 these are different tables", existing, table)
+}
@@ -0,0 +1,63 @@
+package migrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNormalizeTableName(t *testing.T) {
+	if got := NormalizeTableName("  `schema_migrations` "); got != "schema_migrations" {
+		t.Fatalf("got %q", got)
+	}
+	if got := NormalizeTableName(" `ops`.`schema_migrations`"); got != "`ops`.`schema_migrations`" {
+		t.Fatalf("qualified name must keep its quotes, got %q", got)
+	}
+}
+
+func TestCheckTableCase(t *testing.T) {
+	cases := []struct {
+		name     string
+		table    string
+		lctn     int
+		existing string
+		want     string
+	}{
+		{name: "folding server mixed case", table: "Schema_Migrations", lctn: 1, want: "lower_case_table_names=1"},
+		{name: "folding server lowercase", table: "schema_migrations", lctn: 1},
+		{name: "case-sensitive server clash", table: "Schema_Migrations", lctn: 0, existing: "schema_migrations", want: "different tables"},
+		{name: "case-sensitive server no clash", table: "schema_migrations", lctn: 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT @@lower_case_table_names").WillReturnRows(sqlmock.NewRows([]string{"v"}).AddRow(tc.lctn))
+			if tc.lctn == 0 {
+				rows := sqlmock.NewRows([]string{"table_name"})
+				if tc.existing != "" {
+					rows.AddRow(tc.existing)
+				}
+				mock.ExpectQuery("information_schema.tables").WillReturnRows(rows)
+			}
+			var warnings []string
+			r := NewRunner(db, tc.table, "tester")
+			r.OnWarn = func(msg string) { warnings = append(warnings, msg) }
+			r.checkTableCase(context.Background())
+			if tc.want == "" {
+				if len(warnings) != 0 {
+					t.Fatalf("unexpected warnings: %v", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0], tc.want) {
+				t.Fatalf("warnings = %v, want one containing %q", warnings, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoTransactionalDDL is returned by ValidateInTx on databases that commit
+// DDL implicitly, where a rolled-back rehearsal would leave changes behind.
+var ErrNoTransactionalDDL = errors.New("two-phase apply needs transactional DDL")
+
+// ValidateInTx rehearses files in order inside one transaction that is always
+// rolled back, proving the batch runs against the current schema before
+// anything is committed. Rehearsal stops before the first no-transaction
+// file, since it can't run inside a transaction; files after it are not
+// checked. Nothing is recorded in the tracking table.
+func (r *Runner) ValidateInTx(ctx context.Context, files []FilePair) error {
+	if !r.Storage.driver().TransactionalDDL() {
+		return fmt.Errorf("%w: %s commits DDL implicitly", ErrNoTransactionalDDL, r.Storage.driver().Name())
+	}
+	for _, fp := range files {
+		if err := r.checkParams(fp, fp.UpBytes); err != nil {
+			return err
+		}
+	}
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := r.setSessionTimeouts(ctx, tx); err != nil {
+		return err
+	}
+	for _, fp := range files {
+		if fp.NoTx {
+			break
+		}
+		var err error
+		if fp.goUp != nil {
+			err = fp.goUp(ctx, tx)
+		} else {
+			var stmts []stmt
+			if stmts, err = r.statements(fp.UpBytes, false); err == nil {
+				err = execBound(ctx, tx, stmts)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("validation of %s:%s failed: %w", fp.Version, fp.Name, err)
+		}
+	}
+	return nil
+}
+
+// ApplyUpTwoPhase runs ValidateInTx over files and, only if the whole batch
+// passes, applies them for real with ApplyUp (one commit per migration).
+// Migrations whose effect depends on data written concurrently may still
+// behave differently between the two phases.
+func (r *Runner) ApplyUpTwoPhase(ctx context.Context, files []FilePair, progress func(stage string, fp FilePair, row *Row, err error)) ([]Row, error) {
+	if err := r.ValidateInTx(ctx, files); err != nil {
+		return nil, err
+	}
+	return r.ApplyUp(ctx, files, false, progress)
+}
@@ -0,0 +1,85 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestApplyUpTwoPhase_Phase1CatchesBadMigration(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+
+	files := []FilePair{
+		{Version: "1", Name: "good", UpBytes: []byte("CREATE TABLE a(id INT);"), Checksum: "x"},
+		{Version: "2", Name: "bad", UpBytes: []byte("ALTER TABLE missing ADD COLUMN c INT;"), Checksum: "y"},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE missing").WillReturnError(errors.New(`relation "missing" does not exist`))
+	mock.ExpectRollback()
+
+	r := NewRunner(sqlDB, "schema_migrations", "tester")
+	r.Storage.Driver = db.Postgres
+	applied, err := r.ApplyUpTwoPhase(context.Background(), files, nil)
+	if err == nil || !strings.Contains(err.Error(), "2:bad") {
+		t.Fatalf("expected phase 1 failure for 2:bad, got %v", err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("nothing may be applied, got %v", applied)
+	}
+	// no commit, no tracking row written
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestApplyUpTwoPhase_AppliesAfterValidation(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+
+	files := []FilePair{{Version: "1", Name: "good", UpBytes: []byte("CREATE TABLE a(id INT);"), Checksum: "x"}}
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(execution_order\\), 0\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO \"schema_migrations\"").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT execution_order FROM \"schema_migrations\"").
+		WillReturnRows(sqlmock.NewRows([]string{"execution_order"}).AddRow(int64(1)))
+
+	r := NewRunner(sqlDB, "schema_migrations", "tester")
+	r.Storage.Driver = db.Postgres
+	applied, err := r.ApplyUpTwoPhase(context.Background(), files, nil)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("apply: %v, %v", applied, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestValidateInTx_RequiresTransactionalDDL(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	r := NewRunner(sqlDB, "schema_migrations", "tester")
+	if err := r.ValidateInTx(context.Background(), nil); !errors.Is(err, ErrNoTransactionalDDL) {
+		t.Fatalf("expected ErrNoTransactionalDDL on MySQL, got %v", err)
+	}
+}
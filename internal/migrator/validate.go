@@ -0,0 +1,99 @@
+package migrator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mirajehossain/gomigratex/internal/fsutil"
+	"github.com/mirajehossain/gomigratex/internal/sqlsplit"
+)
+
+// Reasons reported by Validate in addition to the fsutil scan reasons.
+const (
+	ReasonEmpty            = "empty"
+	ReasonDuplicateVersion = "duplicate-version"
+	ReasonUnsortable       = "unsortable-version"
+)
+
+// Problem is one issue found by Validate.
+type Problem struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+// Validate checks a migration source without a database: every file with the
+// migration extension must match the naming pattern, every version needs
+// both halves, versions must be unique and sort the same as text and as
+// numbers, and no file may be empty or comment-only. It collects all problems in one pass; the error is only for
+// sources that can't be read.
+func Validate(src FileSource) ([]Problem, error) {
+	d, err := Discover(src)
+	if err != nil {
+		return nil, err
+	}
+	ext := src.Ext
+	if ext == "" {
+		ext = fsutil.DefaultExt
+	}
+	var problems []Problem
+	for _, ig := range d.Ignored {
+		switch ig.Reason {
+		case fsutil.ReasonDialect:
+			continue
+		case fsutil.ReasonPattern:
+			if !strings.HasSuffix(ig.Path, ext) {
+				continue // unrelated files such as a README
+			}
+		}
+		problems = append(problems, Problem{Path: ig.Path, Reason: ig.Reason, Detail: ig.Detail})
+	}
+	seen := map[string]FilePair{}
+	for _, fp := range d.Files {
+		if prev, ok := seen[fp.Version]; ok {
+			problems = append(problems, Problem{Path: fp.UpPath, Reason: ReasonDuplicateVersion, Detail: "version " + fp.Version + " is also used by " + prev.Name})
+			continue
+		}
+		seen[fp.Version] = fp
+	}
+	problems = append(problems, unsortable(d.Files)...)
+	for i := range d.Files {
+		fp := &d.Files[i]
+		if err := d.load(fp); err != nil {
+			problems = append(problems, Problem{Path: fp.UpPath, Reason: "unreadable", Detail: err.Error()})
+			continue
+		}
+		if len(sqlsplit.Split(string(fp.UpBytes))) == 0 {
+			problems = append(problems, Problem{Path: fp.UpPath, Reason: ReasonEmpty, Detail: "up file has no statements"})
+		}
+		if len(sqlsplit.Split(string(fp.DownBytes))) == 0 {
+			problems = append(problems, Problem{Path: fp.DownPath, Reason: ReasonEmpty, Detail: "down file has no statements"})
+		}
+	}
+	return problems, nil
+}
+
+// unsortable reports versions whose text order, which planning uses,
+// differs from their numeric order, such as 9 and 10 next to each other.
+func unsortable(files []FilePair) []Problem {
+	sorted := append([]FilePair(nil), files...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	var problems []Problem
+	for i := 1; i < len(sorted); i++ {
+		prev, fp := sorted[i-1], sorted[i]
+		if prev.Version == fp.Version || numericLess(prev.Version, fp.Version) {
+			continue
+		}
+		problems = append(problems, Problem{Path: fp.UpPath, Reason: ReasonUnsortable, Detail: "version " + fp.Version + " sorts after " + prev.Version + " as text but not as a number; use versions of the same width"})
+	}
+	return problems
+}
+
+// numericLess compares two digit strings by value.
+func numericLess(a, b string) bool {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
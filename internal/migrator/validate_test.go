@@ -0,0 +1,92 @@
+package migrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "20250101000000", "init", "CREATE TABLE t(id INT);", "DROP TABLE t;")
+	writePair(t, dir, "20250102000000", "empty_down", "CREATE TABLE u(id INT);", "-- nothing to undo\n")
+	writePair(t, dir, "20250101000000", "clash", "SELECT 1;", "SELECT 1;")
+	for name, body := range map[string]string{
+		"20250103000000_orphan.up.sql": "SELECT 1;",
+		"bad-name.sql":                 "SELECT 1;",
+		"README.md":                    "docs",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	problems, err := Validate(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	got := map[string]string{}
+	for _, p := range problems {
+		got[filepath.Base(p.Path)] = p.Reason
+	}
+	want := map[string]string{
+		"20250103000000_orphan.up.sql":       "missing-pair",
+		"bad-name.sql":                       "not-matching-pattern",
+		"20250101000000_init.up.sql":         ReasonDuplicateVersion,
+		"20250102000000_empty_down.down.sql": ReasonEmpty,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("problems = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("problems = %v, want %v", got, want)
+		}
+	}
+
+	clean := t.TempDir()
+	writePair(t, clean, "20250101000000", "init", "CREATE TABLE t(id INT);", "DROP TABLE t;")
+	if problems, err := Validate(FileSource{RootDir: clean}); err != nil || len(problems) != 0 {
+		t.Fatalf("clean dir: %v, %v", problems, err)
+	}
+}
+
+func TestValidate_DuplicateVersionAcrossFolders(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"2025/01", "2025/02"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePair(t, filepath.Join(dir, "2025/01"), "20250101000000", "init", "CREATE TABLE t(id INT);", "DROP TABLE t;")
+	writePair(t, filepath.Join(dir, "2025/02"), "20250101000000", "clash", "SELECT 1;", "SELECT 1;")
+
+	problems, err := Validate(FileSource{RootDir: dir, Recursive: true})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Reason != ReasonDuplicateVersion {
+		t.Fatalf("problems = %+v", problems)
+	}
+}
+
+func TestValidate_UnsortableVersions(t *testing.T) {
+	dir := t.TempDir()
+	writePair(t, dir, "9", "ninth", "CREATE TABLE t9(id INT);", "DROP TABLE t9;")
+	writePair(t, dir, "10", "tenth", "CREATE TABLE t10(id INT);", "DROP TABLE t10;")
+	writePair(t, dir, "11", "eleventh", "CREATE TABLE t11(id INT);", "DROP TABLE t11;")
+	problems, err := Validate(FileSource{RootDir: dir})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Reason != ReasonUnsortable || filepath.Base(problems[0].Path) != "9_ninth.up.sql" {
+		t.Fatalf("problems = %+v", problems)
+	}
+
+	padded := t.TempDir()
+	writePair(t, padded, "009", "ninth", "CREATE TABLE t9(id INT);", "DROP TABLE t9;")
+	writePair(t, padded, "010", "tenth", "CREATE TABLE t10(id INT);", "DROP TABLE t10;")
+	if problems, err := Validate(FileSource{RootDir: padded}); err != nil || len(problems) != 0 {
+		t.Fatalf("same-width versions: %v, %v", problems, err)
+	}
+}
@@ -0,0 +1,197 @@
+// Package schemadiff scaffolds migrations from the output of an external
+// schema-diff tool. gomigratex only orchestrates: it runs the configured
+// command, captures the SQL it prints, and writes the migration pair.
+package schemadiff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/fsutil"
+	"github.com/mirajehossain/gomigratex/internal/sqlsplit"
+)
+
+var (
+	// ErrNoCommand means no diff command is configured or it isn't on PATH.
+	ErrNoCommand = errors.New("schema diff command not found")
+	// ErrNoChanges means the diff tool printed no SQL: the live database
+	// already matches the expected schema.
+	ErrNoChanges = errors.New("schema diff produced no changes")
+)
+
+// Command is an external diff tool. Args are templates in which {schema} is
+// replaced by the expected-schema file and {dsn} by the live database DSN.
+// The tool must print the SQL that brings the database to the expected
+// schema on stdout.
+type Command struct {
+	Path string
+	Args []string
+}
+
+// Run executes the diff and returns its stdout. A non-zero exit is an error
+// carrying the tool's stderr.
+func (c Command) Run(ctx context.Context, schemaFile, dsn string) (string, error) {
+	if strings.TrimSpace(c.Path) == "" {
+		return "", ErrNoCommand
+	}
+	path, err := exec.LookPath(c.Path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNoCommand, c.Path)
+	}
+	rep := strings.NewReplacer("{schema}", schemaFile, "{dsn}", dsn)
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = rep.Replace(a)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("schema diff %s: %w", c.Path, err)
+		}
+		return "", fmt.Errorf("schema diff %s: %w: %s", c.Path, err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// Create runs the diff and writes its output as the up file of a new
+// migration pair in dir, with Reverse of it as the down file in the dialect
+// of dsn's driver. Nothing is written when the diff fails or is empty.
+func Create(ctx context.Context, c Command, schemaFile, dsn, dir, version, name, ext string, onConflict fsutil.OnConflict) (fsutil.Created, error) {
+	driver, _, err := db.DriverFor(dsn)
+	if err != nil {
+		return fsutil.Created{}, err
+	}
+	up, err := c.Run(ctx, schemaFile, dsn)
+	if err != nil {
+		return fsutil.Created{}, err
+	}
+	if len(sqlsplit.Split(up)) == 0 {
+		return fsutil.Created{}, ErrNoChanges
+	}
+	created, err := fsutil.CreatePair(dir, version, name, ext, onConflict)
+	if err != nil || created.Skipped {
+		return created, err
+	}
+	if !strings.HasSuffix(up, "\n") {
+		up += "\n"
+	}
+	if err := os.WriteFile(created.UpPath, []byte(up), 0o644); err != nil {
+		return created, err
+	}
+	return created, os.WriteFile(created.DownPath, []byte(Reverse(up, driver)), 0o644)
+}
+
+var (
+	createTableRe = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)`)
+	createIndexRe = regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(\S+)\s+ON\s+([^\s(]+)`)
+	alterTableRe  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\S+)\s+(.+)$`)
+	addColumnRe   = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(\S+)`)
+)
+
+// Reverse is a best-effort down for up: CREATE TABLE, CREATE INDEX and
+// ALTER TABLE ... ADD COLUMN are undone in reverse order, and any other
+// statement is left as a TODO comment to write by hand. An ALTER TABLE with
+// several clauses is reversed only if every clause adds a column. Indexes are
+// dropped in d's syntax: DROP INDEX ... ON on MySQL (d nil or db.MySQL), a
+// plain DROP INDEX, qualified with the table's schema, elsewhere. Review the
+// result.
+func Reverse(up string, d db.Driver) string {
+	stmts := sqlsplit.Split(up)
+	var b strings.Builder
+	for i := len(stmts) - 1; i >= 0; i-- {
+		s := stmts[i]
+		if m := createTableRe.FindStringSubmatch(s); m != nil {
+			fmt.Fprintf(&b, "DROP TABLE IF EXISTS %s;\n", m[1])
+		} else if m := createIndexRe.FindStringSubmatch(s); m != nil {
+			b.WriteString(dropIndex(d, m[1], m[2]))
+		} else if table, cols := addedColumns(s); len(cols) > 0 {
+			drops := make([]string, len(cols))
+			for j, c := range cols {
+				drops[len(cols)-1-j] = "DROP COLUMN " + c
+			}
+			fmt.Fprintf(&b, "ALTER TABLE %s %s;\n", table, strings.Join(drops, ", "))
+		} else {
+			fmt.Fprintf(&b, "-- TODO: reverse manually: %s\n", strings.Join(strings.Fields(s), " "))
+		}
+	}
+	return b.String()
+}
+
+// dropIndex undoes CREATE INDEX index ON table. MySQL scopes index names to
+// the table; Postgres and SQLite scope them to the schema, so an unqualified
+// index on a schema-qualified table gets the table's schema.
+func dropIndex(d db.Driver, index, table string) string {
+	if d == nil || d.Name() == "mysql" {
+		return fmt.Sprintf("DROP INDEX %s ON %s;\n", index, table)
+	}
+	if schema, _, ok := strings.Cut(table, "."); ok && !strings.Contains(index, ".") {
+		index = schema + "." + index
+	}
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s;\n", index)
+}
+
+// addedColumns returns the table and columns of an ALTER TABLE whose every
+// clause adds a column, or no columns if any clause does something else.
+func addedColumns(s string) (string, []string) {
+	m := alterTableRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", nil
+	}
+	var cols []string
+	for _, clause := range splitClauses(m[2]) {
+		c := addColumnRe.FindStringSubmatch(strings.TrimSpace(clause))
+		if c == nil || isConstraint(c[1]) || strings.HasPrefix(c[1], "(") {
+			return "", nil
+		}
+		cols = append(cols, c[1])
+	}
+	return m[1], cols
+}
+
+// splitClauses splits an ALTER TABLE clause list at commas outside
+// parentheses and quotes.
+func splitClauses(s string) []string {
+	var out []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+// isConstraint reports whether an ADD clause adds a key or constraint
+// rather than a column.
+func isConstraint(word string) bool {
+	switch strings.ToUpper(word) {
+	case "CONSTRAINT", "INDEX", "KEY", "UNIQUE", "PRIMARY", "FOREIGN", "CHECK", "FULLTEXT", "SPATIAL":
+		return true
+	}
+	return false
+}
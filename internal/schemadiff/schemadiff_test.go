@@ -0,0 +1,90 @@
+package schemadiff
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+)
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.sql")
+	if err := os.WriteFile(schema, []byte("CREATE TABLE users(id INT);\nALTER TABLE users ADD COLUMN email VARCHAR(255);\nUPDATE users SET email = '';"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a fake diff tool that prints the expected schema as the diff
+	fake := Command{Path: "sh", Args: []string{"-c", `test "$1" = "app-dsn" && cat "$0"`, "{schema}", "{dsn}"}}
+
+	c, err := Create(context.Background(), fake, schema, "app-dsn", dir, "20250101000000", "add_users", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	up, _ := os.ReadFile(c.UpPath)
+	if !strings.HasPrefix(string(up), "CREATE TABLE users(id INT);") {
+		t.Fatalf("up file: %q", up)
+	}
+	down, _ := os.ReadFile(c.DownPath)
+	want := "-- TODO: reverse manually: UPDATE users SET email = ''\n" +
+		"ALTER TABLE users DROP COLUMN email;\n" +
+		"DROP TABLE IF EXISTS users;\n"
+	if string(down) != want {
+		t.Fatalf("down file:\n%s\nwant:\n%s", down, want)
+	}
+}
+
+func TestCreateFailures(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := Create(ctx, Command{Path: "no-such-diff-tool"}, "s.sql", "", dir, "1", "x", "", ""); !errors.Is(err, ErrNoCommand) {
+		t.Fatalf("expected ErrNoCommand, got %v", err)
+	}
+	failing := Command{Path: "sh", Args: []string{"-c", "echo cannot connect >&2; exit 3"}}
+	if _, err := Create(ctx, failing, "s.sql", "", dir, "1", "x", "", ""); err == nil || !strings.Contains(err.Error(), "cannot connect") {
+		t.Fatalf("expected the tool's stderr in the error, got %v", err)
+	}
+	empty := Command{Path: "sh", Args: []string{"-c", "echo '-- no changes'"}}
+	if _, err := Create(ctx, empty, "s.sql", "", dir, "1", "x", "", ""); !errors.Is(err, ErrNoChanges) {
+		t.Fatalf("expected ErrNoChanges, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("nothing should be written on failure, found %d files", len(entries))
+	}
+}
+
+func TestReverseMultiClauseAlter(t *testing.T) {
+	cases := map[string]string{
+		"ALTER TABLE t ADD COLUMN a INT, ADD COLUMN b DECIMAL(10,2) DEFAULT 'x,y';": "ALTER TABLE t DROP COLUMN b, DROP COLUMN a;\n",
+		"ALTER TABLE t ADD a INT, ADD INDEX idx_a (a);":                             "-- TODO: reverse manually: ALTER TABLE t ADD a INT, ADD INDEX idx_a (a)\n",
+		"ALTER TABLE t ADD COLUMN a INT, DROP COLUMN old;":                          "-- TODO: reverse manually: ALTER TABLE t ADD COLUMN a INT, DROP COLUMN old\n",
+		"ALTER TABLE t ADD COLUMN (a INT, b INT);":                                  "-- TODO: reverse manually: ALTER TABLE t ADD COLUMN (a INT, b INT)\n",
+	}
+	for up, want := range cases {
+		if got := Reverse(up, nil); got != want {
+			t.Errorf("Reverse(%q) = %q, want %q", up, got, want)
+		}
+	}
+}
+
+func TestReverseIndexDialects(t *testing.T) {
+	up := "CREATE INDEX idx_email ON users (email);\nCREATE UNIQUE INDEX idx_sku ON shop.items (sku);"
+	cases := []struct {
+		driver db.Driver
+		want   string
+	}{
+		{nil, "DROP INDEX idx_sku ON shop.items;\nDROP INDEX idx_email ON users;\n"},
+		{db.MySQL, "DROP INDEX idx_sku ON shop.items;\nDROP INDEX idx_email ON users;\n"},
+		{db.Postgres, "DROP INDEX IF EXISTS shop.idx_sku;\nDROP INDEX IF EXISTS idx_email;\n"},
+		{db.SQLite, "DROP INDEX IF EXISTS shop.idx_sku;\nDROP INDEX IF EXISTS idx_email;\n"},
+	}
+	for i, c := range cases {
+		if got := Reverse(up, c.driver); got != c.want {
+			t.Errorf("case %d: Reverse = %q, want %q", i, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package sqlsplit
+
+import "strings"
+
+// NamedParams returns the distinct :name parameters referenced in script, in
+// order of first use. Quoted strings, identifiers and comments are skipped,
+// as are Postgres casts (::type) and MySQL assignments (:=).
+func NamedParams(script string) []string {
+	return NamedParamsDialect(script, MySQL)
+}
+
+// NamedParamsDialect is NamedParams with d's quoting and comment rules.
+func NamedParamsDialect(script string, d Dialect) []string {
+	_, names := BindNamedDialect(script, d, func(int) string { return "" })
+	seen := map[string]bool{}
+	var out []string
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// BindNamed replaces each :name parameter in stmt with placeholder(i), i
+// counting from 1, and returns the rewritten statement with the parameter
+// names in bind order (repeats included).
+func BindNamed(stmt string, placeholder func(i int) string) (string, []string) {
+	return BindNamedDialect(stmt, MySQL, placeholder)
+}
+
+// BindNamedDialect is BindNamed with d's quoting and comment rules, so a
+// :name inside a Postgres dollar-quoted body is left alone.
+func BindNamedDialect(stmt string, d Dialect, placeholder func(i int) string) (string, []string) {
+	var out strings.Builder
+	var names []string
+	n := len(stmt)
+	for i := 0; i < n; i++ {
+		c := stmt[i]
+		if end, _ := skipSpan(stmt, i, d); end > i {
+			out.WriteString(stmt[i:end])
+			i = end - 1
+			continue
+		}
+		switch {
+		case c == ':' && i+1 < n && stmt[i+1] == ':':
+			out.WriteString("::")
+			i++
+		case c == ':' && i+1 < n && isIdentStart(stmt[i+1]) && (i == 0 || !isIdent(stmt[i-1])):
+			end := i + 1
+			for end < n && isIdent(stmt[end]) {
+				end++
+			}
+			names = append(names, stmt[i+1:end])
+			out.WriteString(placeholder(len(names)))
+			i = end - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), names
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdent(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,32 @@
+package sqlsplit
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestBindNamed(t *testing.T) {
+	stmt := `INSERT INTO tenants (id, note, at) VALUES (:tenant_id, ':not_a_param', '10:30') -- :nor_this
+ON CONFLICT DO NOTHING; SELECT :tenant_id::text, @x := 1, :region`
+	got, names := BindNamed(stmt, func(i int) string { return "$" + strconv.Itoa(i) })
+	want := `INSERT INTO tenants (id, note, at) VALUES ($1, ':not_a_param', '10:30') -- :nor_this
+ON CONFLICT DO NOTHING; SELECT $2::text, @x := 1, $3`
+	if got != want {
+		t.Fatalf("bind mismatch:\n got %s\nwant %s", got, want)
+	}
+	if !reflect.DeepEqual(names, []string{"tenant_id", "tenant_id", "region"}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	if p := NamedParams(stmt); !reflect.DeepEqual(p, []string{"tenant_id", "region"}) {
+		t.Fatalf("unexpected params: %v", p)
+	}
+}
+
+func TestBindNamedPostgresSkipsDollarQuotes(t *testing.T) {
+	stmt := "CREATE FUNCTION f() RETURNS int AS $$ BEGIN x := :inner; RETURN 1; END $$ LANGUAGE plpgsql; SELECT doc #> '{a}', :tenant"
+	got, names := BindNamedDialect(stmt, Postgres, func(i int) string { return "$" + strconv.Itoa(i) })
+	if !reflect.DeepEqual(names, []string{"tenant"}) || got != "CREATE FUNCTION f() RETURNS int AS $$ BEGIN x := :inner; RETURN 1; END $$ LANGUAGE plpgsql; SELECT doc #> '{a}', $1" {
+		t.Fatalf("bind = %s, %v", got, names)
+	}
+}
@@ -0,0 +1,174 @@
+package sqlsplit
+
+import "strings"
+
+// Dialect selects the lexical rules of a database, named like db.Driver's
+// Name. They differ in what starts a comment or a quoted section.
+type Dialect string
+
+const (
+	// MySQL treats # as a comment, allows backslash escapes in strings and
+	// understands DELIMITER lines. It is the default.
+	MySQL Dialect = "mysql"
+	// Postgres has $$ and $tag$ dollar-quoted strings, such as function
+	// bodies, and # in operators like #> and #>>.
+	Postgres Dialect = "postgres"
+	// SQLite has neither # comments nor backslash escapes.
+	SQLite Dialect = "sqlite"
+)
+
+// Split breaks a MySQL script into statements; see SplitDialect.
+func Split(script string) []string {
+	return SplitDialect(script, MySQL)
+}
+
+// SplitDialect breaks a SQL script into individual statements on `;`,
+// ignoring semicolons inside quoted strings, identifiers, dollar-quoted
+// bodies (Postgres) and comments. Statements are trimmed and returned
+// without the terminator; comment-only fragments are dropped.
+//
+// On MySQL a `DELIMITER $$` line, as understood by the mysql client,
+// switches the terminator until the next DELIMITER line, so stored-program
+// bodies with inner semicolons stay whole. DELIMITER lines themselves are
+// not returned.
+func SplitDialect(script string, d Dialect) []string {
+	var out []string
+	var cur strings.Builder
+	hasCode := false
+	delim := ";"
+	flush := func() {
+		if hasCode {
+			out = append(out, strings.TrimSpace(cur.String()))
+		}
+		cur.Reset()
+		hasCode = false
+	}
+
+	n := len(script)
+	for i := 0; i < n; i++ {
+		c := script[i]
+		if end, code := skipSpan(script, i, d); end > i {
+			cur.WriteString(script[i:end])
+			hasCode = hasCode || code
+			i = end - 1
+			continue
+		}
+		switch {
+		case d == MySQL && !hasCode && lineStart(script, i) && isDelimiterCmd(script[i:]):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = n
+			} else {
+				end += i
+			}
+			if nd := strings.TrimSpace(script[i+len("DELIMITER") : end]); nd != "" {
+				delim = nd
+			}
+			cur.Reset()
+			i = end - 1
+		case strings.HasPrefix(script[i:], delim):
+			flush()
+			i += len(delim) - 1
+		default:
+			if !isSpace(c) {
+				hasCode = true
+			}
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return out
+}
+
+// skipSpan returns the index just past the quoted section or comment that
+// starts at i, and whether it counts as code (quotes do, comments don't).
+// It returns i when none starts there.
+func skipSpan(s string, i int, d Dialect) (end int, code bool) {
+	n := len(s)
+	c := s[i]
+	switch {
+	case c == '\'' || c == '"' || c == '`':
+		return quoteEnd(s, i, d == MySQL), true
+	case c == '$' && d == Postgres:
+		if tag, ok := dollarTag(s, i); ok {
+			if j := strings.Index(s[i+len(tag):], tag); j >= 0 {
+				return i + len(tag) + j + len(tag), true
+			}
+			return n, true
+		}
+	case c == '-' && i+1 < n && s[i+1] == '-' && (i+2 == n || isSpace(s[i+2])), c == '#' && d == MySQL:
+		j := strings.IndexByte(s[i:], '\n')
+		if j < 0 {
+			return n, false
+		}
+		return i + j, false
+	case c == '/' && i+1 < n && s[i+1] == '*':
+		j := strings.Index(s[i+2:], "*/")
+		if j < 0 {
+			return n, false
+		}
+		return i + j + 4, false
+	}
+	return i, false
+}
+
+// dollarTag returns the opening $tag$ (possibly $$) of a dollar-quoted
+// string at i. A $ that continues an identifier or is a positional
+// parameter ($1) doesn't open one.
+func dollarTag(s string, i int) (string, bool) {
+	if i > 0 && (isIdent(s[i-1]) || s[i-1] == '$') {
+		return "", false
+	}
+	j := i + 1
+	for j < len(s) && s[j] != '$' {
+		if !isIdent(s[j]) || (j == i+1 && !isIdentStart(s[j])) {
+			return "", false
+		}
+		j++
+	}
+	if j >= len(s) {
+		return "", false
+	}
+	return s[i : j+1], true
+}
+
+// quoteEnd returns the index just past the quoted section starting at i.
+// With backslash set, backslash escapes apply to string literals (MySQL);
+// doubled quotes work for all.
+func quoteEnd(s string, i int, backslash bool) int {
+	q := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if backslash && q != '`' {
+				j++
+			}
+		case q:
+			if j+1 < len(s) && s[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// lineStart reports whether only blanks precede i on its line.
+func lineStart(s string, i int) bool {
+	for j := i - 1; j >= 0 && s[j] != '\n'; j-- {
+		if s[j] != ' ' && s[j] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDelimiterCmd(s string) bool {
+	const kw = "DELIMITER"
+	return len(s) > len(kw) && strings.EqualFold(s[:len(kw)], kw) && (s[len(kw)] == ' ' || s[len(kw)] == '\t')
+}
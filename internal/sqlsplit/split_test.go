@@ -0,0 +1,87 @@
+package sqlsplit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	script := `-- header comment; not a statement
+INSERT INTO t VALUES ('a;b');
+INSERT INTO t VALUES ("it\"s;"), ('it''s;');
+/* block; comment */ UPDATE t SET v = 1 # trailing; comment
+;
+SELECT ` + "`odd;col`" + ` FROM t`
+	got := Split(script)
+	want := []string{
+		"-- header comment; not a statement\nINSERT INTO t VALUES ('a;b')",
+		`INSERT INTO t VALUES ("it\"s;"), ('it''s;')`,
+		"/* block; comment */ UPDATE t SET v = 1 # trailing; comment",
+		"SELECT `odd;col` FROM t",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("split mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSplitDropsEmptyAndCommentOnly(t *testing.T) {
+	got := Split(";;\n-- only a comment\n;  ")
+	if len(got) != 0 {
+		t.Fatalf("expected no statements, got %q", got)
+	}
+}
+
+func TestSplitDelimiter(t *testing.T) {
+	script := `CREATE TABLE audit (msg VARCHAR(64));
+DELIMITER $$
+CREATE PROCEDURE log_it(IN m VARCHAR(64))
+BEGIN
+  INSERT INTO audit VALUES (m);
+  SELECT 'done; really';
+END$$
+delimiter ;
+CALL log_it('x;y');`
+	got := Split(script)
+	want := []string{
+		"CREATE TABLE audit (msg VARCHAR(64))",
+		"CREATE PROCEDURE log_it(IN m VARCHAR(64))\nBEGIN\n  INSERT INTO audit VALUES (m);\n  SELECT 'done; really';\nEND",
+		"CALL log_it('x;y')",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("split mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSplitPostgresDollarQuotes(t *testing.T) {
+	script := `CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at := now(); -- not the end
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DO $body$ BEGIN PERFORM 1; END $body$;
+SELECT $1, 'a\'; SELECT 2`
+	got := SplitDialect(script, Postgres)
+	want := []string{
+		"CREATE FUNCTION touch() RETURNS trigger AS $$\nBEGIN\n  NEW.updated_at := now(); -- not the end\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql",
+		"DO $body$ BEGIN PERFORM 1; END $body$",
+		`SELECT $1, 'a\'`,
+		"SELECT 2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("split mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSplitPostgresJSONBOperators(t *testing.T) {
+	script := "SELECT doc #> '{a,b}', doc #>> '{c}' FROM t; UPDATE t SET n = 1"
+	got := SplitDialect(script, Postgres)
+	want := []string{"SELECT doc #> '{a,b}', doc #>> '{c}' FROM t", "UPDATE t SET n = 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("split mismatch:\n got %q\nwant %q", got, want)
+	}
+	// on MySQL # still starts a comment
+	if got := Split("SELECT 1 # a; comment\n; SELECT 2"); len(got) != 2 || got[0] != "SELECT 1 # a; comment" {
+		t.Fatalf("mysql split = %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package gomigratex
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mirajehossain/gomigratex/internal/db"
+	"github.com/mirajehossain/gomigratex/internal/lock"
+	"github.com/mirajehossain/gomigratex/internal/migrator"
+)
+
+// Retryable reports whether a failed up run is worth running again from
+// scratch: the database connection or the lock connection dropped, or the
+// server reported a transient condition (see db.IsTransient). Drift,
+// missing parameters, errors in the SQL itself, a lock held by another run
+// and cancellation are not retryable.
+func Retryable(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, migrator.ErrDrift), errors.Is(err, migrator.ErrDownDrift),
+		errors.Is(err, migrator.ErrChecksumAlgo), errors.Is(err, migrator.ErrOrphaned),
+		errors.Is(err, migrator.ErrMissingParam), errors.Is(err, migrator.ErrTargetNotFound),
+		errors.Is(err, lock.ErrNotAcquired):
+		return false
+	case errors.Is(err, lock.ErrLost):
+		return true
+	}
+	return db.IsTransient(err)
+}
+
+// RetryRun calls run, and again up to retries more times after delay while
+// it fails with a Retryable error. run should be the whole up flow: open
+// the database, acquire the lock, plan and apply. Planning again is what
+// makes this safe, since migrations applied by an earlier attempt are no
+// longer pending. onRetry, if set, is called before each new attempt with
+// its number (starting at 2) and the error that caused it. The last error
+// is returned.
+func RetryRun(ctx context.Context, retries int, delay time.Duration, run func(ctx context.Context) error, onRetry func(attempt int, err error)) error {
+	for attempt := 1; ; attempt++ {
+		err := run(ctx)
+		if err == nil || attempt > retries || !Retryable(err) {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt+1, err)
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
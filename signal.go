@@ -0,0 +1,52 @@
+package gomigratex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ExitAborted is the exit status for a run stopped by SIGINT or SIGTERM,
+// 128 plus SIGINT as shells report it.
+const ExitAborted = 130
+
+// ErrAborted is the cause of a SignalContext cancelled by a signal.
+var ErrAborted = errors.New("aborted by signal, rolled back current migration")
+
+// SignalContext returns a context cancelled with ErrAborted as its cause on
+// SIGINT or SIGTERM. Run migrations under it: the cancellation reaches the
+// running statement, its transaction is rolled back and recorded as
+// failed, and ApplyUp returns. Release the lock afterwards as usual;
+// Release still unlocks with a cancelled context. stop restores default
+// signal handling.
+func SignalContext(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigs:
+			cancel(fmt.Errorf("%w (%s)", ErrAborted, sig))
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+			cancel(context.Canceled)
+		})
+	}
+}
+
+// Aborted reports whether ctx, from SignalContext, was cancelled by a
+// signal. Log ErrAborted and exit with ExitAborted when it is.
+func Aborted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrAborted)
+}